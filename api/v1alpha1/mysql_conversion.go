@@ -1,4 +1,233 @@
 package v1alpha1
 
-// Hub indicates this version is hub version
-func (*MySQL) Hub() {}
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sample-mysql-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// conversionDataAnnotation 은 v1alpha1 로 표현할 수 없는 v1beta1 의 필드이다. v1beta1 로 되돌릴 때 복원하고 지운다
+const conversionDataAnnotation = "mysql.sample.com/v1alpha1-conversion-data"
+
+// conversionData 는 v1alpha1 로 변환할 때 어노테이션에 보관하는 v1beta1 의 필드이다
+// +kubebuilder:object:generate=false
+type conversionData struct {
+	// PodTemplate 은 Resources 를 제외한 파드 설정이다. Resources 는 v1alpha1 의 Resources 로 변환한다
+	PodTemplate v1beta1.PodTemplateSpec `json:"podTemplate"`
+
+	// Team 과 CostCenter 는 소유자 정보이다. 이름은 v1alpha1 의 OwnerName 으로 변환한다
+	Team       string `json:"team,omitempty"`
+	CostCenter string `json:"costCenter,omitempty"`
+}
+
+// ConvertTo 는 MySQL(v1alpha1)을 MySQL(v1beta1)으로 변환한다
+func (src *MySQL) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MySQL)
+	in := src.DeepCopy()
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Spec = v1beta1.MySQLSpec{
+		Owner: v1beta1.OwnerSpec{Name: in.Spec.OwnerName},
+		Server: v1beta1.ServerSpec{
+			Version:               in.Spec.Version,
+			Image:                 in.Spec.Image,
+			Config:                v1beta1.MySQLConfig(in.Spec.Config),
+			RootPasswordSecretRef: in.Spec.RootPasswordSecretRef,
+		},
+		Storage: v1beta1.StorageSpec{
+			Size:               in.Spec.Storage.Size,
+			StorageClassName:   in.Spec.Storage.StorageClassName,
+			AccessModes:        in.Spec.Storage.AccessModes,
+			ScaleDownPVCPolicy: v1beta1.ScaleDownPVCPolicy(in.Spec.ScaleDownPVCPolicy),
+		},
+		Replication: v1beta1.ReplicationSpec{Replicas: in.Spec.Replicas},
+		Backup: v1beta1.BackupSpec{
+			FinalBackupStorage: convertBackupStorageRefTo(in.Spec.FinalBackupStorage),
+		},
+		Services:           v1beta1.ServicesSpec{Read: v1beta1.ReadServiceSpec(in.Spec.ReadService)},
+		DeletionPolicy:     v1beta1.DeletionPolicy(in.Spec.DeletionPolicy),
+		DeletionProtection: in.Spec.DeletionProtection,
+	}
+	if archive := in.Spec.BinlogArchive; archive != nil {
+		dst.Spec.Backup.BinlogArchive = &v1beta1.BinlogArchiveSpec{Storage: ConvertBackupStorageTo(&archive.Storage), Interval: archive.Interval}
+	}
+	if bootstrap := in.Spec.Bootstrap; bootstrap != nil {
+		dst.Spec.Bootstrap = &v1beta1.BootstrapSpec{CloneFrom: (*v1beta1.BootstrapClone)(bootstrap.CloneFrom)}
+		if backup := bootstrap.FromBackup; backup != nil {
+			dst.Spec.Bootstrap.FromBackup = &v1beta1.BootstrapBackup{
+				Name:        backup.Name,
+				Storage:     convertBackupStorageRefTo(backup.Storage),
+				Key:         backup.Key,
+				PointInTime: (*v1beta1.PointInTimeRecovery)(backup.PointInTime),
+			}
+		}
+	}
+
+	if raw, ok := dst.Annotations[conversionDataAnnotation]; ok {
+		restored := conversionData{}
+		if err := json.Unmarshal([]byte(raw), &restored); err != nil {
+			return fmt.Errorf("invalid %s annotation: %v", conversionDataAnnotation, err)
+		}
+		removeAnnotation(&dst.ObjectMeta, conversionDataAnnotation)
+		dst.Spec.PodTemplate = restored.PodTemplate
+		dst.Spec.Owner.Team = restored.Team
+		dst.Spec.Owner.CostCenter = restored.CostCenter
+	}
+	dst.Spec.PodTemplate.Resources = v1beta1.MySQLResources(in.Spec.Resources)
+
+	dst.Status = v1beta1.MySQLStatus{
+		Conditions:              in.Status.Conditions,
+		Version:                 in.Status.Version,
+		StorageCapacity:         in.Status.StorageCapacity,
+		Primary:                 in.Status.Primary,
+		GTIDMode:                in.Status.GTIDMode,
+		PrimaryUnavailableSince: in.Status.PrimaryUnavailableSince,
+		LastFailover:            (*v1beta1.FailoverStatus)(in.Status.LastFailover),
+		BinlogArchive:           (*v1beta1.BinlogArchiveStatus)(in.Status.BinlogArchive),
+	}
+	for _, instance := range in.Status.Instances {
+		dst.Status.Instances = append(dst.Status.Instances, v1beta1.InstanceStatus(instance))
+	}
+	if bootstrap := in.Status.Bootstrap; bootstrap != nil {
+		dst.Status.Bootstrap = &v1beta1.BootstrapStatus{
+			Backup:       bootstrap.Backup,
+			Storage:      ConvertBackupStorageTo(&bootstrap.Storage),
+			Key:          bootstrap.Key,
+			CloneFrom:    (*v1beta1.BootstrapCloneStatus)(bootstrap.CloneFrom),
+			GTIDSet:      bootstrap.GTIDSet,
+			PointInTime:  (*v1beta1.PointInTimeRecovery)(bootstrap.PointInTime),
+			BinlogsSince: bootstrap.BinlogsSince,
+		}
+	}
+	return nil
+}
+
+// ConvertFrom 은 MySQL(v1beta1)을 MySQL(v1alpha1)로 변환한다. v1alpha1 로 표현할 수 없는 파드 설정과 소유자 정보는 어노테이션에 보관한다
+func (dst *MySQL) ConvertFrom(srcRaw conversion.Hub) error {
+	in := srcRaw.(*v1beta1.MySQL).DeepCopy()
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Spec = MySQLSpec{
+		Replicas:  in.Spec.Replication.Replicas,
+		OwnerName: in.Spec.Owner.Name,
+		Version:   in.Spec.Server.Version,
+		Image:     in.Spec.Server.Image,
+		Resources: MySQLResources(in.Spec.PodTemplate.Resources),
+		Storage: MySQLStorage{
+			Size:             in.Spec.Storage.Size,
+			StorageClassName: in.Spec.Storage.StorageClassName,
+			AccessModes:      in.Spec.Storage.AccessModes,
+		},
+		RootPasswordSecretRef: in.Spec.Server.RootPasswordSecretRef,
+		Config:                MySQLConfig(in.Spec.Server.Config),
+		ReadService:           ReadServiceSpec(in.Spec.Services.Read),
+		DeletionPolicy:        DeletionPolicy(in.Spec.DeletionPolicy),
+		DeletionProtection:    in.Spec.DeletionProtection,
+		FinalBackupStorage:    convertBackupStorageRefFrom(in.Spec.Backup.FinalBackupStorage),
+		ScaleDownPVCPolicy:    ScaleDownPVCPolicy(in.Spec.Storage.ScaleDownPVCPolicy),
+	}
+	if archive := in.Spec.Backup.BinlogArchive; archive != nil {
+		dst.Spec.BinlogArchive = &BinlogArchiveSpec{Storage: ConvertBackupStorageFrom(&archive.Storage), Interval: archive.Interval}
+	}
+	if bootstrap := in.Spec.Bootstrap; bootstrap != nil {
+		dst.Spec.Bootstrap = &BootstrapSpec{CloneFrom: (*BootstrapClone)(bootstrap.CloneFrom)}
+		if backup := bootstrap.FromBackup; backup != nil {
+			dst.Spec.Bootstrap.FromBackup = &BootstrapBackup{
+				Name:        backup.Name,
+				Storage:     convertBackupStorageRefFrom(backup.Storage),
+				Key:         backup.Key,
+				PointInTime: (*PointInTimeRecovery)(backup.PointInTime),
+			}
+		}
+	}
+
+	// 보관할 필드가 없으면 어노테이션을 남기지 않는다. v1beta1 에 남아 있던 어노테이션도 복원되지 않도록 지운다
+	removeAnnotation(&dst.ObjectMeta, conversionDataAnnotation)
+	data := conversionData{PodTemplate: in.Spec.PodTemplate, Team: in.Spec.Owner.Team, CostCenter: in.Spec.Owner.CostCenter}
+	data.PodTemplate.Resources = v1beta1.MySQLResources{}
+	if !equality.Semantic.DeepEqual(data, conversionData{}) {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		setAnnotation(&dst.ObjectMeta, conversionDataAnnotation, string(raw))
+	}
+
+	dst.Status = MySQLStatus{
+		Conditions:              in.Status.Conditions,
+		Version:                 in.Status.Version,
+		StorageCapacity:         in.Status.StorageCapacity,
+		Primary:                 in.Status.Primary,
+		GTIDMode:                in.Status.GTIDMode,
+		PrimaryUnavailableSince: in.Status.PrimaryUnavailableSince,
+		LastFailover:            (*FailoverStatus)(in.Status.LastFailover),
+		BinlogArchive:           (*BinlogArchiveStatus)(in.Status.BinlogArchive),
+	}
+	for _, instance := range in.Status.Instances {
+		dst.Status.Instances = append(dst.Status.Instances, InstanceStatus(instance))
+	}
+	if bootstrap := in.Status.Bootstrap; bootstrap != nil {
+		dst.Status.Bootstrap = &BootstrapStatus{
+			Backup:       bootstrap.Backup,
+			Storage:      ConvertBackupStorageFrom(&bootstrap.Storage),
+			Key:          bootstrap.Key,
+			CloneFrom:    (*BootstrapCloneStatus)(bootstrap.CloneFrom),
+			GTIDSet:      bootstrap.GTIDSet,
+			PointInTime:  (*PointInTimeRecovery)(bootstrap.PointInTime),
+			BinlogsSince: bootstrap.BinlogsSince,
+		}
+	}
+	return nil
+}
+
+// ConvertBackupStorageTo 는 BackupStorage 를 v1beta1 으로 변환한다. MySQLBackup 은 v1alpha1 에만 있으므로
+// 컨트롤러가 백업의 저장소를 MySQL 의 저장소와 비교하거나 MySQL 에 기록할 때 사용한다
+func ConvertBackupStorageTo(in *BackupStorage) v1beta1.BackupStorage {
+	in = in.DeepCopy()
+	return v1beta1.BackupStorage{
+		PersistentVolumeClaim: (*v1beta1.PVCBackupStorage)(in.PersistentVolumeClaim),
+		S3:                    (*v1beta1.S3BackupStorage)(in.S3),
+	}
+}
+
+// ConvertBackupStorageFrom 은 v1beta1 의 BackupStorage 를 변환한다. MySQL 의 저장소로 MySQLBackup 을 만들 때 사용한다
+func ConvertBackupStorageFrom(in *v1beta1.BackupStorage) BackupStorage {
+	in = in.DeepCopy()
+	return BackupStorage{
+		PersistentVolumeClaim: (*PVCBackupStorage)(in.PersistentVolumeClaim),
+		S3:                    (*S3BackupStorage)(in.S3),
+	}
+}
+
+func convertBackupStorageRefTo(in *BackupStorage) *v1beta1.BackupStorage {
+	if in == nil {
+		return nil
+	}
+	out := ConvertBackupStorageTo(in)
+	return &out
+}
+
+func convertBackupStorageRefFrom(in *v1beta1.BackupStorage) *BackupStorage {
+	if in == nil {
+		return nil
+	}
+	out := ConvertBackupStorageFrom(in)
+	return &out
+}
+
+func setAnnotation(meta *metav1.ObjectMeta, key, value string) {
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[key] = value
+}
+
+// removeAnnotation 은 어노테이션을 지우고, 남은 어노테이션이 없으면 변환 전처럼 비워둔다
+func removeAnnotation(meta *metav1.ObjectMeta, key string) {
+	delete(meta.Annotations, key)
+	if len(meta.Annotations) == 0 {
+		meta.Annotations = nil
+	}
+}
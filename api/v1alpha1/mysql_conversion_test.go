@@ -0,0 +1,111 @@
+package v1alpha1
+
+import (
+	"time"
+
+	fuzz "github.com/google/gofuzz"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sample-mysql-operator/api/v1beta1"
+)
+
+var _ = Describe("mysql conversion", func() {
+	const rounds = 200
+
+	// newFuzzer 는 모든 필드를 채우는 퍼저를 만든다. 나중에 추가되는 필드도 별도 설정 없이 함께 검사된다.
+	// JSON 으로 그대로 되돌릴 수 없는 값은 API 서버가 허용하는 값만 만든다
+	newFuzzer := func(nilChance float64) *fuzz.Fuzzer {
+		return fuzz.New().NilChance(nilChance).NumElements(0, 3).Funcs(
+			// 변환은 TypeMeta 를 다루지 않는다
+			func(*metav1.TypeMeta, fuzz.Continue) {},
+			func(q *resource.Quantity, c fuzz.Continue) {
+				*q = *resource.NewQuantity(c.Int63n(1<<40), resource.BinarySI)
+			},
+			func(d *metav1.Duration, c fuzz.Continue) {
+				d.Duration = time.Duration(c.Int63n(int64(24 * time.Hour)))
+			},
+		)
+	}
+
+	expectEqual := func(expected, actual interface{}) {
+		Expect(equality.Semantic.DeepEqual(expected, actual)).Should(BeTrue(), diff.ObjectReflectDiff(expected, actual))
+	}
+
+	It("should round-trip v1beta1 through v1alpha1", func() {
+		for _, nilChance := range []float64{0, 0.5} {
+			f := newFuzzer(nilChance)
+			for i := 0; i < rounds; i++ {
+				hub := &v1beta1.MySQL{}
+				f.Fuzz(hub)
+
+				spoke := &MySQL{}
+				Expect(spoke.ConvertFrom(hub.DeepCopy())).Should(Succeed())
+				after := &v1beta1.MySQL{}
+				Expect(spoke.ConvertTo(after)).Should(Succeed())
+				expectEqual(hub, after)
+			}
+		}
+	})
+
+	It("should round-trip v1alpha1 through v1beta1", func() {
+		for _, nilChance := range []float64{0, 0.5} {
+			f := newFuzzer(nilChance)
+			for i := 0; i < rounds; i++ {
+				spoke := &MySQL{}
+				f.Fuzz(spoke)
+
+				hub := &v1beta1.MySQL{}
+				Expect(spoke.DeepCopy().ConvertTo(hub)).Should(Succeed())
+				after := &MySQL{}
+				Expect(after.ConvertFrom(hub)).Should(Succeed())
+				expectEqual(spoke, after)
+			}
+		}
+	})
+
+	It("should keep the pod template in an annotation only when it is set", func() {
+		hub := &v1beta1.MySQL{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+				PodTemplate: v1beta1.PodTemplateSpec{
+					Resources: v1beta1.MySQLResources{MySQL: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+					}},
+				},
+			},
+		}
+		spoke := &MySQL{}
+		Expect(spoke.ConvertFrom(hub)).Should(Succeed())
+		Expect(spoke.Annotations).ShouldNot(HaveKey(conversionDataAnnotation))
+		Expect(spoke.Spec.Resources.MySQL.Limits.Memory().String()).Should(Equal("1Gi"))
+
+		hub.Spec.PodTemplate.NodeSelector = map[string]string{"disktype": "ssd"}
+		Expect(spoke.ConvertFrom(hub)).Should(Succeed())
+		Expect(spoke.Annotations).Should(HaveKey(conversionDataAnnotation))
+
+		By("changing the resources in v1alpha1")
+		spoke.Spec.Resources.MySQL.Limits[corev1.ResourceMemory] = resource.MustParse("2Gi")
+		after := &v1beta1.MySQL{}
+		Expect(spoke.ConvertTo(after)).Should(Succeed())
+		Expect(after.Spec.PodTemplate.NodeSelector).Should(Equal(map[string]string{"disktype": "ssd"}))
+		Expect(after.Spec.PodTemplate.Resources.MySQL.Limits.Memory().String()).Should(Equal("2Gi"))
+		Expect(after.Annotations).ShouldNot(HaveKey(conversionDataAnnotation))
+	})
+
+	It("should convert backup storages of MySQLBackup", func() {
+		storage := &BackupStorage{S3: &S3BackupStorage{Endpoint: "minio:9000", Bucket: "backup", CredentialsSecret: corev1.LocalObjectReference{Name: "s3"}}}
+		converted := ConvertBackupStorageTo(storage)
+		Expect(converted.S3.Bucket).Should(Equal("backup"))
+		converted.S3.Bucket = "other"
+		Expect(storage.S3.Bucket).Should(Equal("backup"))
+		back := ConvertBackupStorageFrom(&converted)
+		Expect(back.S3.Bucket).Should(Equal("other"))
+		Expect(back.PersistentVolumeClaim).Should(BeNil())
+	})
+})
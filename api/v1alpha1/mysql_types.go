@@ -18,6 +18,8 @@ package v1alpha1
 
 import (
 	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -29,22 +31,333 @@ type MySQLSpec struct {
 	Replicas int32 `json:"replicas"`
 	// OwnerName 은 이 MySQL의 주인을 나타낸다. 반드시 [first name] [last name] 형태로 입력되어야 한다.
 	OwnerName string `json:"ownerName,omitempty"`
+
+	// Version 은 MySQL 서버의 버전을 나타낸다. 5.6, 5.7, 8.0 또는 그 패치 버전(예: 5.7.31)을 사용할 수 있다
+	// +kubebuilder:validation:Pattern=`^(5\.6|5\.7|8\.0)(\.[0-9]+)?$`
+	Version string `json:"version,omitempty"`
+
+	// Image 는 MySQL 서버 이미지를 직접 지정할 때 사용한다. 비어 있으면 Version 에 맞는 mysql 공식 이미지를 사용한다
+	Image string `json:"image,omitempty"`
+
+	// Resources 는 컨테이너별 CPU/메모리 요청량과 제한량을 나타낸다
+	Resources MySQLResources `json:"resources,omitempty"`
+
+	// Storage 는 MySQL 데이터를 저장할 볼륨을 나타낸다
+	Storage MySQLStorage `json:"storage,omitempty"`
+
+	// RootPasswordSecretRef 는 root 비밀번호가 저장된 시크릿의 키를 나타낸다. 비어 있으면 오퍼레이터가 <name>-root 시크릿을 생성한다.
+	// 클러스터를 생성한 후에 변경해도 이미 설정된 root 비밀번호는 바뀌지 않는다
+	RootPasswordSecretRef *corev1.SecretKeySelector `json:"rootPasswordSecretRef,omitempty"`
+
+	// Config 는 오퍼레이터가 생성하는 my.cnf 의 [mysqld] 섹션에 추가할 설정을 나타낸다
+	Config MySQLConfig `json:"config,omitempty"`
+
+	// ReadService 는 읽기 전용 서비스(<name>-read)의 설정을 나타낸다
+	ReadService ReadServiceSpec `json:"readService,omitempty"`
+
+	// Bootstrap 은 새 클러스터의 초기 데이터를 어디서 가져올지 나타낸다. 비어 있으면 빈 데이터로 시작한다. 생성 후에는 변경할 수 없다
+	Bootstrap *BootstrapSpec `json:"bootstrap,omitempty"`
+
+	// BinlogArchive 는 프라이머리의 바이너리 로그를 저장소에 계속 보관하는 설정이다. 백업과 보관된 바이너리 로그로
+	// 특정 시점까지 복구할 수 있다. 비어 있으면 바이너리 로그를 보관하지 않는다
+	BinlogArchive *BinlogArchiveSpec `json:"binlogArchive,omitempty"`
+
+	// DeletionPolicy 는 MySQL 을 삭제할 때 데이터 볼륨을 어떻게 처리할지 나타낸다. 비어 있으면 Retain 이다
+	// +kubebuilder:validation:Enum=Delete;Retain;BackupThenDelete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// DeletionProtection 이 true 이면 MySQL 을 삭제할 수 없다. 새 클러스터는 웹훅이 true 로 설정하며, 삭제하려면 먼저 false 로 바꿔야 한다
+	DeletionProtection *bool `json:"deletionProtection,omitempty"`
+
+	// FinalBackupStorage 는 DeletionPolicy 가 BackupThenDelete 일 때 마지막 백업을 저장할 곳이다
+	FinalBackupStorage *BackupStorage `json:"finalBackupStorage,omitempty"`
+
+	// ScaleDownPVCPolicy 는 Replicas 를 줄일 때 제거되는 파드의 데이터 볼륨 클레임을 어떻게 처리할지 나타낸다. 비어 있으면 Retain 이다
+	// +kubebuilder:validation:Enum=Delete;Retain
+	ScaleDownPVCPolicy ScaleDownPVCPolicy `json:"scaleDownPVCPolicy,omitempty"`
+}
+
+// ScaleDownPVCPolicy 는 레플리카 수를 줄일 때 제거되는 파드의 데이터 볼륨 클레임을 처리하는 방법이다
+type ScaleDownPVCPolicy string
+
+const (
+	// ScaleDownPVCPolicyDelete 는 파드가 제거된 뒤 데이터 볼륨 클레임을 삭제한다. 다시 늘리면 빈 볼륨에서 프라이머리를 복제한다
+	ScaleDownPVCPolicyDelete ScaleDownPVCPolicy = "Delete"
+	// ScaleDownPVCPolicyRetain 은 데이터 볼륨 클레임을 남겨둔다. 다시 늘리면 남겨둔 데이터에서 복제를 이어가므로
+	// 그동안의 바이너리 로그가 프라이머리에 남아 있어야 따라잡을 수 있다
+	ScaleDownPVCPolicyRetain ScaleDownPVCPolicy = "Retain"
+)
+
+// DeletionPolicy 는 MySQL 을 삭제할 때 데이터 볼륨을 처리하는 방법이다
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete 는 데이터 볼륨 클레임을 함께 삭제한다
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain 은 데이터 볼륨 클레임을 남겨둔다. 같은 이름의 MySQL 을 다시 만들면 남겨둔 볼륨을 그대로 사용한다
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyBackupThenDelete 는 FinalBackupStorage 에 마지막 백업을 받고 완료된 뒤 데이터 볼륨 클레임을 삭제한다
+	DeletionPolicyBackupThenDelete DeletionPolicy = "BackupThenDelete"
+)
+
+// BinlogArchiveSpec 은 바이너리 로그를 보관할 저장소를 나타낸다
+type BinlogArchiveSpec struct {
+	// Storage 는 바이너리 로그를 보관할 저장소이다. <name>/binlog/ 아래에 보관한다.
+	// 특정 시점 복구는 백업과 같은 저장소에서 바이너리 로그를 읽으므로 백업과 같은 저장소를 지정해야 한다
+	Storage BackupStorage `json:"storage"`
+
+	// Interval 은 현재 바이너리 로그를 닫고 보관하는 주기이다. 마지막 보관 이후의 트랜잭션은 복구할 수 없다. 비어 있으면 5분이다
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// BootstrapSpec 은 새 클러스터의 초기 데이터를 나타낸다. FromBackup 과 CloneFrom 중 하나만 지정할 수 있다
+type BootstrapSpec struct {
+	// FromBackup 은 백업을 첫 프라이머리에 복원하여 클러스터를 시작한다. 레플리카는 복원된 프라이머리에서 데이터를 복제한다.
+	// 복원한 데이터의 계정을 그대로 사용하므로 RootPasswordSecretRef 로 백업한 클러스터의 root 비밀번호를 지정해야 한다
+	FromBackup *BootstrapBackup `json:"fromBackup,omitempty"`
+
+	// CloneFrom 은 동작 중인 MySQL 의 데이터를 첫 프라이머리로 복제하여 클러스터를 시작한다. 레플리카가 있으면 레플리카에서 복제한다.
+	// 복제한 데이터의 계정을 그대로 사용하므로 RootPasswordSecretRef 로 원본 클러스터의 root 비밀번호를 지정해야 한다
+	CloneFrom *BootstrapClone `json:"cloneFrom,omitempty"`
+}
+
+// BootstrapClone 은 복제할 MySQL 을 나타낸다
+type BootstrapClone struct {
+	// Name 은 복제할 MySQL 의 이름이다
+	Name string `json:"name"`
+
+	// Namespace 는 복제할 MySQL 의 네임스페이스이다. 비어 있으면 같은 네임스페이스이다.
+	// 다른 네임스페이스의 클러스터는 그 네임스페이스에 이 네임스페이스를 허용하는 MySQLCloneGrant 가 있어야 복제할 수 있다
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// BootstrapBackup 은 복원할 백업을 나타낸다. Name 또는 Storage 와 Key 중 하나를 지정해야 한다
+type BootstrapBackup struct {
+	// Name 은 같은 네임스페이스에 있는 MySQLBackup 의 이름이다. 백업이 완료될 때까지 클러스터 생성을 기다린다
+	Name string `json:"name,omitempty"`
+
+	// Storage 는 MySQLBackup 없이 저장소에 있는 백업을 직접 지정할 때 사용한다. 다른 클러스터나 네임스페이스의 백업을 복원할 때 사용한다
+	Storage *BackupStorage `json:"storage,omitempty"`
+
+	// Key 는 Storage 안에서 백업의 경로이다. 예: sample/nightly.xbstream
+	Key string `json:"key,omitempty"`
+
+	// PointInTime 은 백업을 복원한 뒤 보관된 바이너리 로그를 재생하여 복구할 시점이다. 비어 있으면 백업 시점으로 복구한다
+	PointInTime *PointInTimeRecovery `json:"pointInTime,omitempty"`
+}
+
+// PointInTimeRecovery 는 바이너리 로그를 재생하여 복구할 시점을 나타낸다. Time 과 GTIDSet 중 하나를 지정해야 한다
+type PointInTimeRecovery struct {
+	// Time 은 복구할 시각이다. 이 시각 이전에 기록된 트랜잭션까지 재생한다
+	Time *metav1.Time `json:"time,omitempty"`
+
+	// GTIDSet 은 복구할 트랜잭션의 GTID 집합이다. 이 집합에 포함된 트랜잭션까지 재생한다
+	GTIDSet string `json:"gtidSet,omitempty"`
+
+	// BinlogPrefix 는 백업 저장소에서 바이너리 로그가 보관된 경로이다. 비어 있으면 백업한 클러스터의 <cluster>/binlog/ 이다.
+	// 저장소를 직접 지정한 경우 필수이다
+	BinlogPrefix string `json:"binlogPrefix,omitempty"`
+}
+
+// ReadServiceSpec 은 읽기 전용 서비스의 설정을 나타낸다
+type ReadServiceSpec struct {
+	// ExcludePrimary 가 true 이면 읽기 요청을 레플리카로만 보낸다. 레플리카가 없으면 읽기 전용 서비스로 접속할 수 없다
+	ExcludePrimary bool `json:"excludePrimary,omitempty"`
+
+	// MaxLagSeconds 는 읽기 요청을 받을 수 있는 레플리카의 최대 복제 지연 시간(초)이다. 복제가 이보다 늦거나 멈춘 레플리카는
+	// 따라잡을 때까지 읽기 전용 서비스에서 제외된다. 비어 있으면 지연 시간과 관계없이 모든 파드로 보낸다
+	// +kubebuilder:validation:Minimum=0
+	MaxLagSeconds *int64 `json:"maxLagSeconds,omitempty"`
+}
+
+// MySQLConfig 는 역할별 mysqld 설정을 나타낸다. 키는 mysqld 옵션 이름이고, 값이 비어 있으면 skip-name-resolve 와 같이 옵션 이름만 기록한다.
+// 자동으로 계산한 InnoDB 설정보다 우선한다
+type MySQLConfig struct {
+	// Primary 는 프라이머리에만 적용할 설정을 나타낸다
+	Primary map[string]string `json:"primary,omitempty"`
+
+	// Replica 는 레플리카에만 적용할 설정을 나타낸다
+	Replica map[string]string `json:"replica,omitempty"`
+}
+
+// MySQLResources 는 MySQL 파드의 컨테이너별 리소스를 나타낸다
+type MySQLResources struct {
+	// MySQL 은 mysql 컨테이너의 리소스를 나타낸다. 메모리 제한량(없으면 요청량)에 맞추어 InnoDB 설정을 자동으로 조정한다
+	MySQL corev1.ResourceRequirements `json:"mysql,omitempty"`
+
+	// Xtrabackup 은 xtrabackup 컨테이너의 리소스를 나타낸다
+	Xtrabackup corev1.ResourceRequirements `json:"xtrabackup,omitempty"`
+}
+
+// MySQLStorage 는 각 파드가 사용할 데이터 볼륨을 나타낸다
+type MySQLStorage struct {
+	// Size 는 볼륨의 크기를 나타낸다. 비어 있으면 2Gi 를 사용한다. 스토리지 클래스가 볼륨 확장을 허용하는 경우에만 늘릴 수 있고, 줄일 수는 없다
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// StorageClassName 은 볼륨이 사용할 스토리지 클래스를 나타낸다. 비어 있으면 기본 스토리지 클래스를 사용한다. 생성 후에는 변경할 수 없다
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// AccessModes 는 볼륨의 접근 모드를 나타낸다. 비어 있으면 ReadWriteOnce 를 사용한다. 생성 후에는 변경할 수 없다
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
 }
 
 // MySQLStatus defines the observed state of MySQL
 type MySQLStatus struct {
 	// Conditions represent the latest available observations of an object's state
 	Conditions condition.Conditions `json:"conditions,omitempty"`
+
+	// Version 은 현재 모든 파드에서 실제로 동작하고 있는 MySQL 서버의 버전을 나타낸다
+	Version string `json:"version,omitempty"`
+
+	// StorageCapacity 는 모든 파드의 데이터 볼륨 중 가장 작은 실제 용량을 나타낸다
+	StorageCapacity *resource.Quantity `json:"storageCapacity,omitempty"`
+
+	// Primary 는 현재 프라이머리 역할을 하는 파드, 즉 쓰기를 받는 파드의 이름을 나타낸다. <name>-primary 서비스는 이 파드로 연결된다
+	Primary string `json:"primary,omitempty"`
+
+	// GTIDMode 는 모든 서버가 도달한 gtid_mode 이다. 오퍼레이터는 이 값을 설정 파일에 기록하고, GTID 를 사용하지 않던 클러스터는
+	// OFF_PERMISSIVE 와 ON_PERMISSIVE 를 거쳐 한 단계씩 ON 으로 바꾼다. 페일오버와 프라이머리 전환은 ON 이 된 뒤에만 동작한다
+	GTIDMode string `json:"gtidMode,omitempty"`
+
+	// Instances 는 오퍼레이터가 각 파드의 MySQL 서버에 접속하여 관찰한 복제 상태이다
+	Instances []InstanceStatus `json:"instances,omitempty"`
+
+	// PrimaryUnavailableSince 는 프라이머리 파드가 준비되지 않은 상태가 된 시각이다. 일정 시간이 지나면 페일오버한다
+	PrimaryUnavailableSince *metav1.Time `json:"primaryUnavailableSince,omitempty"`
+
+	// LastFailover 는 마지막으로 수행한 페일오버의 기록이다
+	LastFailover *FailoverStatus `json:"lastFailover,omitempty"`
+
+	// Bootstrap 은 클러스터를 시작할 때 복원한 백업이다. 파드 템플릿은 이 값으로 만들어지므로 백업이 삭제되어도 바뀌지 않는다
+	Bootstrap *BootstrapStatus `json:"bootstrap,omitempty"`
+
+	// BinlogArchive 는 바이너리 로그 보관 상태와 복구할 수 있는 시간 범위이다
+	BinlogArchive *BinlogArchiveStatus `json:"binlogArchive,omitempty"`
+}
+
+// BinlogArchiveStatus 는 바이너리 로그 보관 상태이다
+type BinlogArchiveStatus struct {
+	// Since 는 바이너리 로그 보관을 시작한 시각이다. 이후에 완료된 백업부터 특정 시점 복구에 사용할 수 있다
+	Since metav1.Time `json:"since"`
+
+	// LastArchivedBinlog 는 마지막으로 보관한 바이너리 로그의 저장소 안 경로이다
+	LastArchivedBinlog string `json:"lastArchivedBinlog,omitempty"`
+
+	// LastArchivedTime 은 마지막으로 보관한 바이너리 로그에 마지막으로 기록된 시각이다
+	LastArchivedTime *metav1.Time `json:"lastArchivedTime,omitempty"`
+
+	// RecoverableFrom 과 RecoverableUntil 은 특정 시점 복구로 복구할 수 있는 시간 범위이다. RecoverableFrom 은
+	// 보관을 시작한 뒤 같은 저장소에 완료된 가장 오래된 백업의 완료 시각이다. 복구할 수 있는 백업이 없으면 비어 있다
+	RecoverableFrom  *metav1.Time `json:"recoverableFrom,omitempty"`
+	RecoverableUntil *metav1.Time `json:"recoverableUntil,omitempty"`
+
+	// Message 는 바이너리 로그를 보관하거나 상태를 확인하다 발생한 마지막 오류이다
+	Message string `json:"message,omitempty"`
+}
+
+// BootstrapStatus 는 클러스터를 시작할 때 복원한 백업이나 복제한 클러스터이다
+type BootstrapStatus struct {
+	// Backup 은 복원한 MySQLBackup 의 이름이다. 저장소를 직접 지정한 경우 비어 있다
+	Backup string `json:"backup,omitempty"`
+
+	// Storage 는 백업이 저장된 저장소이다
+	Storage BackupStorage `json:"storage,omitempty"`
+
+	// Key 는 저장소 안에서 백업의 경로이다. 클러스터를 복제한 경우 비어 있다
+	Key string `json:"key,omitempty"`
+
+	// CloneFrom 은 데이터를 복제한 클러스터와 파드이다
+	CloneFrom *BootstrapCloneStatus `json:"cloneFrom,omitempty"`
+
+	// GTIDSet 은 복원한 백업에 포함된 트랜잭션의 GTID 집합이다. 저장소를 직접 지정한 경우 비어 있다
+	GTIDSet string `json:"gtidSet,omitempty"`
+
+	// PointInTime 은 백업을 복원한 뒤 재생할 바이너리 로그와 복구할 시점이다. BinlogPrefix 는 항상 채워진다
+	PointInTime *PointInTimeRecovery `json:"pointInTime,omitempty"`
+
+	// BinlogsSince 는 재생할 바이너리 로그의 시작 시각이다. 이전에 닫힌 바이너리 로그의 트랜잭션은 모두 백업에 포함되어 있다
+	BinlogsSince *metav1.Time `json:"binlogsSince,omitempty"`
+}
+
+// BootstrapCloneStatus 는 데이터를 복제한 클러스터와 파드이다
+type BootstrapCloneStatus struct {
+	// Name 과 Namespace 는 복제한 MySQL 이다
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+
+	// Host 는 데이터를 받은 파드의 주소이다. 이 파드의 xtrabackup 컨테이너에서 데이터를 받는다
+	Host string `json:"host"`
+}
+
+// InstanceStatus 는 하나의 MySQL 파드에서 관찰한 복제 상태이다
+type InstanceStatus struct {
+	// Name 은 파드의 이름이다
+	Name string `json:"name"`
+
+	// Role 은 관찰한 복제 역할이다. 쓰기를 받으면 primary, 다른 파드를 복제하고 있으면 replica 이고, 알 수 없으면 비어 있다
+	Role string `json:"role,omitempty"`
+
+	// ReadOnly 는 read_only 시스템 변수의 값이다
+	ReadOnly bool `json:"readOnly"`
+
+	// SuperReadOnly 는 super_read_only 시스템 변수의 값이다
+	SuperReadOnly bool `json:"superReadOnly"`
+
+	// ExecutedGTIDSet 은 이 서버에서 실행한 트랜잭션의 GTID 집합이다
+	ExecutedGTIDSet string `json:"executedGtidSet,omitempty"`
+
+	// SourceHost 는 복제하고 있는 서버의 호스트이다
+	SourceHost string `json:"sourceHost,omitempty"`
+
+	// SecondsBehindSource 는 SHOW SLAVE STATUS 의 Seconds_Behind_Master 이다. SQL 스레드가 멈춰 있으면 비어 있다
+	SecondsBehindSource *int64 `json:"secondsBehindSource,omitempty"`
+
+	// IOThreadRunning 은 복제 I/O 스레드가 동작 중인지를 나타낸다
+	IOThreadRunning bool `json:"ioThreadRunning"`
+
+	// SQLThreadRunning 은 복제 SQL 스레드가 동작 중인지를 나타낸다
+	SQLThreadRunning bool `json:"sqlThreadRunning"`
+
+	// LastError 는 복제 스레드의 마지막 오류 또는 상태를 확인하지 못한 이유이다
+	LastError string `json:"lastError,omitempty"`
+}
+
+// FailoverStatus 는 프라이머리를 다른 파드로 옮긴 기록이다
+type FailoverStatus struct {
+	// Time 은 페일오버가 완료된 시각이다
+	Time metav1.Time `json:"time"`
+
+	// OldPrimary 는 페일오버 전의 프라이머리 파드 이름이다
+	OldPrimary string `json:"oldPrimary"`
+
+	// NewPrimary 는 새로 승격된 프라이머리 파드 이름이다
+	NewPrimary string `json:"newPrimary"`
+
+	// Reason 은 페일오버를 수행한 이유이다
+	Reason string `json:"reason,omitempty"`
 }
 
 const (
 	// ConditionTypeRunning 은 MySQL이 동작하고 있는지를 나타낸다
 	ConditionTypeRunning condition.ConditionType = "Running"
+	// ConditionTypeUpgrading 은 MySQL 버전 업그레이드가 진행 중인지를 나타낸다
+	ConditionTypeUpgrading condition.ConditionType = "Upgrading"
+	// ConditionTypeStorageResizing 은 데이터 볼륨 확장이 진행 중인지를 나타낸다
+	ConditionTypeStorageResizing condition.ConditionType = "StorageResizing"
+	// ConditionTypeSwitchover 는 요청된 프라이머리 전환이 진행 중인지와 그 결과를 나타낸다
+	ConditionTypeSwitchover condition.ConditionType = "Switchover"
+	// ConditionTypeBootstrap 은 Spec.Bootstrap 으로 초기 데이터를 가져오는 중인지와 그 결과를 나타낸다
+	ConditionTypeBootstrap condition.ConditionType = "Bootstrap"
+	// ConditionTypeDeleting 은 삭제 정책에 따른 정리가 진행 중인지와 그 결과를 나타낸다
+	ConditionTypeDeleting condition.ConditionType = "Deleting"
+	// ConditionTypeScalingDown 은 레플리카 수를 줄이기 위한 준비가 진행 중인지와 그 결과를 나타낸다
+	ConditionTypeScalingDown condition.ConditionType = "ScalingDown"
+	// ConditionTypeBackingUp 은 클러스터의 백업이 진행 중인지를 나타낸다
+	ConditionTypeBackingUp condition.ConditionType = "BackingUp"
 )
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
-// +kubebuilder:storageversion
 
 // MySQL is the Schema for the mysqls API
 type MySQL struct {
@@ -17,10 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sample-mysql-operator/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -45,55 +43,64 @@ func (r *MySQL) Default() {
 	mysqllog.Info("----- Start Default()", "name", r.Name)
 
 	// 오너 이름이 설정되어 있지 않으면 no body로 설정한다
+	r.Spec.OwnerName = v1beta1.NormalizeOwnerName(r.Spec.OwnerName)
 	if r.Spec.OwnerName == "" {
 		r.Spec.OwnerName = "no body"
 	}
+	// 버전이 설정되어 있지 않으면 기본 버전을 사용한다
+	if r.Spec.Version == "" {
+		r.Spec.Version = v1beta1.DefaultVersion
+	}
+	// 삭제 정책이 설정되어 있지 않으면 데이터를 남겨둔다
+	if r.Spec.DeletionPolicy == "" {
+		r.Spec.DeletionPolicy = DeletionPolicyRetain
+	}
+	// 새 클러스터는 실수로 삭제되지 않도록 보호한다. 기존 클러스터는 설정을 바꾸지 않는다
+	if r.Spec.DeletionProtection == nil && r.CreationTimestamp.IsZero() {
+		protected := true
+		r.Spec.DeletionProtection = &protected
+	}
+	// 레플리카 수를 줄일 때도 데이터를 남겨둔다
+	if r.Spec.ScaleDownPVCPolicy == "" {
+		r.Spec.ScaleDownPVCPolicy = ScaleDownPVCPolicyRetain
+	}
 }
 
-// +kubebuilder:webhook:verbs=create;update,path=/validate-mysql-sample-com-v1alpha1-mysql,mutating=false,failurePolicy=fail,groups=mysql.sample.com,resources=mysqls,versions=v1alpha1,name=vmysql.kb.io
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-mysql-sample-com-v1alpha1-mysql,mutating=false,failurePolicy=fail,groups=mysql.sample.com,resources=mysqls,versions=v1alpha1,name=vmysql.kb.io
 
 var _ webhook.Validator = &MySQL{}
 
+// v1alpha1 은 v1beta1 으로 변환한 뒤 v1beta1 의 규칙으로 확인한다. 오류는 v1beta1 의 필드 경로로 보고한다
+
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *MySQL) ValidateCreate() error {
 	mysqllog.Info("----- Start ValidateCreate()", "name", r.Name)
-	return validateMysql(r)
+	hub := &v1beta1.MySQL{}
+	if err := r.ConvertTo(hub); err != nil {
+		return err
+	}
+	return hub.ValidateCreate()
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *MySQL) ValidateUpdate(old runtime.Object) error {
 	mysqllog.Info("----- Start ValidateUpdate()", "name", r.Name)
-	return validateMysql(r)
-}
-
-func validateMysql(mysql *MySQL) error {
-	var allErrs field.ErrorList
-	if err := validateName(mysql.Spec.OwnerName); err != nil {
-		allErrs = append(allErrs, err)
+	hub, oldHub := &v1beta1.MySQL{}, &v1beta1.MySQL{}
+	if err := r.ConvertTo(hub); err != nil {
+		return err
 	}
-	if len(allErrs) != 0 {
-		return apierrors.NewInvalid(schema.GroupKind{Group: "mysql.sample.com", Kind: "MySQL"}, mysql.Name, allErrs)
+	if err := old.(*MySQL).ConvertTo(oldHub); err != nil {
+		return err
 	}
-	return nil
-}
-
-func validateName(name string) *field.Error {
-	spaceNum := 0
-	for _, char := range name {
-		if char == ' ' {
-			spaceNum++
-		}
-	}
-	if spaceNum != 1 {
-		return field.Invalid(field.NewPath("spec"), name, "OwnerName must be form of [first name] [last name]")
-	}
-	return nil
+	return hub.ValidateUpdate(oldHub)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (r *MySQL) ValidateDelete() error {
 	mysqllog.Info("validate delete", "name", r.Name)
-
-	// TODO(user): fill in your validation logic upon object deletion.
-	return nil
+	hub := &v1beta1.MySQL{}
+	if err := r.ConvertTo(hub); err != nil {
+		return err
+	}
+	return hub.ValidateDelete()
 }
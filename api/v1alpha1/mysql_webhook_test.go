@@ -0,0 +1,73 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("mysql webhook", func() {
+	newMySQL := func() *MySQL {
+		return &MySQL{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: MySQLSpec{
+				OwnerName: "woohyung han",
+				Replicas:  3,
+				Version:   "5.7",
+			},
+			Status: MySQLStatus{Version: "5.7"},
+		}
+	}
+
+	It("should validate updates with the rules of v1beta1", func() {
+		old := newMySQL()
+		mysql := old.DeepCopy()
+		mysql.Spec.Version = "5.6"
+		err := mysql.ValidateUpdate(old)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.server.version: Forbidden: downgrade from 5.7 to 5.6"))
+
+		old.Status.Conditions.SetCondition(condition.Condition{Type: ConditionTypeBackingUp, Status: corev1.ConditionTrue, Reason: "BackupRunning"})
+		mysql = old.DeepCopy()
+		mysql.Spec.Replicas = 2
+		mysql.Spec.Config.Replica = map[string]string{"read_only": "ON"}
+		err = mysql.ValidateUpdate(old)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.replication.replicas: Forbidden: can not be changed while BackingUp is in progress"))
+		Expect(err.Error()).Should(ContainSubstring("spec.server: Forbidden"))
+	})
+
+	It("should validate the pod template kept in the annotation", func() {
+		mysql := newMySQL()
+		mysql.Annotations = map[string]string{conversionDataAnnotation: "{"}
+		Expect(mysql.ValidateCreate()).ShouldNot(Succeed())
+
+		mysql.Annotations[conversionDataAnnotation] = `{"podTemplate":{"metadata":{"labels":{"app":"other"}}}}`
+		err := mysql.ValidateCreate()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.podTemplate.metadata.labels[app]"))
+	})
+
+	It("should refuse to delete a protected cluster", func() {
+		mysql := newMySQL()
+		protected := true
+		mysql.Spec.DeletionProtection = &protected
+		Expect(mysql.ValidateDelete()).ShouldNot(Succeed())
+
+		protected = false
+		Expect(mysql.ValidateDelete()).Should(Succeed())
+	})
+
+	It("should accept updates that keep an owner name from before the name rule", func() {
+		old := newMySQL()
+		old.Spec.OwnerName = "Cher"
+		protected := true
+		old.Spec.DeletionProtection = &protected
+		mysql := old.DeepCopy()
+		unprotected := false
+		mysql.Spec.DeletionProtection = &unprotected
+		Expect(mysql.ValidateUpdate(old)).Should(Succeed())
+	})
+})
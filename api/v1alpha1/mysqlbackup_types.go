@@ -0,0 +1,161 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MySQLBackupSpec defines the desired state of MySQLBackup
+type MySQLBackupSpec struct {
+	// Cluster 는 백업할 MySQL 의 이름이다. 백업과 같은 네임스페이스에 있어야 한다
+	Cluster string `json:"cluster"`
+
+	// Source 는 백업을 받을 파드를 나타낸다. replica 이면 복제가 정상인 레플리카 중 지연이 가장 적은 파드에서,
+	// primary 이면 프라이머리에서 받는다. 비어 있으면 replica 를 사용한다
+	// +kubebuilder:validation:Enum=replica;primary
+	Source BackupSource `json:"source,omitempty"`
+
+	// Storage 는 백업을 저장할 곳을 나타낸다
+	Storage BackupStorage `json:"storage"`
+}
+
+// BackupSource 는 백업을 받을 파드의 역할이다
+type BackupSource string
+
+const (
+	// BackupSourceReplica 는 레플리카에서 백업을 받는다
+	BackupSourceReplica BackupSource = "replica"
+	// BackupSourcePrimary 는 프라이머리에서 백업을 받는다
+	BackupSourcePrimary BackupSource = "primary"
+)
+
+// BackupStorage 는 백업 저장소를 나타낸다. 하나의 저장소만 지정해야 한다
+type BackupStorage struct {
+	// PersistentVolumeClaim 은 볼륨 클레임에 파일로 저장한다
+	PersistentVolumeClaim *PVCBackupStorage `json:"persistentVolumeClaim,omitempty"`
+
+	// S3 는 S3 호환 오브젝트 스토리지에 저장한다
+	S3 *S3BackupStorage `json:"s3,omitempty"`
+}
+
+// PVCBackupStorage 는 볼륨 클레임 저장소를 나타낸다
+type PVCBackupStorage struct {
+	// ClaimName 은 백업을 저장할 볼륨 클레임의 이름이다. 백업 잡이 실행되는 노드에서 마운트할 수 있어야 한다
+	ClaimName string `json:"claimName"`
+
+	// Path 는 볼륨 안에서 백업을 저장할 디렉터리이다. 비어 있으면 볼륨의 최상위 디렉터리를 사용한다
+	Path string `json:"path,omitempty"`
+}
+
+// S3BackupStorage 는 S3 호환 오브젝트 스토리지 저장소를 나타낸다
+type S3BackupStorage struct {
+	// Endpoint 는 오브젝트 스토리지의 host:port 이다. 예: s3.amazonaws.com, minio.minio:9000
+	Endpoint string `json:"endpoint"`
+
+	// Bucket 은 백업을 저장할 버킷이다
+	Bucket string `json:"bucket"`
+
+	// Prefix 는 오브젝트 이름 앞에 붙일 경로이다
+	Prefix string `json:"prefix,omitempty"`
+
+	// Region 은 버킷의 리전이다
+	Region string `json:"region,omitempty"`
+
+	// Insecure 가 true 이면 TLS 없이 접속한다. 로컬 MinIO 로 시험할 때 사용한다
+	Insecure bool `json:"insecure,omitempty"`
+
+	// CredentialsSecret 은 accessKeyID 와 secretAccessKey 키를 가진 시크릿이다
+	CredentialsSecret corev1.LocalObjectReference `json:"credentialsSecret"`
+}
+
+// BackupPhase 는 백업의 진행 단계이다
+type BackupPhase string
+
+const (
+	// BackupPhasePending 은 백업을 받을 파드를 기다리는 단계이다
+	BackupPhasePending BackupPhase = "Pending"
+	// BackupPhaseRunning 은 백업 잡이 백업을 받아 저장하고 있는 단계이다
+	BackupPhaseRunning BackupPhase = "Running"
+	// BackupPhaseCompleted 는 백업이 저장소에 저장된 단계이다
+	BackupPhaseCompleted BackupPhase = "Completed"
+	// BackupPhaseFailed 는 백업에 실패한 단계이다. 실패한 백업은 다시 시도하지 않는다
+	BackupPhaseFailed BackupPhase = "Failed"
+)
+
+// MySQLBackupStatus defines the observed state of MySQLBackup
+type MySQLBackupStatus struct {
+	// Phase 는 백업의 진행 단계이다
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// Message 는 현재 단계에 대한 설명이다
+	Message string `json:"message,omitempty"`
+
+	// SourcePod 는 백업을 받은 파드의 이름이다
+	SourcePod string `json:"sourcePod,omitempty"`
+
+	// ServerVersion 은 백업을 받을 때 동작하던 MySQL 서버의 버전이다
+	ServerVersion string `json:"serverVersion,omitempty"`
+
+	// Location 은 저장소에서 백업의 위치이다
+	Location string `json:"location,omitempty"`
+
+	// Size 는 저장된 백업의 크기이다
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// StartTime 은 백업을 시작한 시각이다
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime 은 백업이 완료된 시각이다
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// BinlogFile 은 백업 시점의 바이너리 로그 파일이다
+	BinlogFile string `json:"binlogFile,omitempty"`
+
+	// BinlogPosition 은 백업 시점의 바이너리 로그 위치이다
+	BinlogPosition int64 `json:"binlogPosition,omitempty"`
+
+	// GTIDSet 은 백업에 포함된 트랜잭션의 GTID 집합이다
+	GTIDSet string `json:"gtidSet,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// MySQLBackup is the Schema for the mysqlbackups API
+type MySQLBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MySQLBackupSpec   `json:"spec,omitempty"`
+	Status MySQLBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MySQLBackupList contains a list of MySQLBackup
+type MySQLBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MySQLBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MySQLBackup{}, &MySQLBackupList{})
+}
@@ -0,0 +1,108 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MySQLBackupScheduleSpec defines the desired state of MySQLBackupSchedule
+type MySQLBackupScheduleSpec struct {
+	// Schedule 은 백업을 받을 시각을 나타내는 cron 표현식이다. 예: "0 3 * * *" 는 매일 3시에 백업을 받는다
+	Schedule string `json:"schedule"`
+
+	// TimeZone 은 Schedule 과 보존 정책의 날짜를 해석할 IANA 시간대이다. 예: Asia/Seoul. 비어 있으면 UTC 를 사용한다
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Suspend 가 true 이면 새 백업을 만들지 않는다. 이미 만든 백업의 보존 정책은 계속 적용한다
+	Suspend bool `json:"suspend,omitempty"`
+
+	// StartingDeadlineSeconds 는 예정된 시각을 놓쳤을 때 늦게라도 백업을 시작할 수 있는 시간이다.
+	// 이 시간이 지나면 그 백업은 건너뛰고 ScheduleMissed 컨디션에 기록한다. 비어 있으면 기한 없이 늦게라도 시작한다
+	// +kubebuilder:validation:Minimum=0
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// Backup 은 예정된 시각마다 만들 MySQLBackup 의 spec 이다
+	Backup MySQLBackupSpec `json:"backup"`
+
+	// Retention 은 완료된 백업을 얼마나 보존할지 나타낸다. 보존하지 않는 백업은 저장소의 파일과 함께 삭제한다
+	Retention BackupRetention `json:"retention,omitempty"`
+}
+
+// BackupRetention 은 백업 보존 정책이다. 여러 규칙을 지정하면 하나라도 보존하라는 백업은 보존한다.
+// 아무 규칙도 지정하지 않으면 모든 백업을 보존한다
+type BackupRetention struct {
+	// KeepLast 는 가장 최근의 백업을 몇 개 보존할지 나타낸다
+	// +kubebuilder:validation:Minimum=0
+	KeepLast *int32 `json:"keepLast,omitempty"`
+
+	// KeepDaily 는 최근 며칠 동안 하루에 하나씩, 그날의 마지막 백업을 보존할지 나타낸다
+	// +kubebuilder:validation:Minimum=0
+	KeepDaily *int32 `json:"keepDaily,omitempty"`
+
+	// KeepWeekly 는 최근 몇 주 동안 한 주에 하나씩, 그 주의 마지막 백업을 보존할지 나타낸다
+	// +kubebuilder:validation:Minimum=0
+	KeepWeekly *int32 `json:"keepWeekly,omitempty"`
+}
+
+// MySQLBackupScheduleStatus defines the observed state of MySQLBackupSchedule
+type MySQLBackupScheduleStatus struct {
+	// Conditions represent the latest available observations of an object's state
+	Conditions condition.Conditions `json:"conditions,omitempty"`
+
+	// LastScheduleTime 은 마지막으로 처리한 예정 시각이다. 백업을 건너뛴 경우도 포함한다
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// LastSuccessfulTime 은 마지막으로 완료된 백업이 예정되었던 시각이다
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+
+	// LastBackup 은 마지막으로 만든 MySQLBackup 의 이름이다
+	LastBackup string `json:"lastBackup,omitempty"`
+}
+
+const (
+	// ConditionTypeScheduleMissed 는 예정된 백업을 만들지 못하고 건너뛰었는지를 나타낸다
+	ConditionTypeScheduleMissed condition.ConditionType = "ScheduleMissed"
+	// ConditionTypeBackupFailed 는 이 스케줄이 만든 가장 최근의 백업이 실패했는지를 나타낸다
+	ConditionTypeBackupFailed condition.ConditionType = "BackupFailed"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// MySQLBackupSchedule is the Schema for the mysqlbackupschedules API
+type MySQLBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MySQLBackupScheduleSpec   `json:"spec,omitempty"`
+	Status MySQLBackupScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MySQLBackupScheduleList contains a list of MySQLBackupSchedule
+type MySQLBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MySQLBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MySQLBackupSchedule{}, &MySQLBackupScheduleList{})
+}
@@ -0,0 +1,69 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MySQLCloneGrantSpec defines the desired state of MySQLCloneGrant
+type MySQLCloneGrantSpec struct {
+	// Cluster 는 다른 네임스페이스에서 복제할 수 있도록 허용할, 이 그랜트와 같은 네임스페이스에 있는 MySQL 의 이름이다
+	Cluster string `json:"cluster"`
+
+	// Namespaces 는 Cluster 를 복제하여 새 클러스터를 만들 수 있는 네임스페이스이다
+	// +kubebuilder:validation:MinItems=1
+	Namespaces []string `json:"namespaces"`
+}
+
+// +kubebuilder:object:root=true
+
+// MySQLCloneGrant is the Schema for the mysqlclonegrants API.
+// 다른 네임스페이스의 MySQL 이 spec.bootstrap.cloneFrom 으로 이 네임스페이스의 클러스터를 복제하려면 복제할 클러스터의
+// 네임스페이스에 그랜트가 있어야 한다. 복제한 클러스터는 원본의 모든 데이터와 계정을 가지므로 원본 네임스페이스의 소유자가 허용한다
+type MySQLCloneGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MySQLCloneGrantSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MySQLCloneGrantList contains a list of MySQLCloneGrant
+type MySQLCloneGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MySQLCloneGrant `json:"items"`
+}
+
+// Allows 는 그랜트가 namespace 에서 cluster 를 복제하도록 허용하는지 확인한다
+func (g *MySQLCloneGrant) Allows(cluster, namespace string) bool {
+	if g.Spec.Cluster != cluster {
+		return false
+	}
+	for _, ns := range g.Spec.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	SchemeBuilder.Register(&MySQLCloneGrant{}, &MySQLCloneGrantList{})
+}
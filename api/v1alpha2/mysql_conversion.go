@@ -1,31 +1,128 @@
 package v1alpha2
 
 import (
-	"errors"
-	"sample-mysql-operator/api/v1alpha1"
-	"sigs.k8s.io/controller-runtime/pkg/conversion"
+	"encoding/json"
+	"fmt"
 	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sample-mysql-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+const (
+	// conversionDataAnnotation 은 v1alpha2 로 표현할 수 없는 v1beta1 의 spec 과 status 이다. v1beta1 로 되돌릴 때 복원하고 지운다
+	conversionDataAnnotation = "mysql.sample.com/v1alpha2-conversion-data"
+	// ownerNameAnnotation 은 Owner.Name 을 나눠서는 되찾을 수 없는 v1alpha2 의 이름이다. 예: 이름에 공백이 있는 경우
+	ownerNameAnnotation = "mysql.sample.com/owner-name"
 )
 
-// ConvertTo 는 MySQL(v1alpha2)를 MySQL(v1alpha1)으로 변환한다
+// conversionData 는 v1alpha2 로 변환할 때 어노테이션에 보관하는 v1beta1 의 상태이다
+// +kubebuilder:object:generate=false
+type conversionData struct {
+	Spec   v1beta1.MySQLSpec   `json:"spec"`
+	Status v1beta1.MySQLStatus `json:"status"`
+}
+
+// ownerName 은 v1alpha2 의 오너 이름이다
+// +kubebuilder:object:generate=false
+type ownerName struct {
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+// ConvertTo 는 MySQL(v1alpha2)를 MySQL(v1beta1)으로 변환한다. 어노테이션에 보관한 v1beta1 의 상태를 복원한 뒤
+// v1alpha2 에서 바뀌었을 수 있는 필드를 덮어쓴다
 func (v1alpha2 *MySQL) ConvertTo(dstRaw conversion.Hub) error {
-	dst := dstRaw.(*v1alpha1.MySQL)
-	dst.ObjectMeta = v1alpha2.ObjectMeta
-	dst.Spec.Replicas = v1alpha2.Spec.Replicas
-	dst.Spec.OwnerName = v1alpha2.Spec.OwnerFirstName + " " + v1alpha2.Spec.OwnerLastName
+	dst := dstRaw.(*v1beta1.MySQL)
+	dst.ObjectMeta = *v1alpha2.ObjectMeta.DeepCopy()
+
+	var restored *conversionData
+	if raw, ok := dst.Annotations[conversionDataAnnotation]; ok {
+		restored = &conversionData{}
+		if err := json.Unmarshal([]byte(raw), restored); err != nil {
+			return fmt.Errorf("invalid %s annotation: %v", conversionDataAnnotation, err)
+		}
+		removeAnnotation(&dst.ObjectMeta, conversionDataAnnotation)
+		dst.Spec = restored.Spec
+		dst.Status = restored.Status
+	}
+
+	dst.Spec.Replication.Replicas = v1alpha2.Spec.Replicas
+	first, last := v1alpha2.Spec.OwnerFirstName, v1alpha2.Spec.OwnerLastName
+	// 이름이 바뀌지 않았으면 나눌 때 잃어버린 공백까지 원래 이름을 그대로 사용한다
+	dst.Spec.Owner.Name = joinOwnerName(first, last)
+	if restored != nil {
+		if f, l := splitOwnerName(restored.Spec.Owner.Name); f == first && l == last {
+			dst.Spec.Owner.Name = restored.Spec.Owner.Name
+		}
+	}
+	if f, l := splitOwnerName(dst.Spec.Owner.Name); f != first || l != last {
+		raw, err := json.Marshal(ownerName{FirstName: first, LastName: last})
+		if err != nil {
+			return err
+		}
+		setAnnotation(&dst.ObjectMeta, ownerNameAnnotation, string(raw))
+	}
+	dst.Status.Conditions = v1alpha2.Status.DeepCopy().Conditions
 	return nil
 }
 
-// ConvertFrom 은 MySQL(v1alpha1)을 MySQL(v1alpha2)로 변환한다
+// ConvertFrom 은 MySQL(v1beta1)을 MySQL(v1alpha2)로 변환한다. v1alpha2 로 표현할 수 없는 필드는 어노테이션에 보관한다
 func (v1alpha2 *MySQL) ConvertFrom(srcRaw conversion.Hub) error {
-	src := srcRaw.(*v1alpha1.MySQL)
-	v1alpha2.ObjectMeta = src.ObjectMeta
-	v1alpha2.Spec.Replicas = src.Spec.Replicas
-	name := strings.Split(src.Spec.OwnerName, " ")
-	if len(name) != 2 {
-		return errors.New("invalid name")
-	}
-	v1alpha2.Spec.OwnerFirstName = name[0]
-	v1alpha2.Spec.OwnerLastName = name[1]
+	src := srcRaw.(*v1beta1.MySQL)
+	v1alpha2.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	v1alpha2.Spec.Replicas = src.Spec.Replication.Replicas
+
+	first, last := splitOwnerName(src.Spec.Owner.Name)
+	if raw, ok := src.Annotations[ownerNameAnnotation]; ok {
+		name := ownerName{}
+		// 이름을 보관한 뒤 v1beta1 에서 Owner.Name 이 바뀌었으면 보관한 이름은 무시한다
+		if err := json.Unmarshal([]byte(raw), &name); err == nil && joinOwnerName(name.FirstName, name.LastName) == src.Spec.Owner.Name {
+			first, last = name.FirstName, name.LastName
+		}
+		removeAnnotation(&v1alpha2.ObjectMeta, ownerNameAnnotation)
+	}
+	v1alpha2.Spec.OwnerFirstName = first
+	v1alpha2.Spec.OwnerLastName = last
+	v1alpha2.Status.Conditions = src.Status.DeepCopy().Conditions
+
+	raw, err := json.Marshal(conversionData{Spec: src.Spec, Status: src.Status})
+	if err != nil {
+		return err
+	}
+	setAnnotation(&v1alpha2.ObjectMeta, conversionDataAnnotation, string(raw))
 	return nil
 }
+
+// splitOwnerName 은 첫 번째 공백을 기준으로 이름과 성을 나눈다. 공백이 없으면 모두 이름이다
+func splitOwnerName(name string) (string, string) {
+	i := strings.Index(name, " ")
+	if i < 0 {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
+
+// joinOwnerName 은 이름과 성을 공백으로 이어 붙인다. 성이 없으면 이름만 사용한다
+func joinOwnerName(first, last string) string {
+	if last == "" {
+		return first
+	}
+	return first + " " + last
+}
+
+func setAnnotation(meta *metav1.ObjectMeta, key, value string) {
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[key] = value
+}
+
+// removeAnnotation 은 어노테이션을 지우고, 남은 어노테이션이 없으면 변환 전처럼 비워둔다
+func removeAnnotation(meta *metav1.ObjectMeta, key string) {
+	delete(meta.Annotations, key)
+	if len(meta.Annotations) == 0 {
+		meta.Annotations = nil
+	}
+}
@@ -0,0 +1,180 @@
+package v1alpha2
+
+import (
+	"time"
+
+	fuzz "github.com/google/gofuzz"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+)
+
+var _ = Describe("mysql conversion", func() {
+	const rounds = 200
+
+	// newFuzzer 는 모든 필드를 채우는 퍼저를 만든다. 나중에 추가되는 필드도 별도 설정 없이 함께 검사된다.
+	// JSON 으로 그대로 되돌릴 수 없는 값은 API 서버가 허용하는 값만 만든다
+	newFuzzer := func(nilChance float64) *fuzz.Fuzzer {
+		return fuzz.New().NilChance(nilChance).NumElements(0, 3).Funcs(
+			// 변환은 TypeMeta 를 다루지 않는다
+			func(*metav1.TypeMeta, fuzz.Continue) {},
+			func(q *resource.Quantity, c fuzz.Continue) {
+				*q = *resource.NewQuantity(c.Int63n(1<<40), resource.BinarySI)
+			},
+			func(d *metav1.Duration, c fuzz.Continue) {
+				d.Duration = time.Duration(c.Int63n(int64(24 * time.Hour)))
+			},
+		)
+	}
+
+	expectEqual := func(expected, actual interface{}) {
+		Expect(equality.Semantic.DeepEqual(expected, actual)).Should(BeTrue(), diff.ObjectReflectDiff(expected, actual))
+	}
+
+	It("should round-trip v1beta1 through v1alpha2", func() {
+		for _, nilChance := range []float64{0, 0.5} {
+			f := newFuzzer(nilChance)
+			for i := 0; i < rounds; i++ {
+				hub := &v1beta1.MySQL{}
+				f.Fuzz(hub)
+
+				spoke := &MySQL{}
+				Expect(spoke.ConvertFrom(hub.DeepCopy())).Should(Succeed())
+				after := &v1beta1.MySQL{}
+				Expect(spoke.ConvertTo(after)).Should(Succeed())
+				expectEqual(hub, after)
+			}
+		}
+	})
+
+	It("should round-trip v1alpha2 through v1beta1", func() {
+		for _, nilChance := range []float64{0, 0.5} {
+			f := newFuzzer(nilChance)
+			for i := 0; i < rounds; i++ {
+				spoke := &MySQL{}
+				f.Fuzz(spoke)
+
+				hub := &v1beta1.MySQL{}
+				Expect(spoke.DeepCopy().ConvertTo(hub)).Should(Succeed())
+				after := &MySQL{}
+				Expect(after.ConvertFrom(hub)).Should(Succeed())
+				Expect(after.Annotations).Should(HaveKey(conversionDataAnnotation))
+				removeAnnotation(&after.ObjectMeta, conversionDataAnnotation)
+				expectEqual(spoke, after)
+			}
+		}
+	})
+
+	It("should round-trip v1alpha1 through v1beta1 and v1alpha2", func() {
+		for _, nilChance := range []float64{0, 0.5} {
+			f := newFuzzer(nilChance)
+			for i := 0; i < rounds; i++ {
+				alpha1 := &v1alpha1.MySQL{}
+				f.Fuzz(alpha1)
+
+				hub := &v1beta1.MySQL{}
+				Expect(alpha1.DeepCopy().ConvertTo(hub)).Should(Succeed())
+				spoke := &MySQL{}
+				Expect(spoke.ConvertFrom(hub.DeepCopy())).Should(Succeed())
+				after := &v1beta1.MySQL{}
+				Expect(spoke.ConvertTo(after)).Should(Succeed())
+				expectEqual(hub, after)
+
+				afterAlpha1 := &v1alpha1.MySQL{}
+				Expect(afterAlpha1.ConvertFrom(after)).Should(Succeed())
+				expectEqual(alpha1, afterAlpha1)
+			}
+		}
+	})
+
+	It("should keep the data of each version under its own annotation", func() {
+		hub := &v1beta1.MySQL{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: v1beta1.MySQLSpec{
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han", Team: "dba"},
+				PodTemplate: v1beta1.PodTemplateSpec{NodeSelector: map[string]string{"disktype": "ssd"}},
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+			},
+		}
+		alpha1 := &v1alpha1.MySQL{}
+		Expect(alpha1.ConvertFrom(hub)).Should(Succeed())
+		Expect(alpha1.Annotations).ShouldNot(HaveKey(conversionDataAnnotation))
+
+		By("converting a v1alpha2 object that carries the annotation of v1alpha1")
+		spoke := &MySQL{}
+		Expect(spoke.ConvertFrom(hub)).Should(Succeed())
+		for key, value := range alpha1.Annotations {
+			spoke.Annotations[key] = value
+		}
+		after := &v1beta1.MySQL{}
+		Expect(spoke.ConvertTo(after)).Should(Succeed())
+		expectEqual(hub.Spec, after.Spec)
+	})
+
+	It("should keep fields changed in v1alpha2 and the rest of v1beta1", func() {
+		size := resource.MustParse("10Gi")
+		hub := &v1beta1.MySQL{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: v1beta1.MySQLSpec{
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+				Server:      v1beta1.ServerSpec{Version: "8.0"},
+				Storage:     v1beta1.StorageSpec{Size: &size},
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+			},
+			Status: v1beta1.MySQLStatus{Primary: "sample-1"},
+		}
+		spoke := &MySQL{}
+		Expect(spoke.ConvertFrom(hub)).Should(Succeed())
+		Expect(spoke.Spec.OwnerFirstName).Should(Equal("woohyung"))
+		Expect(spoke.Spec.OwnerLastName).Should(Equal("han"))
+
+		spoke.Spec.Replicas = 5
+		spoke.Spec.OwnerLastName = "kim"
+		after := &v1beta1.MySQL{}
+		Expect(spoke.ConvertTo(after)).Should(Succeed())
+		Expect(after.Spec.Replication.Replicas).Should(Equal(int32(5)))
+		Expect(after.Spec.Owner.Name).Should(Equal("woohyung kim"))
+		Expect(after.Spec.Server.Version).Should(Equal("8.0"))
+		Expect(after.Spec.Storage.Size.String()).Should(Equal("10Gi"))
+		Expect(after.Status.Primary).Should(Equal("sample-1"))
+		Expect(after.Annotations).ShouldNot(HaveKey(conversionDataAnnotation))
+	})
+
+	It("should convert owner names that are not two words", func() {
+		for name, expected := range map[string][2]string{
+			"":                      {"", ""},
+			"Cher":                  {"Cher", ""},
+			"Jean Claude Van Damme": {"Jean", "Claude Van Damme"},
+			"woohyung  han":         {"woohyung", " han"},
+		} {
+			spoke := &MySQL{}
+			Expect(spoke.ConvertFrom(&v1beta1.MySQL{Spec: v1beta1.MySQLSpec{Owner: v1beta1.OwnerSpec{Name: name}}})).Should(Succeed())
+			Expect([2]string{spoke.Spec.OwnerFirstName, spoke.Spec.OwnerLastName}).Should(Equal(expected), name)
+		}
+	})
+
+	It("should keep first names with spaces in an annotation", func() {
+		spoke := &MySQL{Spec: MySQLSpec{OwnerFirstName: "Mary Ann", OwnerLastName: "Smith"}}
+		hub := &v1beta1.MySQL{}
+		Expect(spoke.ConvertTo(hub)).Should(Succeed())
+		Expect(hub.Spec.Owner.Name).Should(Equal("Mary Ann Smith"))
+		Expect(hub.Annotations).Should(HaveKey(ownerNameAnnotation))
+
+		after := &MySQL{}
+		Expect(after.ConvertFrom(hub)).Should(Succeed())
+		Expect(after.Spec.OwnerFirstName).Should(Equal("Mary Ann"))
+		Expect(after.Spec.OwnerLastName).Should(Equal("Smith"))
+		Expect(after.Annotations).ShouldNot(HaveKey(ownerNameAnnotation))
+
+		By("ignoring the annotation once the name is changed in v1beta1")
+		hub.Spec.Owner.Name = "John Smith"
+		Expect(after.ConvertFrom(hub)).Should(Succeed())
+		Expect(after.Spec.OwnerFirstName).Should(Equal("John"))
+		Expect(after.Spec.OwnerLastName).Should(Equal("Smith"))
+	})
+})
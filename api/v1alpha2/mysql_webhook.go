@@ -0,0 +1,134 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"encoding/json"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var mysqllog = logf.Log.WithName("mysql-v1alpha2-resource")
+
+// SetupWebhookWithManager setup new webhook
+func (r *MySQL) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-mysql-sample-com-v1alpha2-mysql,mutating=true,failurePolicy=fail,groups=mysql.sample.com,resources=mysqls,verbs=create;update,versions=v1alpha2,name=mmysqlv1alpha2.kb.io
+
+var _ webhook.Defaulter = &MySQL{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *MySQL) Default() {
+	mysqllog.Info("----- Start Default()", "name", r.Name)
+
+	r.Spec.OwnerFirstName = strings.TrimSpace(r.Spec.OwnerFirstName)
+	r.Spec.OwnerLastName = strings.TrimSpace(r.Spec.OwnerLastName)
+	// 오너 이름이 설정되어 있지 않으면 v1alpha1 과 같이 no body 로 설정한다
+	if r.Spec.OwnerFirstName == "" && r.Spec.OwnerLastName == "" {
+		r.Spec.OwnerFirstName = "no"
+		r.Spec.OwnerLastName = "body"
+	}
+	// v1alpha2 에는 삭제 보호 필드가 없으므로 어노테이션에 보관하는 v1beta1 의 스펙에 설정한다
+	if r.CreationTimestamp.IsZero() {
+		hub := &v1beta1.MySQL{}
+		// 어노테이션이 잘못된 경우는 validateMysql 이 거부한다
+		if err := r.ConvertTo(hub); err == nil && hub.Spec.DeletionProtection == nil {
+			protected := true
+			hub.Spec.DeletionProtection = &protected
+			if err := r.ConvertFrom(hub); err != nil {
+				mysqllog.Error(err, "failed to default deletion protection", "name", r.Name)
+			}
+		}
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-mysql-sample-com-v1alpha2-mysql,mutating=false,failurePolicy=fail,groups=mysql.sample.com,resources=mysqls,versions=v1alpha2,name=vmysqlv1alpha2.kb.io
+
+var _ webhook.Validator = &MySQL{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *MySQL) ValidateCreate() error {
+	mysqllog.Info("----- Start ValidateCreate()", "name", r.Name)
+	if err := validateMysql(r); err != nil {
+		return err
+	}
+	hub := &v1beta1.MySQL{}
+	if err := r.ConvertTo(hub); err != nil {
+		return err
+	}
+	return hub.ValidateCreate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// 어노테이션에 보관된 필드도 함께 바뀔 수 있으므로 두 오브젝트를 v1beta1 으로 변환하여 v1beta1 의 규칙으로 확인한다
+func (r *MySQL) ValidateUpdate(old runtime.Object) error {
+	mysqllog.Info("----- Start ValidateUpdate()", "name", r.Name)
+	if err := validateMysql(r); err != nil {
+		return err
+	}
+	hub, oldHub := &v1beta1.MySQL{}, &v1beta1.MySQL{}
+	if err := r.ConvertTo(hub); err != nil {
+		return err
+	}
+	if err := old.(*MySQL).ConvertTo(oldHub); err != nil {
+		return err
+	}
+	return hub.ValidateUpdate(oldHub)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *MySQL) ValidateDelete() error {
+	hub := &v1beta1.MySQL{}
+	if err := r.ConvertTo(hub); err != nil {
+		return err
+	}
+	return hub.ValidateDelete()
+}
+
+func validateMysql(mysql *MySQL) error {
+	var allErrs field.ErrorList
+	path := field.NewPath("spec")
+	if strings.TrimSpace(mysql.Spec.OwnerFirstName) == "" {
+		allErrs = append(allErrs, field.Required(path.Child("ownerFirstName"), "owner first name must not be empty"))
+	}
+	if strings.TrimSpace(mysql.Spec.OwnerLastName) == "" {
+		allErrs = append(allErrs, field.Required(path.Child("ownerLastName"), "owner last name must not be empty"))
+	}
+	// 변환할 때 v1beta1 의 필드를 복원하지 못하면 저장할 수 없다
+	if raw, ok := mysql.Annotations[conversionDataAnnotation]; ok {
+		if err := json.Unmarshal([]byte(raw), &conversionData{}); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(conversionDataAnnotation), raw, err.Error()))
+		}
+	}
+	if len(allErrs) != 0 {
+		return apierrors.NewInvalid(schema.GroupKind{Group: "mysql.sample.com", Kind: "MySQL"}, mysql.Name, allErrs)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package v1alpha2
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sample-mysql-operator/api/v1beta1"
+)
+
+var _ = Describe("mysql webhook", func() {
+	newMySQL := func(first, last string) *MySQL {
+		return &MySQL{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec:       MySQLSpec{Replicas: 1, OwnerFirstName: first, OwnerLastName: last},
+		}
+	}
+
+	It("should default an empty owner like v1alpha1", func() {
+		mysql := newMySQL("", " ")
+		mysql.Default()
+		Expect(mysql.Spec.OwnerFirstName).Should(Equal("no"))
+		Expect(mysql.Spec.OwnerLastName).Should(Equal("body"))
+		Expect(mysql.ValidateCreate()).Should(Succeed())
+	})
+
+	It("should trim the owner names", func() {
+		mysql := newMySQL(" woohyung ", "han ")
+		mysql.Default()
+		Expect(mysql.Spec.OwnerFirstName).Should(Equal("woohyung"))
+		Expect(mysql.Spec.OwnerLastName).Should(Equal("han"))
+	})
+
+	It("should require both first and last names", func() {
+		Expect(newMySQL("woohyung", "han").ValidateCreate()).Should(Succeed())
+		Expect(newMySQL("Mary Ann", "Smith").ValidateCreate()).Should(Succeed())
+		Expect(newMySQL("woohyung", "").ValidateCreate()).ShouldNot(Succeed())
+		Expect(newMySQL("", "han").ValidateUpdate(newMySQL("woohyung", "han"))).ShouldNot(Succeed())
+		Expect(newMySQL(" ", "han").ValidateCreate()).ShouldNot(Succeed())
+	})
+
+	It("should reject a broken conversion data annotation", func() {
+		mysql := newMySQL("woohyung", "han")
+		mysql.Annotations = map[string]string{conversionDataAnnotation: "{"}
+		err := mysql.ValidateUpdate(newMySQL("woohyung", "han"))
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring(conversionDataAnnotation))
+	})
+
+	It("should validate updates with the rules of v1beta1", func() {
+		old := newMySQL("woohyung", "han")
+		old.Spec.Replicas = 3
+		old.Status.Conditions.SetCondition(condition.Condition{Type: v1beta1.ConditionTypeBackingUp, Status: corev1.ConditionTrue, Reason: "BackupRunning"})
+		mysql := old.DeepCopy()
+		mysql.Spec.Replicas = 2
+		err := mysql.ValidateUpdate(old)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.replication.replicas: Forbidden: can not be changed while BackingUp is in progress"))
+
+		By("changing the v1beta1 fields kept in the annotation")
+		hub := &v1beta1.MySQL{}
+		Expect(old.ConvertTo(hub)).Should(Succeed())
+		hub.Spec.Server.Version = "5.7"
+		Expect(old.ConvertFrom(hub)).Should(Succeed())
+		hub.Spec.Server.Version = "5.6"
+		Expect(mysql.ConvertFrom(hub)).Should(Succeed())
+		err = mysql.ValidateUpdate(old)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.server.version: Forbidden: downgrade from 5.7 to 5.6"))
+	})
+
+	It("should protect new clusters from deletion through the v1beta1 fields", func() {
+		mysql := newMySQL("woohyung", "han")
+		mysql.Default()
+		Expect(mysql.Annotations).Should(HaveKey(conversionDataAnnotation))
+		Expect(mysql.ValidateCreate()).Should(Succeed())
+		err := mysql.ValidateDelete()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.deletionProtection"))
+
+		By("deleting a cluster created before deletion protection")
+		Expect(newMySQL("woohyung", "han").ValidateDelete()).Should(Succeed())
+	})
+})
@@ -0,0 +1,16 @@
+package v1alpha2
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/reporters"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestV1alpha2(t *testing.T) {
+	RegisterFailHandler(Fail)
+	junitReporter := reporters.NewJUnitReporter(fmt.Sprintf("../../unit-v1alpha2-ginkgo-junit_%d.xml", config.GinkgoConfig.ParallelNode))
+	RunSpecsWithDefaultAndCustomReporters(t, "API v1alpha2 Suite", []Reporter{junitReporter})
+}
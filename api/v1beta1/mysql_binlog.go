@@ -0,0 +1,27 @@
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateBinlogArchive 는 바이너리 로그를 보관할 저장소와 주기를 확인한다
+func validateBinlogArchive(mysql *MySQL) field.ErrorList {
+	archive := mysql.Spec.Backup.BinlogArchive
+	if archive == nil {
+		return nil
+	}
+	path := field.NewPath("spec").Child("backup").Child("binlogArchive")
+	allErrs := validateBackupStorage(&archive.Storage, path.Child("storage"))
+	if archive.Interval != nil && archive.Interval.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("interval"), archive.Interval.Duration.String(), "interval must be positive"))
+	}
+	return allErrs
+}
+
+// validateBackupStorage 는 저장소가 하나만 지정되었는지 확인한다
+func validateBackupStorage(storage *BackupStorage, path *field.Path) field.ErrorList {
+	if (storage.PersistentVolumeClaim == nil) == (storage.S3 == nil) {
+		return field.ErrorList{field.Invalid(path, "", "exactly one of persistentVolumeClaim and s3 must be specified")}
+	}
+	return nil
+}
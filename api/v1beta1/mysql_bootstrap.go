@@ -0,0 +1,60 @@
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateBootstrap 은 초기 데이터를 가져올 곳이 하나만 지정되었는지 확인한다
+func validateBootstrap(mysql *MySQL) field.ErrorList {
+	bootstrap := mysql.Spec.Bootstrap
+	if bootstrap == nil || (bootstrap.FromBackup == nil && bootstrap.CloneFrom == nil) {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if mysql.Spec.Server.RootPasswordSecretRef == nil {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec").Child("server").Child("rootPasswordSecretRef"),
+			"the root password of the source cluster is required to bootstrap from its data"))
+	}
+	if bootstrap.FromBackup != nil && bootstrap.CloneFrom != nil {
+		return append(allErrs, field.Invalid(field.NewPath("spec").Child("bootstrap"), "", "only one of fromBackup and cloneFrom can be specified"))
+	}
+	if clone := bootstrap.CloneFrom; clone != nil {
+		path := field.NewPath("spec").Child("bootstrap").Child("cloneFrom")
+		if clone.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("name"), "name is required"))
+		}
+		if clone.Name == mysql.Name && (clone.Namespace == "" || clone.Namespace == mysql.Namespace) {
+			allErrs = append(allErrs, field.Invalid(path.Child("name"), clone.Name, "a cluster can not be cloned from itself"))
+		}
+		return allErrs
+	}
+	path := field.NewPath("spec").Child("bootstrap").Child("fromBackup")
+	backup := mysql.Spec.Bootstrap.FromBackup
+	switch {
+	case backup.Name != "" && (backup.Storage != nil || backup.Key != ""):
+		allErrs = append(allErrs, field.Invalid(path, backup.Name, "only one of name and storage can be specified"))
+	case backup.Name == "" && backup.Storage == nil:
+		allErrs = append(allErrs, field.Required(path, "one of name and storage must be specified"))
+	case backup.Storage != nil:
+		if backup.Key == "" {
+			allErrs = append(allErrs, field.Required(path.Child("key"), "key is required with storage"))
+		}
+		allErrs = append(allErrs, validateBackupStorage(backup.Storage, path.Child("storage"))...)
+		if backup.PointInTime != nil && backup.PointInTime.BinlogPrefix == "" {
+			allErrs = append(allErrs, field.Required(path.Child("pointInTime").Child("binlogPrefix"), "binlogPrefix is required with storage"))
+		}
+	}
+	if pitr := backup.PointInTime; pitr != nil && (pitr.Time == nil) == (pitr.GTIDSet == "") {
+		allErrs = append(allErrs, field.Invalid(path.Child("pointInTime"), "", "exactly one of time and gtidSet must be specified"))
+	}
+	return allErrs
+}
+
+// validateBootstrapUpdate 는 클러스터를 시작한 뒤에는 초기 데이터를 바꿀 수 없으므로 Bootstrap 변경을 막는다
+func validateBootstrapUpdate(mysql, old *MySQL) field.ErrorList {
+	if equality.Semantic.DeepEqual(mysql.Spec.Bootstrap, old.Spec.Bootstrap) {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(field.NewPath("spec").Child("bootstrap"), "bootstrap can not be changed after the cluster is created")}
+}
@@ -0,0 +1,4 @@
+package v1beta1
+
+// Hub indicates this version is hub version
+func (*MySQL) Hub() {}
@@ -0,0 +1,35 @@
+package v1beta1
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// IsDeletionProtected 는 삭제가 막혀 있는지 확인한다. 웹훅이 기본값을 설정하기 전에 만들어진 클러스터는 보호되지 않는다
+func (s *MySQLSpec) IsDeletionProtected() bool {
+	return s.DeletionProtection != nil && *s.DeletionProtection
+}
+
+// validateDeletionPolicy 는 BackupThenDelete 정책에 마지막 백업을 저장할 곳이 지정되었는지 확인한다
+func validateDeletionPolicy(mysql *MySQL) field.ErrorList {
+	path := field.NewPath("spec").Child("backup").Child("finalBackupStorage")
+	if mysql.Spec.Backup.FinalBackupStorage != nil {
+		return validateBackupStorage(mysql.Spec.Backup.FinalBackupStorage, path)
+	}
+	if mysql.Spec.DeletionPolicy == DeletionPolicyBackupThenDelete {
+		return field.ErrorList{field.Required(path, "backup.finalBackupStorage is required for the BackupThenDelete deletion policy")}
+	}
+	return nil
+}
+
+// validateDeletionProtection 은 삭제가 막혀 있으면 삭제 요청을 거부한다
+func validateDeletionProtection(mysql *MySQL) error {
+	if !mysql.Spec.IsDeletionProtected() {
+		return nil
+	}
+	return apierrors.NewForbidden(schema.GroupResource{Group: "mysql.sample.com", Resource: "mysqls"}, mysql.Name,
+		fmt.Errorf("deletion protection is enabled. Set spec.deletionProtection to false before deleting"))
+}
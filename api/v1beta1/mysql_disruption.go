@@ -0,0 +1,41 @@
+package v1beta1
+
+import (
+	"fmt"
+
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateDisruptiveUpdate 는 업그레이드나 백업이 진행 중일 때 파드를 재시작하거나 줄이는 변경을 막는다.
+// 진행 중인 작업은 컨트롤러가 기록한 컨디션으로 판단한다
+func validateDisruptiveUpdate(mysql, old *MySQL) field.ErrorList {
+	reason := ""
+	for _, t := range []condition.ConditionType{ConditionTypeUpgrading, ConditionTypeBackingUp} {
+		if old.Status.Conditions.IsTrueFor(t) {
+			reason = fmt.Sprintf("can not be changed while %s is in progress", t)
+			break
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	spec := field.NewPath("spec")
+	if !equality.Semantic.DeepEqual(mysql.Spec.Server, old.Spec.Server) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("server"), reason))
+	}
+	if !equality.Semantic.DeepEqual(mysql.Spec.PodTemplate, old.Spec.PodTemplate) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("podTemplate"), reason))
+	}
+	// 레플리카를 늘리는 것은 기존 파드에 영향을 주지 않는다
+	if mysql.Spec.Replication.Replicas < old.Spec.Replication.Replicas {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("replication").Child("replicas"), reason))
+	}
+	if target := mysql.SwitchoverTarget(); target != "" && target != old.SwitchoverTarget() {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("metadata").Child("annotations").Key(SwitchoverAnnotation), reason))
+	}
+	return allErrs
+}
@@ -0,0 +1,126 @@
+package v1beta1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// OwnerAnnotation 은 오퍼레이터가 만든 리소스에 기록하는 소유자 이름이다. 이름에는 공백이 있으므로 레이블로 쓸 수 없다
+	OwnerAnnotation = "mysql.sample.com/owner"
+	// OwnerLabel 은 소유자로 리소스를 고를 수 있도록 OwnerLabelValue 로 바꾼 소유자 이름을 붙이는 레이블이다
+	OwnerLabel = "mysql.sample.com/owner"
+	// TeamLabel 은 오퍼레이터가 만든 리소스에 붙이는 팀 레이블이다
+	TeamLabel = "mysql.sample.com/team"
+	// CostCenterLabel 은 오퍼레이터가 만든 리소스에 붙이는 코스트 센터 레이블이다
+	CostCenterLabel = "mysql.sample.com/cost-center"
+)
+
+// OwnerLabels 는 소유자 정보로 만든 레이블이다. 비어 있는 값은 포함하지 않는다
+func (s *MySQLSpec) OwnerLabels() map[string]string {
+	labels := map[string]string{}
+	if s.Owner.Name != "" {
+		labels[OwnerLabel] = OwnerLabelValue(s.Owner.Name)
+	}
+	if s.Owner.Team != "" {
+		labels[TeamLabel] = s.Owner.Team
+	}
+	if s.Owner.CostCenter != "" {
+		labels[CostCenterLabel] = s.Owner.CostCenter
+	}
+	return labels
+}
+
+// OwnerAnnotations 는 소유자 정보로 만든 어노테이션이다. 비어 있는 값은 포함하지 않는다
+func (s *MySQLSpec) OwnerAnnotations() map[string]string {
+	annotations := map[string]string{}
+	if s.Owner.Name != "" {
+		annotations[OwnerAnnotation] = s.Owner.Name
+	}
+	return annotations
+}
+
+// OwnerLabelValue 는 소유자 이름을 레이블 값으로 쓸 수 있도록 영문자와 숫자는 소문자로 남기고 나머지 문자는 하이픈으로 바꾼다.
+// 한글처럼 ASCII 가 아닌 문자가 있으면 다른 이름과 겹치지 않도록 이름의 해시를 덧붙인다. 예: "Woohyung Han" 은 "woohyung-han" 이 된다
+func OwnerLabelValue(name string) string {
+	var b strings.Builder
+	ascii := true
+	for _, r := range strings.ToLower(name) {
+		if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') {
+			b.WriteRune(r)
+			continue
+		}
+		if r > unicode.MaxASCII {
+			ascii = false
+		}
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "-") {
+			b.WriteByte('-')
+		}
+	}
+	value := b.String()
+	max := validation.LabelValueMaxLength
+	var hash string
+	if !ascii {
+		sum := sha256.Sum256([]byte(name))
+		hash = hex.EncodeToString(sum[:])[:8]
+		max -= len(hash) + 1
+	}
+	if len(value) > max {
+		value = value[:max]
+	}
+	value = strings.TrimSuffix(value, "-")
+	if hash == "" {
+		return value
+	}
+	if value == "" {
+		return hash
+	}
+	return value + "-" + hash
+}
+
+// NormalizeOwnerName 은 앞뒤 공백을 지우고 단어 사이의 연속된 공백을 하나의 스페이스로 바꾼다.
+// 전각 공백과 같은 유니코드 공백도 단어 구분으로 취급한다
+func NormalizeOwnerName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// ValidateOwnerName 은 이름이 한 칸씩 띄어 쓴 두 단어 이상으로 이루어졌는지 확인한다.
+// 한글 등 ASCII 가 아닌 문자도 허용하며, 단어에는 문자와 결합 부호, 하이픈, 마침표, 아포스트로피만 사용할 수 있다
+func ValidateOwnerName(name string, path *field.Path) *field.Error {
+	words := strings.Fields(name)
+	if len(words) < 2 || strings.Join(words, " ") != name {
+		return field.Invalid(path, name, "owner name must be form of [first name] [last name] separated by a single space")
+	}
+	for _, word := range words {
+		for _, r := range word {
+			if !unicode.IsLetter(r) && !unicode.IsMark(r) && !strings.ContainsRune("-.'", r) {
+				return field.Invalid(path, name, "owner name must consist of letters, hyphens, periods or apostrophes")
+			}
+		}
+	}
+	return nil
+}
+
+// validateOwner 는 소유자 이름과 레이블로 사용할 팀, 코스트 센터를 확인한다. 이름 규칙이 생기기 전에 만든 클러스터도
+// 수정하거나 삭제할 수 있도록 이름은 생성할 때와 바뀔 때만 확인한다. 생성할 때는 old 가 nil 이다
+func validateOwner(owner, old *OwnerSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	path := field.NewPath("spec").Child("owner")
+	if old == nil || owner.Name != old.Name {
+		if err := ValidateOwnerName(owner.Name, path.Child("name")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	for _, msg := range validation.IsValidLabelValue(owner.Team) {
+		allErrs = append(allErrs, field.Invalid(path.Child("team"), owner.Team, msg))
+	}
+	for _, msg := range validation.IsValidLabelValue(owner.CostCenter) {
+		allErrs = append(allErrs, field.Invalid(path.Child("costCenter"), owner.CostCenter, msg))
+	}
+	return allErrs
+}
@@ -0,0 +1,28 @@
+package v1beta1
+
+import (
+	"strings"
+
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// reservedKeyPrefix 는 오퍼레이터가 사용하는 레이블과 어노테이션의 접두사이다
+const reservedKeyPrefix = "mysql.sample.com/"
+
+// validatePodTemplate 은 파드에 추가할 레이블과 어노테이션이 오퍼레이터가 사용하는 키를 덮어쓰지 않는지 확인한다
+func validatePodTemplate(template *PodTemplateSpec) field.ErrorList {
+	path := field.NewPath("spec").Child("podTemplate").Child("metadata")
+	allErrs := metav1validation.ValidateLabels(template.Metadata.Labels, path.Child("labels"))
+	for key := range template.Metadata.Labels {
+		if key == "app" || strings.HasPrefix(key, reservedKeyPrefix) {
+			allErrs = append(allErrs, field.Forbidden(path.Child("labels").Key(key), "label is managed by the operator"))
+		}
+	}
+	for key := range template.Metadata.Annotations {
+		if strings.HasPrefix(key, reservedKeyPrefix) {
+			allErrs = append(allErrs, field.Forbidden(path.Child("annotations").Key(key), "annotation is managed by the operator"))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,25 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DefaultStorageSize 는 Storage.Size 가 지정되지 않았을 때 사용하는 볼륨 크기이다
+const DefaultStorageSize = "2Gi"
+
+// StorageSize 는 데이터 볼륨의 크기를 반환한다. Size 가 비어 있으면 DefaultStorageSize 를 반환한다
+func (s *MySQLSpec) StorageSize() resource.Quantity {
+	if s.Storage.Size == nil {
+		return resource.MustParse(DefaultStorageSize)
+	}
+	return s.Storage.Size.DeepCopy()
+}
+
+// StorageAccessModes 는 데이터 볼륨의 접근 모드를 반환한다. AccessModes 가 비어 있으면 ReadWriteOnce 를 반환한다
+func (s *MySQLSpec) StorageAccessModes() []corev1.PersistentVolumeAccessMode {
+	if len(s.Storage.AccessModes) == 0 {
+		return []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	return s.Storage.AccessModes
+}
@@ -0,0 +1,32 @@
+package v1beta1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// SwitchoverAnnotation 은 프라이머리를 옮길 파드의 이름이다. 오퍼레이터는 전환을 마치면 이 어노테이션을 제거한다
+const SwitchoverAnnotation = "mysql.sample.com/switchover-to"
+
+// SwitchoverTarget 은 프라이머리를 옮기도록 요청된 파드의 이름을 반환한다. 요청이 없으면 빈 문자열을 반환한다
+func (in *MySQL) SwitchoverTarget() string {
+	return in.Annotations[SwitchoverAnnotation]
+}
+
+// validateSwitchover 는 전환 대상이 클러스터의 파드인지 확인한다
+func validateSwitchover(mysql *MySQL) *field.Error {
+	target := mysql.SwitchoverTarget()
+	if target == "" {
+		return nil
+	}
+	path := field.NewPath("metadata").Child("annotations").Key(SwitchoverAnnotation)
+	prefix := mysql.Name + "-"
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(target, prefix))
+	if !strings.HasPrefix(target, prefix) || err != nil || ordinal < 0 || ordinal >= int(mysql.Spec.Replication.Replicas) {
+		return field.Invalid(path, target, fmt.Sprintf("must be the name of a pod from %s0 to %s%d", prefix, prefix, mysql.Spec.Replication.Replicas-1))
+	}
+	return nil
+}
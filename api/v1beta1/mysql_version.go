@@ -0,0 +1,128 @@
+package v1beta1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// DefaultVersion 은 Version 이 지정되지 않았을 때 사용하는 MySQL 버전이다
+const DefaultVersion = "5.7"
+
+// SupportedVersions 는 오퍼레이터가 지원하는 MySQL 메이저 버전 목록이다. 오래된 버전부터 순서대로 나열한다
+var SupportedVersions = []string{"5.6", "5.7", "8.0"}
+
+// ServerVersion 은 MySQL 서버 버전을 반환한다. Version 이 비어 있으면 DefaultVersion 을 반환한다
+func (s *MySQLSpec) ServerVersion() string {
+	if s.Server.Version == "" {
+		return DefaultVersion
+	}
+	return s.Server.Version
+}
+
+// ServerImage 는 MySQL 서버 컨테이너가 사용할 이미지를 반환한다
+func (s *MySQLSpec) ServerImage() string {
+	if s.Server.Image != "" {
+		return s.Server.Image
+	}
+	return "mysql:" + s.ServerVersion()
+}
+
+// MajorVersion 은 5.7.31 과 같은 버전에서 메이저 버전(5.7)을 반환한다
+func MajorVersion(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// IsSupportedVersion 은 해당 버전을 지원하는지 확인한다
+func IsSupportedVersion(version string) bool {
+	if _, err := parseVersion(version); err != nil {
+		return false
+	}
+	major := MajorVersion(version)
+	for _, v := range SupportedVersions {
+		if v == major {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareVersions 는 두 버전을 비교하여 a 가 낮으면 -1, 같으면 0, 높으면 1 을 반환한다.
+// 패치 버전이 없는 버전(예: 5.7)은 해당 메이저 버전의 가장 낮은 패치 버전으로 취급한다
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1, nil
+		case va[i] > vb[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// IsUpgradePathSupported 는 메이저 버전을 건너뛰지 않는 업그레이드인지 확인한다
+func IsUpgradePathSupported(current, target string) bool {
+	from, to := -1, -1
+	for i, v := range SupportedVersions {
+		if v == MajorVersion(current) {
+			from = i
+		}
+		if v == MajorVersion(target) {
+			to = i
+		}
+	}
+	return from >= 0 && to >= 0 && to-from <= 1
+}
+
+// validateVersionUpdate 는 버전을 낮추거나 메이저 버전을 건너뛰는 변경을 막는다.
+// 업그레이드가 시작되기 전이라면 실제로 동작하고 있는 버전으로 되돌려 업그레이드를 취소할 수 있다
+func validateVersionUpdate(mysql, old *MySQL) *field.Error {
+	current, target := old.Spec.ServerVersion(), mysql.Spec.ServerVersion()
+	if current == target || target == old.Status.Version {
+		return nil
+	}
+	// 지원하지 않는 버전은 validateVersion 이 확인한다
+	cmp, err := CompareVersions(target, current)
+	if err != nil {
+		return nil
+	}
+	path := field.NewPath("spec").Child("server").Child("version")
+	if cmp < 0 {
+		return field.Forbidden(path, fmt.Sprintf("downgrade from %s to %s is not supported", current, target))
+	}
+	if !IsUpgradePathSupported(current, target) {
+		return field.Forbidden(path, fmt.Sprintf("upgrade from %s to %s must go through every major version in between", current, target))
+	}
+	return nil
+}
+
+func parseVersion(version string) ([3]int, error) {
+	var v [3]int
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", version)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", version)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
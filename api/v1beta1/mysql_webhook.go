@@ -0,0 +1,191 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+var configKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// log is for logging in this package.
+var mysqllog = logf.Log.WithName("mysql-v1beta1-resource")
+
+// SetupWebhookWithManager setup new webhook
+func (r *MySQL) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-mysql-sample-com-v1beta1-mysql,mutating=true,failurePolicy=fail,groups=mysql.sample.com,resources=mysqls,verbs=create;update,versions=v1beta1,name=mmysqlv1beta1.kb.io
+
+var _ webhook.Defaulter = &MySQL{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *MySQL) Default() {
+	mysqllog.Info("----- Start Default()", "name", r.Name)
+
+	// 오너 이름이 설정되어 있지 않으면 no body로 설정한다
+	r.Spec.Owner.Name = NormalizeOwnerName(r.Spec.Owner.Name)
+	if r.Spec.Owner.Name == "" {
+		r.Spec.Owner.Name = "no body"
+	}
+	// 버전이 설정되어 있지 않으면 기본 버전을 사용한다
+	if r.Spec.Server.Version == "" {
+		r.Spec.Server.Version = DefaultVersion
+	}
+	// 삭제 정책이 설정되어 있지 않으면 데이터를 남겨둔다
+	if r.Spec.DeletionPolicy == "" {
+		r.Spec.DeletionPolicy = DeletionPolicyRetain
+	}
+	// 새 클러스터는 실수로 삭제되지 않도록 보호한다. 기존 클러스터는 설정을 바꾸지 않는다
+	if r.Spec.DeletionProtection == nil && r.CreationTimestamp.IsZero() {
+		protected := true
+		r.Spec.DeletionProtection = &protected
+	}
+	// 레플리카 수를 줄일 때도 데이터를 남겨둔다
+	if r.Spec.Storage.ScaleDownPVCPolicy == "" {
+		r.Spec.Storage.ScaleDownPVCPolicy = ScaleDownPVCPolicyRetain
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-mysql-sample-com-v1beta1-mysql,mutating=false,failurePolicy=fail,groups=mysql.sample.com,resources=mysqls,versions=v1beta1,name=vmysqlv1beta1.kb.io
+
+var _ webhook.Validator = &MySQL{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *MySQL) ValidateCreate() error {
+	mysqllog.Info("----- Start ValidateCreate()", "name", r.Name)
+	return validateMysql(r, nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *MySQL) ValidateUpdate(old runtime.Object) error {
+	mysqllog.Info("----- Start ValidateUpdate()", "name", r.Name)
+	oldMySQL := old.(*MySQL)
+	if err := validateMysql(r, oldMySQL); err != nil {
+		return err
+	}
+	allErrs := validateStorageUpdate(r, oldMySQL)
+	allErrs = append(allErrs, validateBootstrapUpdate(r, oldMySQL)...)
+	if err := validateVersionUpdate(r, oldMySQL); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	allErrs = append(allErrs, validateDisruptiveUpdate(r, oldMySQL)...)
+	if len(allErrs) != 0 {
+		return apierrors.NewInvalid(schema.GroupKind{Group: "mysql.sample.com", Kind: "MySQL"}, r.Name, allErrs)
+	}
+	return nil
+}
+
+// validateMysql 은 생성과 수정에 공통인 규칙을 확인한다. 생성할 때는 old 가 nil 이다
+func validateMysql(mysql, old *MySQL) error {
+	var oldOwner *OwnerSpec
+	if old != nil {
+		oldOwner = &old.Spec.Owner
+	}
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, validateOwner(&mysql.Spec.Owner, oldOwner)...)
+	if err := validateVersion(mysql.Spec.Server.Version); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if err := validateSwitchover(mysql); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	allErrs = append(allErrs, validateBootstrap(mysql)...)
+	allErrs = append(allErrs, validateBinlogArchive(mysql)...)
+	allErrs = append(allErrs, validateDeletionPolicy(mysql)...)
+	allErrs = append(allErrs, validateConfig(mysql.Spec.Server.Config.Primary, field.NewPath("spec").Child("server").Child("config").Child("primary"))...)
+	allErrs = append(allErrs, validateConfig(mysql.Spec.Server.Config.Replica, field.NewPath("spec").Child("server").Child("config").Child("replica"))...)
+	allErrs = append(allErrs, validatePodTemplate(&mysql.Spec.PodTemplate)...)
+	if len(allErrs) != 0 {
+		return apierrors.NewInvalid(schema.GroupKind{Group: "mysql.sample.com", Kind: "MySQL"}, mysql.Name, allErrs)
+	}
+	return nil
+}
+
+func validateVersion(version string) *field.Error {
+	// 비어 있는 경우 기본 버전을 사용한다
+	if version == "" {
+		return nil
+	}
+	if !IsSupportedVersion(version) {
+		return field.NotSupported(field.NewPath("spec").Child("server").Child("version"), version, SupportedVersions)
+	}
+	return nil
+}
+
+// managedOptions 는 오퍼레이터가 직접 관리하므로 덮어쓸 수 없는 mysqld 옵션이다
+var managedOptions = []string{
+	"server-id", "server_id",
+	"gtid-mode", "gtid_mode",
+	"enforce-gtid-consistency", "enforce_gtid_consistency",
+}
+
+// validateConfig 는 mysqld 옵션이 한 줄의 설정으로 기록될 수 있는지 확인한다
+func validateConfig(config map[string]string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for key, value := range config {
+		if !configKeyRegexp.MatchString(key) {
+			allErrs = append(allErrs, field.Invalid(path.Key(key), key, "option name must consist of alphanumeric characters, '-' or '_'"))
+			continue
+		}
+		for _, option := range managedOptions {
+			if key == option {
+				allErrs = append(allErrs, field.Forbidden(path.Key(key), "option is managed by the operator"))
+			}
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			allErrs = append(allErrs, field.Invalid(path.Key(key), value, "value must not contain line breaks"))
+		}
+	}
+	return allErrs
+}
+
+// validateStorageUpdate 는 스테이트풀셋의 볼륨 클레임 템플릿을 변경할 수 없으므로 볼륨을 늘리는 것 외의 변경을 막는다
+func validateStorageUpdate(mysql, old *MySQL) field.ErrorList {
+	var allErrs field.ErrorList
+	path := field.NewPath("spec").Child("storage")
+	size, oldSize := mysql.Spec.StorageSize(), old.Spec.StorageSize()
+	if size.Cmp(oldSize) < 0 {
+		allErrs = append(allErrs, field.Forbidden(path.Child("size"), "storage size can not be decreased from "+oldSize.String()))
+	}
+	if !equality.Semantic.DeepEqual(mysql.Spec.Storage.StorageClassName, old.Spec.Storage.StorageClassName) {
+		allErrs = append(allErrs, field.Forbidden(path.Child("storageClassName"), "storage class can not be changed"))
+	}
+	if !equality.Semantic.DeepEqual(mysql.Spec.StorageAccessModes(), old.Spec.StorageAccessModes()) {
+		allErrs = append(allErrs, field.Forbidden(path.Child("accessModes"), "access modes can not be changed"))
+	}
+	return allErrs
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *MySQL) ValidateDelete() error {
+	mysqllog.Info("validate delete", "name", r.Name)
+	return validateDeletionProtection(r)
+}
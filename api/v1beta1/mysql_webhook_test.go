@@ -0,0 +1,220 @@
+package v1beta1
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var _ = Describe("mysql webhook", func() {
+	newMySQL := func() *MySQL {
+		return &MySQL{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: MySQLSpec{
+				Owner:       OwnerSpec{Name: "woohyung han"},
+				Replication: ReplicationSpec{Replicas: 3},
+			},
+		}
+	}
+
+	It("should default the server version and policies", func() {
+		mysql := newMySQL()
+		mysql.Default()
+		Expect(mysql.Spec.Server.Version).Should(Equal(DefaultVersion))
+		Expect(mysql.Spec.DeletionPolicy).Should(Equal(DeletionPolicyRetain))
+		Expect(mysql.Spec.Storage.ScaleDownPVCPolicy).Should(Equal(ScaleDownPVCPolicyRetain))
+		Expect(mysql.Spec.IsDeletionProtected()).Should(BeTrue())
+		Expect(mysql.ValidateCreate()).Should(Succeed())
+	})
+
+	It("should report errors with the paths of the sections", func() {
+		mysql := newMySQL()
+		mysql.Spec.Server.Version = "5.5"
+		mysql.Spec.Server.Config.Primary = map[string]string{"server-id": "1"}
+		mysql.Spec.DeletionPolicy = DeletionPolicyBackupThenDelete
+		err := mysql.ValidateCreate()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.server.version"))
+		Expect(err.Error()).Should(ContainSubstring("spec.server.config.primary[server-id]"))
+		Expect(err.Error()).Should(ContainSubstring("spec.backup.finalBackupStorage"))
+	})
+
+	It("should not let the pod template override the operator's labels and annotations", func() {
+		mysql := newMySQL()
+		mysql.Spec.PodTemplate.Metadata = PodMetadata{
+			Labels:      map[string]string{"team": "db"},
+			Annotations: map[string]string{"prometheus.io/scrape": "true"},
+		}
+		Expect(mysql.ValidateCreate()).Should(Succeed())
+
+		mysql.Spec.PodTemplate.Metadata.Labels["app"] = "other"
+		mysql.Spec.PodTemplate.Metadata.Annotations["mysql.sample.com/version"] = "8.0"
+		err := mysql.ValidateCreate()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.podTemplate.metadata.labels[app]"))
+		Expect(err.Error()).Should(ContainSubstring("spec.podTemplate.metadata.annotations[mysql.sample.com/version]"))
+	})
+
+	It("should not let the storage shrink or change its class", func() {
+		old := newMySQL()
+		size := resource.MustParse("10Gi")
+		old.Spec.Storage.Size = &size
+		mysql := old.DeepCopy()
+		larger := resource.MustParse("20Gi")
+		mysql.Spec.Storage.Size = &larger
+		Expect(mysql.ValidateUpdate(old)).Should(Succeed())
+
+		smaller := resource.MustParse("5Gi")
+		mysql.Spec.Storage.Size = &smaller
+		class := "fast"
+		mysql.Spec.Storage.StorageClassName = &class
+		err := mysql.ValidateUpdate(old)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.storage.size"))
+		Expect(err.Error()).Should(ContainSubstring("spec.storage.storageClassName"))
+	})
+
+	It("should not downgrade or skip a major version", func() {
+		old := newMySQL()
+		old.Spec.Server.Version = "5.7.30"
+		old.Status.Version = "5.7.30"
+		mysql := old.DeepCopy()
+		mysql.Spec.Server.Version = "5.7.31"
+		Expect(mysql.ValidateUpdate(old)).Should(Succeed())
+		mysql.Spec.Server.Version = "8.0"
+		Expect(mysql.ValidateUpdate(old)).Should(Succeed())
+
+		mysql.Spec.Server.Version = "5.6"
+		err := mysql.ValidateUpdate(old)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.server.version"))
+		Expect(err.Error()).Should(ContainSubstring("downgrade from 5.7.30 to 5.6"))
+
+		old.Spec.Server.Version = "5.6"
+		old.Status.Version = "5.6"
+		mysql.Spec.Server.Version = "8.0"
+		err = mysql.ValidateUpdate(old)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("must go through every major version"))
+	})
+
+	It("should allow returning to the running version to cancel an upgrade", func() {
+		old := newMySQL()
+		old.Spec.Server.Version = "8.0"
+		old.Status.Version = "5.7"
+		mysql := old.DeepCopy()
+		mysql.Spec.Server.Version = "5.7"
+		Expect(mysql.ValidateUpdate(old)).Should(Succeed())
+	})
+
+	It("should reject disruptive changes while an upgrade or a backup is in progress", func() {
+		for _, t := range []condition.ConditionType{ConditionTypeUpgrading, ConditionTypeBackingUp} {
+			old := newMySQL()
+			old.Status.Conditions.SetCondition(condition.Condition{Type: t, Status: corev1.ConditionTrue, Reason: "InProgress"})
+			mysql := old.DeepCopy()
+			mysql.Spec.Replication.Replicas = 5
+			Expect(mysql.ValidateUpdate(old)).Should(Succeed())
+
+			mysql.Spec.Replication.Replicas = 2
+			mysql.Spec.Server.Config.Primary = map[string]string{"max_connections": "500"}
+			mysql.Spec.PodTemplate.NodeSelector = map[string]string{"disktype": "ssd"}
+			mysql.Annotations = map[string]string{SwitchoverAnnotation: "sample-1"}
+			err := mysql.ValidateUpdate(old)
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("spec.server: Forbidden: can not be changed while " + string(t) + " is in progress"))
+			Expect(err.Error()).Should(ContainSubstring("spec.podTemplate"))
+			Expect(err.Error()).Should(ContainSubstring("spec.replication.replicas"))
+			Expect(err.Error()).Should(ContainSubstring("metadata.annotations[mysql.sample.com/switchover-to]"))
+		}
+	})
+
+	It("should refuse to delete a protected cluster", func() {
+		mysql := newMySQL()
+		mysql.Default()
+		err := mysql.ValidateDelete()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.deletionProtection"))
+
+		protected := false
+		mysql.Spec.DeletionProtection = &protected
+		Expect(mysql.ValidateDelete()).Should(Succeed())
+	})
+
+	It("should not protect existing clusters on update", func() {
+		mysql := newMySQL()
+		mysql.CreationTimestamp = metav1.Now()
+		mysql.Default()
+		Expect(mysql.Spec.DeletionProtection).Should(BeNil())
+		Expect(mysql.ValidateDelete()).Should(Succeed())
+	})
+
+	It("should normalize whitespace in the owner name", func() {
+		mysql := newMySQL()
+		mysql.Spec.Owner.Name = "  woohyung \t han\u3000"
+		mysql.Default()
+		Expect(mysql.Spec.Owner.Name).Should(Equal("woohyung han"))
+		Expect(mysql.ValidateCreate()).Should(Succeed())
+	})
+
+	It("should validate the owner name and labels", func() {
+		for _, name := range []string{"우형 한", "José Martínez", "Mary Ann O'Neil", "Jean-Luc Picard"} {
+			mysql := newMySQL()
+			mysql.Spec.Owner.Name = name
+			mysql.Default()
+			Expect(mysql.Spec.Owner.Name).Should(Equal(name))
+			Expect(mysql.ValidateCreate()).Should(Succeed(), name)
+		}
+		for _, name := range []string{"woohyung", "woohyung  han", " woohyung han", "woohyung h4n", "woohyung \xffhan"} {
+			mysql := newMySQL()
+			mysql.Spec.Owner.Name = name
+			err := mysql.ValidateCreate()
+			Expect(err).Should(HaveOccurred(), name)
+			Expect(err.Error()).Should(ContainSubstring("spec.owner.name"))
+		}
+
+		mysql := newMySQL()
+		mysql.Spec.Owner.Team = "db team"
+		mysql.Spec.Owner.CostCenter = "cc/1234"
+		err := mysql.ValidateCreate()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.owner.team"))
+		Expect(err.Error()).Should(ContainSubstring("spec.owner.costCenter"))
+	})
+
+	It("should keep accepting an unchanged owner name that predates the name rule", func() {
+		old := newMySQL()
+		old.Spec.Owner.Name = "Cher"
+		protected := true
+		old.Spec.DeletionProtection = &protected
+		mysql := old.DeepCopy()
+		unprotected := false
+		mysql.Spec.DeletionProtection = &unprotected
+		Expect(mysql.ValidateUpdate(old)).Should(Succeed())
+		Expect(mysql.ValidateDelete()).Should(Succeed())
+
+		mysql.Spec.Owner.Name = "Cherilyn"
+		err := mysql.ValidateUpdate(old)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.owner.name"))
+	})
+
+	It("should turn the owner name into a label value", func() {
+		Expect(OwnerLabelValue("Woohyung Han")).Should(Equal("woohyung-han"))
+		Expect(OwnerLabelValue("Mary Ann O'Neil")).Should(Equal("mary-ann-o-neil"))
+		Expect(OwnerLabelValue("우형 한")).Should(MatchRegexp("^[0-9a-f]{8}$"))
+		Expect(OwnerLabelValue("José Martínez")).Should(MatchRegexp("^jos-mart-nez-[0-9a-f]{8}$"))
+		for _, name := range []string{"우형 한", "José Martínez", strings.Repeat("Long ", 20) + "Name"} {
+			Expect(validation.IsValidLabelValue(OwnerLabelValue(name))).Should(BeEmpty(), name)
+		}
+
+		mysql := newMySQL()
+		Expect(mysql.Spec.OwnerLabels()).Should(HaveKeyWithValue(OwnerLabel, "woohyung-han"))
+		Expect(mysql.Spec.OwnerAnnotations()).Should(HaveKeyWithValue(OwnerAnnotation, "woohyung han"))
+	})
+})
@@ -0,0 +1,313 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// agent 는 오퍼레이터가 잡과 초기화 컨테이너로 실행하는 백업 도구이다
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/pkg/backup"
+	"sample-mysql-operator/pkg/binlog"
+	"sample-mysql-operator/pkg/mysqlclient"
+	"sample-mysql-operator/pkg/storage"
+)
+
+// 오퍼레이터가 잡에 넘기는 환경 변수
+const (
+	// storageEnv 는 JSON 으로 직렬화한 BackupStorage 이다
+	storageEnv = "BACKUP_STORAGE"
+	// accessKeyIDEnv 와 secretAccessKeyEnv 는 S3 호환 저장소의 인증 정보이다
+	accessKeyIDEnv     = "AWS_ACCESS_KEY_ID"
+	secretAccessKeyEnv = "AWS_SECRET_ACCESS_KEY"
+	// rootPasswordEnv 는 바이너리 로그를 닫을 때 로컬 MySQL 서버에 접속하는 root 비밀번호이다
+	rootPasswordEnv = "MYSQL_ROOT_PASSWORD"
+)
+
+// storageMountPath 는 볼륨 클레임 저장소를 마운트하는 경로이다
+const storageMountPath = "/backup"
+
+var log = ctrl.Log.WithName("agent")
+
+func main() {
+	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s backup|delete|restore|install|archive-binlogs|restore-binlogs [flags]\n", os.Args[0])
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "backup":
+		err = runBackup(os.Args[2:])
+	case "delete":
+		err = runDelete(os.Args[2:])
+	case "restore":
+		err = runRestore(os.Args[2:])
+	case "install":
+		err = runInstall(os.Args[2:])
+	case "archive-binlogs":
+		err = runArchiveBinlogs(os.Args[2:])
+	case "restore-binlogs":
+		err = runRestoreBinlogs(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		log.Error(err, "command failed", "command", os.Args[1])
+		os.Exit(1)
+	}
+}
+
+// runBackup 은 xtrabackup 사이드카에서 백업을 받아 저장소에 올리고 결과를 resultFile 에 JSON 으로 쓴다
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	host := fs.String("host", "", "The host of the MySQL pod to back up.")
+	port := fs.String("port", "3307", "The port the xtrabackup sidecar streams backups on.")
+	key := fs.String("key", "", "The key to store the backup under.")
+	resultFile := fs.String("result-file", "/dev/termination-log", "The file to write the backup result to.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *host == "" || *key == "" {
+		return fmt.Errorf("--host and --key are required")
+	}
+
+	backend, err := newBackend()
+	if err != nil {
+		return err
+	}
+	log.Info("starting backup", "host", *host, "location", backend.Location(*key))
+	result, err := backup.Backup(context.Background(), backend, net.JoinHostPort(*host, *port), *key)
+	if err != nil {
+		return err
+	}
+	log.Info("backup completed", "location", result.Location, "size", result.Size, "gtidSet", result.GTIDSet)
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*resultFile, data, 0644)
+}
+
+// runDelete 는 저장소에서 백업을 삭제한다
+func runDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	key := fs.String("key", "", "The key of the backup to delete.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *key == "" {
+		return fmt.Errorf("--key is required")
+	}
+
+	backend, err := newBackend()
+	if err != nil {
+		return err
+	}
+	log.Info("deleting backup", "location", backend.Location(*key))
+	return backend.Delete(context.Background(), *key)
+}
+
+// runRestore 는 저장소의 백업을 표준 출력으로 내보낸다. 로그는 표준 에러로 나가므로 xbstream 으로 바로 넘길 수 있다
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	key := fs.String("key", "", "The key of the backup to restore.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *key == "" {
+		return fmt.Errorf("--key is required")
+	}
+
+	backend, err := newBackend()
+	if err != nil {
+		return err
+	}
+	log.Info("restoring backup", "location", backend.Location(*key))
+	ctx := context.Background()
+	r, err := backend.Get(ctx, *key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	n, err := io.Copy(os.Stdout, r)
+	if err != nil {
+		return err
+	}
+	log.Info("backup streamed", "size", n)
+	return nil
+}
+
+// runInstall 은 에이전트 바이너리를 다른 컨테이너와 공유하는 볼륨에 복사한다
+func runInstall(args []string) error {
+	fs := flag.NewFlagSet("install", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: install <dir>")
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(self)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(filepath.Join(fs.Arg(0), "agent"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// runArchiveBinlogs 는 프라이머리인 동안 주기적으로 바이너리 로그를 닫고 저장소에 보관한다. 보관 상태는 HTTP 로 알려준다
+func runArchiveBinlogs(args []string) error {
+	fs := flag.NewFlagSet("archive-binlogs", flag.ExitOnError)
+	dir := fs.String("dir", "/var/lib/mysql", "The MySQL data directory that has the binary logs.")
+	prefix := fs.String("prefix", "", "The prefix to archive binary logs under.")
+	interval := fs.Duration("interval", 5*time.Minute, "How often to close the current binary log and archive it.")
+	primaryFile := fs.String("primary-file", "/mnt/config-map/primary", "The file with the name of the primary pod.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *prefix == "" {
+		return fmt.Errorf("--prefix is required")
+	}
+
+	backend, err := newBackend()
+	if err != nil {
+		return err
+	}
+	archiver := &binlog.Archiver{Backend: backend, Dir: *dir, Prefix: *prefix}
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/status", archiver)
+		log.Error(http.ListenAndServe(":"+strconv.Itoa(binlog.StatusPort), mux), "status server stopped")
+		os.Exit(1)
+	}()
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	log.Info("archiving binary logs", "location", backend.Location(*prefix), "interval", *interval)
+	ctx := context.Background()
+	for ; ; time.Sleep(*interval) {
+		// 레플리카의 바이너리 로그는 프라이머리의 트랜잭션을 복제한 것이므로 프라이머리만 보관한다
+		if primary, err := currentPrimary(*primaryFile, hostname); err != nil || primary != hostname {
+			continue
+		}
+		if err := flushBinaryLogs(ctx); err != nil {
+			log.Error(err, "failed to close the current binary log")
+		}
+		if err := archiver.Archive(ctx); err != nil {
+			log.Error(err, "failed to archive binary logs")
+			continue
+		}
+		log.Info("binary logs archived", "last", archiver.Status().LastArchivedKey)
+	}
+}
+
+// currentPrimary 는 컨피그맵에 기록된 프라이머리 파드의 이름을 반환한다. 기록되기 전에는 첫 번째 파드가 프라이머리이다
+func currentPrimary(primaryFile, hostname string) (string, error) {
+	data, err := ioutil.ReadFile(primaryFile)
+	if os.IsNotExist(err) {
+		return hostname[:strings.LastIndex(hostname, "-")+1] + "0", nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// flushBinaryLogs 는 로컬 MySQL 서버의 현재 바이너리 로그를 닫아 보관할 수 있게 한다
+func flushBinaryLogs(ctx context.Context) error {
+	c, err := mysqlclient.New("127.0.0.1", "root", os.Getenv(rootPasswordEnv))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.FlushBinaryLogs(ctx)
+}
+
+// runRestoreBinlogs 는 백업 이후에 보관된 바이너리 로그를 내려받는다
+func runRestoreBinlogs(args []string) error {
+	fs := flag.NewFlagSet("restore-binlogs", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "The prefix binary logs are archived under.")
+	since := fs.String("since", "", "Skip binary logs closed before this RFC 3339 time.")
+	dir := fs.String("dir", "", "The directory to download binary logs to.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *prefix == "" || *dir == "" {
+		return fmt.Errorf("--prefix and --dir are required")
+	}
+	var sinceTime time.Time
+	if *since != "" {
+		var err error
+		if sinceTime, err = time.Parse(time.RFC3339, *since); err != nil {
+			return fmt.Errorf("invalid --since: %v", err)
+		}
+	}
+
+	backend, err := newBackend()
+	if err != nil {
+		return err
+	}
+	log.Info("downloading binary logs", "location", backend.Location(*prefix), "since", *since)
+	files, err := binlog.Download(context.Background(), backend, *prefix, sinceTime, *dir)
+	if err != nil {
+		return err
+	}
+	log.Info("binary logs downloaded", "count", len(files))
+	return nil
+}
+
+// newBackend 는 환경 변수로 넘겨받은 저장소를 만든다
+func newBackend() (storage.Backend, error) {
+	spec := &mysqlv1alpha1.BackupStorage{}
+	if err := json.Unmarshal([]byte(os.Getenv(storageEnv)), spec); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", storageEnv, err)
+	}
+	return storage.New(spec, storage.Options{
+		MountPath:       storageMountPath,
+		AccessKeyID:     os.Getenv(accessKeyIDEnv),
+		SecretAccessKey: os.Getenv(secretAccessKeyEnv),
+	})
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+)
+
+// syncBackupCondition 은 클러스터의 백업이 진행 중인지 컨디션에 기록한다.
+// 웹훅은 API 서버에 접근하지 않으므로 이 컨디션을 보고 백업 중에 파드를 재시작하는 변경을 막는다
+func (r *MySQLReconciler) syncBackupCondition(mysql *mysqlv1beta1.MySQL) error {
+	backups := &mysqlv1alpha1.MySQLBackupList{}
+	if err := r.List(context.TODO(), backups, client.InNamespace(mysql.Namespace)); err != nil {
+		return err
+	}
+	var running []string
+	for i := range backups.Items {
+		b := &backups.Items[i]
+		if b.Spec.Cluster == mysql.Name && b.Status.Phase == mysqlv1alpha1.BackupPhaseRunning {
+			running = append(running, b.Name)
+		}
+	}
+	if len(running) == 0 {
+		// 백업을 한 번도 받지 않은 클러스터에는 컨디션을 남기지 않는다
+		if findCondition(mysql.Status.Conditions, mysqlv1beta1.ConditionTypeBackingUp) == nil {
+			return nil
+		}
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeBackingUp,
+			Status:  corev1.ConditionFalse,
+			Reason:  "NoBackupRunning",
+			Message: "No backup is running",
+		})
+	}
+	return r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeBackingUp,
+		Status:  corev1.ConditionTrue,
+		Reason:  "BackupRunning",
+		Message: fmt.Sprintf("Backing up in %s", strings.Join(running, ", ")),
+	})
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("mysql backup condition", func() {
+	var r *MySQLReconciler
+
+	newBackup := func(name, cluster string, phase v1alpha1.BackupPhase) *v1alpha1.MySQLBackup {
+		return &v1alpha1.MySQLBackup{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1alpha1.MySQLBackupSpec{Cluster: cluster},
+			Status:     v1alpha1.MySQLBackupStatus{Phase: phase},
+		}
+	}
+
+	BeforeEach(func() {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		mysql := &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+			},
+		}
+		r = &MySQLReconciler{
+			Client: fake.NewFakeClientWithScheme(s, mysql,
+				newBackup("done", "sample", v1alpha1.BackupPhaseCompleted),
+				// 다른 클러스터의 백업은 무시한다
+				newBackup("other", "other", v1alpha1.BackupPhaseRunning),
+			),
+			Log:    ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme: s,
+		}
+	})
+
+	// syncBackups 는 저장된 클러스터로 컨디션을 갱신하고 갱신된 클러스터를 반환한다
+	syncBackups := func() *v1beta1.MySQL {
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample"}, mysql)).Should(Succeed())
+		Expect(r.syncBackupCondition(mysql)).Should(Succeed())
+		return mysql
+	}
+
+	It("should not add the condition to a cluster without running backups", func() {
+		mysql := syncBackups()
+		Expect(findCondition(mysql.Status.Conditions, v1beta1.ConditionTypeBackingUp)).Should(BeNil())
+	})
+
+	It("should record running backups of the cluster", func() {
+		backup := newBackup("daily", "sample", v1alpha1.BackupPhaseRunning)
+		Expect(r.Client.Create(context.TODO(), backup)).Should(Succeed())
+		cond := findCondition(syncBackups().Status.Conditions, v1beta1.ConditionTypeBackingUp)
+		Expect(cond).ShouldNot(BeNil())
+		Expect(cond.Status).Should(Equal(corev1.ConditionTrue))
+		Expect(cond.Message).Should(Equal("Backing up in daily"))
+
+		By("completing the backup")
+		backup.Status.Phase = v1alpha1.BackupPhaseCompleted
+		Expect(r.Client.Update(context.TODO(), backup)).Should(Succeed())
+		Expect(syncBackups().Status.Conditions.IsFalseFor(v1beta1.ConditionTypeBackingUp)).Should(BeTrue())
+	})
+})
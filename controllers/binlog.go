@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/binlog"
+)
+
+const (
+	// defaultBinlogArchiveInterval 은 Spec.BinlogArchive.Interval 이 비어 있을 때 바이너리 로그를 닫고 보관하는 주기이다
+	defaultBinlogArchiveInterval = 5 * time.Minute
+	// mysqlUID 는 MySQL 이미지에서 데이터 파일을 소유하는 사용자이다. 아카이버가 바이너리 로그를 읽으려면 같은 사용자로 실행해야 한다
+	mysqlUID int64 = 999
+	// pitrMountPath 는 복구할 때 재생할 바이너리 로그를 데이터 볼륨에 내려받는 경로이다. 재생이 끝나면 binlogs 디렉터리를 지운다
+	pitrMountPath = "/var/lib/mysql-pitr"
+)
+
+// binlogPrefix 는 클러스터의 바이너리 로그를 보관하는 저장소 안의 경로이다
+func binlogPrefix(cluster string) string {
+	return cluster + "/binlog/"
+}
+
+// binlogArchiverContainer 는 프라이머리의 바이너리 로그를 주기적으로 닫고 저장소에 보관하는 사이드카와 그 볼륨을 반환한다.
+// 모든 파드에서 실행되지만 컨피그맵에 기록된 프라이머리에서만 보관한다
+func binlogArchiverContainer(mysql *mysqlv1beta1.MySQL, agentImage string) (corev1.Container, []corev1.Volume) {
+	archive := mysql.Spec.Backup.BinlogArchive
+	interval := defaultBinlogArchiveInterval
+	if archive.Interval != nil {
+		interval = archive.Interval.Duration
+	}
+	storage := mysqlv1alpha1.ConvertBackupStorageFrom(&archive.Storage)
+	env, volumes, storageMounts := backupStorageAccess(&storage, "binlog-archive")
+	uid := mysqlUID
+	return corev1.Container{
+		Name:  "binlog-archiver",
+		Image: agentImage,
+		Command: []string{
+			"/agent", "archive-binlogs",
+			"--dir", "/var/lib/mysql",
+			"--prefix", binlogPrefix(mysql.Name),
+			"--interval", interval.String(),
+		},
+		Env: append(env, credentialEnv(mysql)...),
+		VolumeMounts: append([]corev1.VolumeMount{
+			{
+				Name:      "data",
+				MountPath: "/var/lib/mysql",
+				SubPath:   "mysql",
+				ReadOnly:  true,
+			},
+			{
+				Name:      "config-map",
+				MountPath: "/mnt/config-map",
+			},
+		}, storageMounts...),
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          "binlog-archive",
+				ContainerPort: binlog.StatusPort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		},
+		SecurityContext: &corev1.SecurityContext{RunAsUser: &uid},
+	}, volumes
+}
+
+// syncBinlogArchive 는 프라이머리의 아카이버에서 보관 상태를 가져와 복구할 수 있는 시간 범위를 Status.BinlogArchive 에 기록한다
+func (r *MySQLReconciler) syncBinlogArchive(mysql *mysqlv1beta1.MySQL) error {
+	if mysql.Spec.Backup.BinlogArchive == nil {
+		if mysql.Status.BinlogArchive == nil {
+			return nil
+		}
+		mysql.Status.BinlogArchive = nil
+		return r.Status().Update(context.TODO(), mysql)
+	}
+
+	status := &mysqlv1beta1.BinlogArchiveStatus{Since: metav1.Now()}
+	if mysql.Status.BinlogArchive != nil {
+		status = mysql.Status.BinlogArchive.DeepCopy()
+	}
+	if err := r.observeBinlogArchive(mysql, status); err != nil {
+		status.Message = err.Error()
+	}
+
+	// 보관을 시작한 뒤 같은 저장소에 완료된 백업부터 복구할 수 있다
+	backups := &mysqlv1alpha1.MySQLBackupList{}
+	if err := r.List(context.TODO(), backups, client.InNamespace(mysql.Namespace)); err != nil {
+		return err
+	}
+	status.RecoverableFrom = nil
+	storage := mysqlv1alpha1.ConvertBackupStorageFrom(&mysql.Spec.Backup.BinlogArchive.Storage)
+	for i := range backups.Items {
+		b := &backups.Items[i]
+		if b.Spec.Cluster != mysql.Name || b.Status.Phase != mysqlv1alpha1.BackupPhaseCompleted || b.Status.CompletionTime == nil ||
+			b.Status.CompletionTime.Before(&status.Since) || !equality.Semantic.DeepEqual(b.Spec.Storage, storage) {
+			continue
+		}
+		if status.RecoverableFrom == nil || b.Status.CompletionTime.Before(status.RecoverableFrom) {
+			status.RecoverableFrom = b.Status.CompletionTime.DeepCopy()
+		}
+	}
+	status.RecoverableUntil = nil
+	if status.RecoverableFrom != nil && status.LastArchivedTime != nil && !status.LastArchivedTime.Before(status.RecoverableFrom) {
+		status.RecoverableUntil = status.LastArchivedTime.DeepCopy()
+	}
+
+	if equality.Semantic.DeepEqual(status, mysql.Status.BinlogArchive) {
+		return nil
+	}
+	mysql.Status.BinlogArchive = status
+	return r.Status().Update(context.TODO(), mysql)
+}
+
+// observeBinlogArchive 는 프라이머리의 아카이버에서 마지막으로 보관한 바이너리 로그를 읽는다
+func (r *MySQLReconciler) observeBinlogArchive(mysql *mysqlv1beta1.MySQL, status *mysqlv1beta1.BinlogArchiveStatus) error {
+	if r.BinlogArchiveStatus == nil || mysql.Status.Primary == "" {
+		return nil
+	}
+	pod := &corev1.Pod{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Status.Primary}, pod); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !isPodReady(pod) || pod.Status.PodIP == "" {
+		return nil
+	}
+	archived, err := r.BinlogArchiveStatus(context.TODO(), pod.Status.PodIP)
+	if err != nil {
+		return err
+	}
+	status.Message = archived.LastError
+	// 페일오버한 뒤 새 프라이머리가 아직 보관하지 않았으면 이전 기록을 유지한다
+	if archived.LastArchivedTime != nil && (status.LastArchivedTime == nil || archived.LastArchivedTime.After(status.LastArchivedTime.Time)) {
+		status.LastArchivedBinlog = archived.LastArchivedKey
+		t := metav1.NewTime(archived.LastArchivedTime.Truncate(time.Second))
+		status.LastArchivedTime = &t
+	}
+	return nil
+}
+
+// pointInTimeEnv 는 복원한 프라이머리의 xtrabackup 컨테이너가 바이너리 로그를 어디까지 재생할지 나타내는 환경 변수를 반환한다
+func pointInTimeEnv(pitr *mysqlv1beta1.PointInTimeRecovery) []corev1.EnvVar {
+	var env []corev1.EnvVar
+	if pitr.Time != nil {
+		env = append(env, corev1.EnvVar{Name: "PITR_STOP_DATETIME", Value: pitr.Time.UTC().Format("2006-01-02 15:04:05")})
+	}
+	if pitr.GTIDSet != "" {
+		env = append(env, corev1.EnvVar{Name: "PITR_INCLUDE_GTIDS", Value: pitr.GTIDSet})
+	}
+	return env
+}
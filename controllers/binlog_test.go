@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/binlog"
+	"sample-mysql-operator/pkg/mysqlclient"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql binlog archive", func() {
+	var (
+		mysql    *v1beta1.MySQL
+		backups  []runtime.Object
+		archived *binlog.Status
+		hosts    []string
+		r        *MySQLReconciler
+		since    = time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+		storage  = v1alpha1.BackupStorage{PersistentVolumeClaim: &v1alpha1.PVCBackupStorage{ClaimName: "backups"}}
+		req      = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	newBackup := func(name, cluster string, storage v1alpha1.BackupStorage, phase v1alpha1.BackupPhase, completed time.Time) *v1alpha1.MySQLBackup {
+		completionTime := v1.NewTime(completed)
+		return &v1alpha1.MySQLBackup{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1alpha1.MySQLBackupSpec{Cluster: cluster, Storage: storage},
+			Status:     v1alpha1.MySQLBackupStatus{Phase: phase, CompletionTime: &completionTime},
+		}
+	}
+
+	reconcileMySQL := func() {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		primary := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: "sample-0", Namespace: "default", Labels: map[string]string{"app": "sample"}},
+			Status: corev1.PodStatus{
+				PodIP:      "10.0.0.0",
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		}
+		r = &MySQLReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, append([]runtime.Object{mysql, primary}, backups...)...),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme:   s,
+			Recorder: record.NewFakeRecorder(10),
+			MySQLClient: func(host, user, password string) (mysqlclient.Client, error) {
+				return &fakeMySQL{}, nil
+			},
+			AgentImage: "controller:latest",
+			BinlogArchiveStatus: func(ctx context.Context, host string) (*binlog.Status, error) {
+				hosts = append(hosts, host)
+				if archived == nil {
+					return nil, fmt.Errorf("connection refused")
+				}
+				return archived, nil
+			},
+		}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		// 가짜 클라이언트는 읽은 값을 기존 오브젝트에 덮어쓰므로 비워진 상태가 남지 않도록 새 오브젝트로 읽는다
+		mysql = &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		hosts = nil
+		archivedTime := since.Add(3 * time.Hour)
+		archived = &binlog.Status{
+			LastArchivedKey:  "sample/binlog/20200701T030000Z-sample-0-bin.000004",
+			LastArchivedTime: &archivedTime,
+		}
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+				Backup:      v1beta1.BackupSpec{BinlogArchive: &v1beta1.BinlogArchiveSpec{Storage: v1alpha1.ConvertBackupStorageTo(&storage)}},
+			},
+			Status: v1beta1.MySQLStatus{
+				Primary:       "sample-0",
+				BinlogArchive: &v1beta1.BinlogArchiveStatus{Since: v1.NewTime(since)},
+			},
+		}
+		other := v1alpha1.BackupStorage{PersistentVolumeClaim: &v1alpha1.PVCBackupStorage{ClaimName: "other"}}
+		backups = []runtime.Object{
+			newBackup("before-archiving", "sample", storage, v1alpha1.BackupPhaseCompleted, since.Add(-time.Hour)),
+			newBackup("other-storage", "sample", other, v1alpha1.BackupPhaseCompleted, since.Add(30*time.Minute)),
+			newBackup("other-cluster", "other", storage, v1alpha1.BackupPhaseCompleted, since.Add(30*time.Minute)),
+			newBackup("running", "sample", storage, v1alpha1.BackupPhaseRunning, since.Add(40*time.Minute)),
+			newBackup("first", "sample", storage, v1alpha1.BackupPhaseCompleted, since.Add(time.Hour)),
+			newBackup("second", "sample", storage, v1alpha1.BackupPhaseCompleted, since.Add(2*time.Hour)),
+		}
+	})
+
+	It("should run the archiver next to mysql", func() {
+		reconcileMySQL()
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		containers := sf.Spec.Template.Spec.Containers
+		Expect(containers).Should(HaveLen(3))
+		archiver := containers[2]
+		Expect(archiver.Name).Should(Equal("binlog-archiver"))
+		Expect(archiver.Image).Should(Equal("controller:latest"))
+		Expect(archiver.Command).Should(Equal([]string{
+			"/agent", "archive-binlogs", "--dir", "/var/lib/mysql", "--prefix", "sample/binlog/", "--interval", "5m0s",
+		}))
+		Expect(sf.Spec.Template.Spec.Volumes).Should(ContainElement(corev1.Volume{
+			Name: "binlog-archive",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: "backups",
+			}},
+		}))
+	})
+
+	It("should publish the recoverable window", func() {
+		reconcileMySQL()
+		Expect(hosts).Should(Equal([]string{"10.0.0.0"}))
+		status := mysql.Status.BinlogArchive
+		Expect(status.LastArchivedBinlog).Should(Equal("sample/binlog/20200701T030000Z-sample-0-bin.000004"))
+		Expect(status.LastArchivedTime.Time).Should(BeTemporally("==", since.Add(3*time.Hour)))
+		Expect(status.RecoverableFrom.Time).Should(BeTemporally("==", since.Add(time.Hour)))
+		Expect(status.RecoverableUntil.Time).Should(BeTemporally("==", since.Add(3*time.Hour)))
+		Expect(status.Message).Should(BeEmpty())
+	})
+
+	It("should not publish a window without a backup to recover from", func() {
+		backups = nil
+		reconcileMySQL()
+		Expect(mysql.Status.BinlogArchive.LastArchivedTime).ShouldNot(BeNil())
+		Expect(mysql.Status.BinlogArchive.RecoverableFrom).Should(BeNil())
+		Expect(mysql.Status.BinlogArchive.RecoverableUntil).Should(BeNil())
+	})
+
+	It("should keep the last archived binlog when the archiver can not be reached", func() {
+		lastArchived := v1.NewTime(since.Add(90 * time.Minute))
+		mysql.Status.BinlogArchive.LastArchivedBinlog = "sample/binlog/20200701T013000Z-sample-0-bin.000002"
+		mysql.Status.BinlogArchive.LastArchivedTime = &lastArchived
+		archived = nil
+		reconcileMySQL()
+		status := mysql.Status.BinlogArchive
+		Expect(status.Message).Should(Equal("connection refused"))
+		Expect(status.LastArchivedBinlog).Should(Equal("sample/binlog/20200701T013000Z-sample-0-bin.000002"))
+		Expect(status.RecoverableUntil.Time).Should(BeTemporally("==", since.Add(90*time.Minute)))
+	})
+
+	It("should start recording when archiving is enabled", func() {
+		mysql.Status.BinlogArchive = nil
+		reconcileMySQL()
+		Expect(mysql.Status.BinlogArchive.Since.Time).Should(BeTemporally("~", time.Now(), time.Minute))
+		// 보관을 시작하기 전의 백업으로는 복구할 수 없다
+		Expect(mysql.Status.BinlogArchive.RecoverableFrom).Should(BeNil())
+	})
+
+	It("should clear the status when archiving is disabled", func() {
+		mysql.Spec.Backup.BinlogArchive = nil
+		reconcileMySQL()
+		Expect(mysql.Status.BinlogArchive).Should(BeNil())
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Spec.Containers).Should(HaveLen(2))
+	})
+})
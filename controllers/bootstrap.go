@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+)
+
+// agentToolsPath 는 에이전트 바이너리를 다른 이미지의 컨테이너에서 실행할 수 있도록 복사해 두는 경로이다
+const agentToolsPath = "/tools"
+
+// syncBootstrap 은 Spec.Bootstrap 으로 복원할 백업이나 복제할 클러스터를 찾아 Status.Bootstrap 에 기록한다. 초기 데이터를
+// 가져올 수 있을 때까지 스테이트풀셋을 만들지 않으며, 모든 파드가 준비되면 복원을 마친 것으로 기록한다
+func (r *MySQLReconciler) syncBootstrap(mysql *mysqlv1beta1.MySQL) error {
+	spec := mysql.Spec.Bootstrap
+	if spec == nil || (spec.FromBackup == nil && spec.CloneFrom == nil) {
+		return nil
+	}
+	if mysql.Status.Bootstrap != nil {
+		if mysql.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeBootstrap) && mysql.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeRunning) {
+			source := bootstrapSource(mysql.Status.Bootstrap)
+			r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "Restored", "Restored %s", source)
+			return r.setCondition(mysql, condition.Condition{
+				Type:    mysqlv1beta1.ConditionTypeBootstrap,
+				Status:  corev1.ConditionFalse,
+				Reason:  "Restored",
+				Message: fmt.Sprintf("Restored %s", source),
+			})
+		}
+		return nil
+	}
+	// Bootstrap 없이 만들어진 클러스터는 이미 데이터가 있다
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}, &appsv1.StatefulSet{})
+	if err == nil || !errors.IsNotFound(err) {
+		return err
+	}
+
+	var bootstrap *mysqlv1beta1.BootstrapStatus
+	var waiting *condition.Condition
+	if spec.CloneFrom != nil {
+		bootstrap, waiting, err = r.resolveClone(mysql)
+	} else {
+		bootstrap, waiting, err = r.resolveBackup(mysql)
+	}
+	if err != nil {
+		return err
+	}
+	if waiting != nil {
+		waiting.Type = mysqlv1beta1.ConditionTypeBootstrap
+		waiting.Status = corev1.ConditionTrue
+		return r.setCondition(mysql, *waiting)
+	}
+
+	source := bootstrapSource(bootstrap)
+	r.Log.Info("Bootstrap", "source", source)
+	mysql.Status.Bootstrap = bootstrap
+	mysql.Status.Conditions.SetCondition(condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeBootstrap,
+		Status:  corev1.ConditionTrue,
+		Reason:  "Restoring",
+		Message: fmt.Sprintf("Restoring %s into %s", source, podName(mysql, 0)),
+	})
+	return r.Status().Update(context.TODO(), mysql)
+}
+
+// resolveBackup 은 복원할 백업을 찾는다. 백업을 아직 복원할 수 없으면 그 이유를 컨디션으로 반환한다
+func (r *MySQLReconciler) resolveBackup(mysql *mysqlv1beta1.MySQL) (*mysqlv1beta1.BootstrapStatus, *condition.Condition, error) {
+	from := mysql.Spec.Bootstrap.FromBackup
+	bootstrap := &mysqlv1beta1.BootstrapStatus{Key: from.Key, PointInTime: from.PointInTime.DeepCopy()}
+	if from.Storage != nil {
+		bootstrap.Storage = *from.Storage
+		return bootstrap, nil, nil
+	}
+
+	backup := &mysqlv1alpha1.MySQLBackup{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: from.Name}, backup); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, nil, err
+		}
+		return nil, &condition.Condition{
+			Reason:  "BackupNotFound",
+			Message: fmt.Sprintf("Waiting for MySQLBackup %s to be created", from.Name),
+		}, nil
+	}
+	switch backup.Status.Phase {
+	case mysqlv1alpha1.BackupPhaseCompleted:
+	case mysqlv1alpha1.BackupPhaseFailed:
+		return nil, &condition.Condition{
+			Reason:  "BackupFailed",
+			Message: fmt.Sprintf("MySQLBackup %s failed: %s", from.Name, backup.Status.Message),
+		}, nil
+	default:
+		return nil, &condition.Condition{
+			Reason:  "WaitingForBackup",
+			Message: fmt.Sprintf("Waiting for MySQLBackup %s to complete", from.Name),
+		}, nil
+	}
+	if pitr := from.PointInTime; pitr != nil && pitr.Time != nil && backup.Status.CompletionTime != nil && pitr.Time.Before(backup.Status.CompletionTime) {
+		return nil, &condition.Condition{
+			Reason:  "InvalidRecoveryTarget",
+			Message: fmt.Sprintf("MySQLBackup %s completed at %s, after the recovery target", from.Name, backup.Status.CompletionTime.UTC().Format(time.RFC3339)),
+		}, nil
+	}
+	bootstrap.Backup = backup.Name
+	bootstrap.Storage = mysqlv1alpha1.ConvertBackupStorageTo(&backup.Spec.Storage)
+	bootstrap.Key = backupKey(backup)
+	bootstrap.GTIDSet = backup.Status.GTIDSet
+	if bootstrap.PointInTime != nil {
+		// 백업을 시작하기 전에 닫힌 바이너리 로그의 트랜잭션은 모두 백업에 포함되어 있다
+		bootstrap.BinlogsSince = backup.Status.StartTime.DeepCopy()
+		if bootstrap.PointInTime.BinlogPrefix == "" {
+			bootstrap.PointInTime.BinlogPrefix = binlogPrefix(backup.Spec.Cluster)
+		}
+	}
+	return bootstrap, nil, nil
+}
+
+// resolveClone 은 복제할 클러스터에서 데이터를 받을 파드를 고른다. 다른 네임스페이스의 클러스터는 그 네임스페이스의
+// MySQLCloneGrant 가 허용해야 한다. 아직 복제할 수 없으면 그 이유를 컨디션으로 반환한다
+func (r *MySQLReconciler) resolveClone(mysql *mysqlv1beta1.MySQL) (*mysqlv1beta1.BootstrapStatus, *condition.Condition, error) {
+	from := mysql.Spec.Bootstrap.CloneFrom
+	namespace := from.Namespace
+	if namespace == "" {
+		namespace = mysql.Namespace
+	}
+	if namespace != mysql.Namespace {
+		allowed, err := r.cloneAllowed(from.Name, namespace, mysql.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !allowed {
+			return nil, &condition.Condition{
+				Reason:  "CloneNotPermitted",
+				Message: fmt.Sprintf("No MySQLCloneGrant in %s allows namespace %s to clone %s", namespace, mysql.Namespace, from.Name),
+			}, nil
+		}
+	}
+
+	source := &mysqlv1beta1.MySQL{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: from.Name}, source); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, nil, err
+		}
+		return nil, &condition.Condition{
+			Reason:  "SourceNotFound",
+			Message: fmt.Sprintf("Waiting for MySQL %s/%s to be created", namespace, from.Name),
+		}, nil
+	}
+	// 프라이머리의 부하를 줄이기 위해 복제가 정상인 레플리카에서 먼저 받는다
+	pod := backupSourcePod(source, mysqlv1alpha1.BackupSourceReplica)
+	if pod == "" {
+		pod = backupSourcePod(source, mysqlv1alpha1.BackupSourcePrimary)
+	}
+	if pod == "" || !source.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeRunning) {
+		return nil, &condition.Condition{
+			Reason:  "WaitingForSource",
+			Message: fmt.Sprintf("Waiting for MySQL %s/%s to be running", namespace, from.Name),
+		}, nil
+	}
+	return &mysqlv1beta1.BootstrapStatus{
+		CloneFrom: &mysqlv1beta1.BootstrapCloneStatus{
+			Name:      source.Name,
+			Namespace: namespace,
+			// 파드는 헤드리스 서비스의 이름으로 다른 네임스페이스에서도 찾을 수 있다
+			Host: fmt.Sprintf("%s.%s.%s", pod, source.Name, namespace),
+		},
+	}, nil, nil
+}
+
+// cloneAllowed 는 namespace 의 MySQLCloneGrant 중 target 네임스페이스에서 cluster 를 복제하도록 허용하는 것이 있는지 확인한다
+func (r *MySQLReconciler) cloneAllowed(cluster, namespace, target string) (bool, error) {
+	grants := &mysqlv1alpha1.MySQLCloneGrantList{}
+	if err := r.List(context.TODO(), grants, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+	for i := range grants.Items {
+		if grants.Items[i].Allows(cluster, target) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// bootstrapSource 는 초기 데이터를 가져온 곳을 이벤트와 컨디션에 남길 수 있도록 나타낸다
+func bootstrapSource(bootstrap *mysqlv1beta1.BootstrapStatus) string {
+	if clone := bootstrap.CloneFrom; clone != nil {
+		return fmt.Sprintf("MySQL %s/%s", clone.Namespace, clone.Name)
+	}
+	return bootstrap.Key
+}
+
+// pointInTimeRecovery 는 복원한 백업 이후의 바이너리 로그를 재생해야 하면 복구할 시점을 반환한다
+func pointInTimeRecovery(mysql *mysqlv1beta1.MySQL) *mysqlv1beta1.PointInTimeRecovery {
+	if mysql.Status.Bootstrap == nil {
+		return nil
+	}
+	return mysql.Status.Bootstrap.PointInTime
+}
+
+// bootstrapPending 은 초기 데이터를 가져올 곳을 아직 찾지 못해 스테이트풀셋을 만들면 안 되는지 확인한다
+func bootstrapPending(mysql *mysqlv1beta1.MySQL) bool {
+	spec := mysql.Spec.Bootstrap
+	return spec != nil && (spec.FromBackup != nil || spec.CloneFrom != nil) && mysql.Status.Bootstrap == nil
+}
+
+// restoreInitContainers 는 프라이머리의 빈 데이터 볼륨에 백업을 복원하는 초기화 컨테이너와 그 볼륨을 반환한다.
+// 에이전트 바이너리를 tools 볼륨에 복사한 뒤 xtrabackup 이미지에서 백업을 받아 풀고 준비한다.
+// 특정 시점으로 복구하는 경우 재생할 바이너리 로그도 함께 내려받는다. 클러스터를 복제하는 경우에는 원본 파드의
+// xtrabackup 컨테이너에서 바로 데이터를 받는다
+func restoreInitContainers(mysql *mysqlv1beta1.MySQL, agentImage string, dataMounts []corev1.VolumeMount) ([]corev1.Container, []corev1.Volume) {
+	bootstrap := mysql.Status.Bootstrap
+	if bootstrap == nil {
+		return nil, nil
+	}
+	if clone := bootstrap.CloneFrom; clone != nil {
+		return []corev1.Container{
+			{
+				Name:    "clone-source",
+				Image:   "quay.io/sample-mysql-operator/xtrabackup:latest",
+				Command: []string{"bash", "-c", cloneSourceScript},
+				Env:     []corev1.EnvVar{{Name: "CLONE_HOST", Value: clone.Host}},
+				VolumeMounts: append([]corev1.VolumeMount{
+					{
+						Name:      "config-map",
+						MountPath: "/mnt/config-map",
+					},
+				}, dataMounts...),
+			},
+		}, nil
+	}
+	storage := mysqlv1alpha1.ConvertBackupStorageFrom(&bootstrap.Storage)
+	env, volumes, storageMounts := backupStorageAccess(&storage, "backup")
+	volumes = append(volumes, corev1.Volume{
+		Name:         "tools",
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+	toolsMount := corev1.VolumeMount{Name: "tools", MountPath: agentToolsPath}
+	mounts := append([]corev1.VolumeMount{
+		toolsMount,
+		{
+			Name:      "config-map",
+			MountPath: "/mnt/config-map",
+		},
+	}, dataMounts...)
+	env = append(env, corev1.EnvVar{Name: "BACKUP_KEY", Value: bootstrap.Key})
+	if pitr := bootstrap.PointInTime; pitr != nil {
+		env = append(env, corev1.EnvVar{Name: "BINLOG_PREFIX", Value: pitr.BinlogPrefix})
+		if bootstrap.BinlogsSince != nil {
+			env = append(env, corev1.EnvVar{Name: "BINLOGS_SINCE", Value: bootstrap.BinlogsSince.UTC().Format(time.RFC3339)})
+		}
+		mounts = append(mounts, pitrVolumeMount())
+	}
+	return []corev1.Container{
+		{
+			Name:         "install-agent",
+			Image:        agentImage,
+			Command:      []string{"/agent", "install", agentToolsPath},
+			VolumeMounts: []corev1.VolumeMount{toolsMount},
+		},
+		{
+			Name:         "restore-mysql",
+			Image:        "quay.io/sample-mysql-operator/xtrabackup:latest",
+			Command:      []string{"bash", "-c", restoreMySQLScript},
+			Env:          env,
+			VolumeMounts: append(mounts, storageMounts...),
+		},
+	}, volumes
+}
+
+// pitrVolumeMount 는 재생할 바이너리 로그를 내려받는 데이터 볼륨의 경로이다. 데이터 디렉터리 밖에 두어야 MySQL 이 데이터베이스로 보지 않는다
+func pitrVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      "data",
+		MountPath: pitrMountPath,
+		SubPath:   "pitr",
+	}
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql bootstrap", func() {
+	var (
+		mysql  *v1beta1.MySQL
+		backup *v1alpha1.MySQLBackup
+		others []runtime.Object
+		r      *MySQLReconciler
+		req    = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "restored"}}
+	)
+
+	reconcileMySQL := func() {
+		if r == nil {
+			s := scheme.Scheme
+			Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+			Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+			objs := []runtime.Object{
+				mysql,
+				// 복원한 데이터의 root 비밀번호가 담긴 시크릿이다
+				&corev1.Secret{
+					ObjectMeta: v1.ObjectMeta{Name: "sample-root", Namespace: "default"},
+					Data:       map[string][]byte{"password": []byte("root")},
+				},
+			}
+			if backup != nil {
+				objs = append(objs, backup)
+			}
+			objs = append(objs, others...)
+			r = &MySQLReconciler{
+				Client:     fake.NewFakeClientWithScheme(s, objs...),
+				Log:        ctrl.Log.WithName("controllers").WithName("MySQL"),
+				Scheme:     s,
+				Recorder:   record.NewFakeRecorder(10),
+				AgentImage: "controller:latest",
+			}
+		}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+	}
+
+	getStatefulSet := func() (*v12.StatefulSet, error) {
+		sf := &v12.StatefulSet{}
+		return sf, r.Client.Get(context.TODO(), req.NamespacedName, sf)
+	}
+
+	bootstrapCondition := func() string {
+		cond := findCondition(mysql.Status.Conditions, v1beta1.ConditionTypeBootstrap)
+		Expect(cond).ShouldNot(BeNil())
+		return cond.Reason
+	}
+
+	// runningSource 는 복제할 수 있는 원본 클러스터를 만든다
+	runningSource := func(namespace string) *v1beta1.MySQL {
+		lag := int64(0)
+		source := &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{Name: "sample", Namespace: namespace},
+			Spec:       v1beta1.MySQLSpec{Owner: v1beta1.OwnerSpec{Name: "woohyung han"}, Replication: v1beta1.ReplicationSpec{Replicas: 2}},
+			Status: v1beta1.MySQLStatus{
+				Primary: "sample-0",
+				Instances: []v1beta1.InstanceStatus{
+					{Name: "sample-0", Role: rolePrimary},
+					{Name: "sample-1", Role: roleReplica, IOThreadRunning: true, SQLThreadRunning: true, SecondsBehindSource: &lag},
+				},
+			},
+		}
+		source.Status.Conditions.SetCondition(condition.Condition{
+			Type:   v1beta1.ConditionTypeRunning,
+			Status: corev1.ConditionTrue,
+			Reason: "MysqlRunning",
+		})
+		return source
+	}
+
+	BeforeEach(func() {
+		r = nil
+		others = nil
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{Name: "restored", Namespace: "default"},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+				Server: v1beta1.ServerSpec{
+					RootPasswordSecretRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "sample-root"},
+						Key:                  "password",
+					},
+				},
+				Bootstrap: &v1beta1.BootstrapSpec{
+					FromBackup: &v1beta1.BootstrapBackup{Name: "nightly"},
+				},
+			},
+		}
+		backup = &v1alpha1.MySQLBackup{
+			ObjectMeta: v1.ObjectMeta{Name: "nightly", Namespace: "default"},
+			Spec: v1alpha1.MySQLBackupSpec{
+				Cluster: "sample",
+				Storage: v1alpha1.BackupStorage{
+					PersistentVolumeClaim: &v1alpha1.PVCBackupStorage{ClaimName: "backups"},
+				},
+			},
+			Status: v1alpha1.MySQLBackupStatus{Phase: v1alpha1.BackupPhaseRunning},
+		}
+	})
+
+	It("should wait for the backup to complete before creating the StatefulSet", func() {
+		reconcileMySQL()
+		Expect(bootstrapCondition()).Should(Equal("WaitingForBackup"))
+		Expect(mysql.Status.Bootstrap).Should(BeNil())
+		_, err := getStatefulSet()
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("should wait for a backup that does not exist yet", func() {
+		backup = nil
+		reconcileMySQL()
+		Expect(bootstrapCondition()).Should(Equal("BackupNotFound"))
+		_, err := getStatefulSet()
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("should not create the StatefulSet from a failed backup", func() {
+		backup.Status.Phase = v1alpha1.BackupPhaseFailed
+		reconcileMySQL()
+		Expect(bootstrapCondition()).Should(Equal("BackupFailed"))
+		_, err := getStatefulSet()
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("should restore a completed backup into the first primary", func() {
+		backup.Status.Phase = v1alpha1.BackupPhaseCompleted
+		backup.Status.GTIDSet = "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5"
+		reconcileMySQL()
+		Expect(bootstrapCondition()).Should(Equal("Restoring"))
+		Expect(mysql.Status.Bootstrap).Should(Equal(&v1beta1.BootstrapStatus{
+			Backup:  "nightly",
+			Storage: v1alpha1.ConvertBackupStorageTo(&backup.Spec.Storage),
+			Key:     "sample/nightly.xbstream",
+			GTIDSet: backup.Status.GTIDSet,
+		}))
+
+		reconcileMySQL()
+		sf, err := getStatefulSet()
+		Expect(err).ShouldNot(HaveOccurred())
+		var names []string
+		for _, c := range sf.Spec.Template.Spec.InitContainers {
+			names = append(names, c.Name)
+		}
+		Expect(names).Should(Equal([]string{"init-mysql", "install-agent", "restore-mysql", "clone-mysql"}))
+		restore := sf.Spec.Template.Spec.InitContainers[2]
+		Expect(restore.Env).Should(ContainElement(corev1.EnvVar{Name: "BACKUP_KEY", Value: "sample/nightly.xbstream"}))
+		Expect(sf.Spec.Template.Spec.Volumes).Should(ContainElement(corev1.Volume{
+			Name: "backup",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: "backups",
+			}},
+		}))
+	})
+
+	It("should replay archived binlogs up to the recovery target", func() {
+		started, completed, target := v1.Date(2020, 7, 1, 3, 0, 0, 0, time.UTC), v1.Date(2020, 7, 1, 3, 10, 0, 0, time.UTC), v1.Date(2020, 7, 1, 9, 30, 0, 0, time.UTC)
+		backup.Status = v1alpha1.MySQLBackupStatus{Phase: v1alpha1.BackupPhaseCompleted, StartTime: &started, CompletionTime: &completed}
+		mysql.Spec.Bootstrap.FromBackup.PointInTime = &v1beta1.PointInTimeRecovery{Time: &target}
+		reconcileMySQL()
+		Expect(bootstrapCondition()).Should(Equal("Restoring"))
+		Expect(mysql.Status.Bootstrap.PointInTime.BinlogPrefix).Should(Equal("sample/binlog/"))
+		Expect(mysql.Status.Bootstrap.BinlogsSince.Time).Should(BeTemporally("==", started.Time))
+
+		reconcileMySQL()
+		sf, err := getStatefulSet()
+		Expect(err).ShouldNot(HaveOccurred())
+		restore := sf.Spec.Template.Spec.InitContainers[2]
+		Expect(restore.Env).Should(ContainElement(corev1.EnvVar{Name: "BINLOG_PREFIX", Value: "sample/binlog/"}))
+		Expect(restore.Env).Should(ContainElement(corev1.EnvVar{Name: "BINLOGS_SINCE", Value: "2020-07-01T03:00:00Z"}))
+		mysqlContainer, xtrabackup := sf.Spec.Template.Spec.Containers[0], sf.Spec.Template.Spec.Containers[1]
+		Expect(xtrabackup.Env).Should(ContainElement(corev1.EnvVar{Name: "PITR_STOP_DATETIME", Value: "2020-07-01 09:30:00"}))
+		Expect(xtrabackup.VolumeMounts).Should(ContainElement(pitrVolumeMount()))
+		Expect(mysqlContainer.ReadinessProbe.Exec.Command[2]).Should(HavePrefix("test ! -d /var/lib/mysql-pitr/binlogs && "))
+	})
+
+	It("should not recover to a time before the backup completed", func() {
+		completed, target := v1.Date(2020, 7, 1, 3, 10, 0, 0, time.UTC), v1.Date(2020, 7, 1, 3, 0, 0, 0, time.UTC)
+		backup.Status = v1alpha1.MySQLBackupStatus{Phase: v1alpha1.BackupPhaseCompleted, CompletionTime: &completed}
+		mysql.Spec.Bootstrap.FromBackup.PointInTime = &v1beta1.PointInTimeRecovery{Time: &target}
+		reconcileMySQL()
+		Expect(bootstrapCondition()).Should(Equal("InvalidRecoveryTarget"))
+		Expect(mysql.Status.Bootstrap).Should(BeNil())
+	})
+
+	It("should restore a backup given by its storage location", func() {
+		backup = nil
+		mysql.Spec.Bootstrap.FromBackup = &v1beta1.BootstrapBackup{
+			Storage: &v1beta1.BackupStorage{S3: &v1beta1.S3BackupStorage{
+				Endpoint:          "s3.amazonaws.com",
+				Bucket:            "backups",
+				CredentialsSecret: corev1.LocalObjectReference{Name: "s3-credentials"},
+			}},
+			Key: "other/nightly.xbstream",
+		}
+		reconcileMySQL()
+		Expect(mysql.Status.Bootstrap.Backup).Should(BeEmpty())
+		Expect(mysql.Status.Bootstrap.Key).Should(Equal("other/nightly.xbstream"))
+
+		reconcileMySQL()
+		sf, err := getStatefulSet()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(sf.Spec.Template.Spec.InitContainers[2].Env).Should(ContainElement(corev1.EnvVar{Name: "BACKUP_KEY", Value: "other/nightly.xbstream"}))
+	})
+
+	Context("cloning a running cluster", func() {
+		BeforeEach(func() {
+			backup = nil
+			mysql.Spec.Bootstrap = &v1beta1.BootstrapSpec{CloneFrom: &v1beta1.BootstrapClone{Name: "sample"}}
+		})
+
+		It("should clone the first primary from a replica of the source", func() {
+			others = []runtime.Object{runningSource("default")}
+			reconcileMySQL()
+			Expect(bootstrapCondition()).Should(Equal("Restoring"))
+			Expect(mysql.Status.Bootstrap.CloneFrom).Should(Equal(&v1beta1.BootstrapCloneStatus{
+				Name:      "sample",
+				Namespace: "default",
+				Host:      "sample-1.sample.default",
+			}))
+
+			reconcileMySQL()
+			sf, err := getStatefulSet()
+			Expect(err).ShouldNot(HaveOccurred())
+			var names []string
+			for _, c := range sf.Spec.Template.Spec.InitContainers {
+				names = append(names, c.Name)
+			}
+			Expect(names).Should(Equal([]string{"init-mysql", "clone-source", "clone-mysql"}))
+			Expect(sf.Spec.Template.Spec.InitContainers[1].Env).Should(Equal([]corev1.EnvVar{{Name: "CLONE_HOST", Value: "sample-1.sample.default"}}))
+		})
+
+		It("should wait for the source to be running", func() {
+			source := runningSource("default")
+			source.Status.Conditions = nil
+			others = []runtime.Object{source}
+			reconcileMySQL()
+			Expect(bootstrapCondition()).Should(Equal("WaitingForSource"))
+			_, err := getStatefulSet()
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("should wait for the source to be created", func() {
+			reconcileMySQL()
+			Expect(bootstrapCondition()).Should(Equal("SourceNotFound"))
+		})
+
+		It("should require a grant to clone from another namespace", func() {
+			mysql.Spec.Bootstrap.CloneFrom.Namespace = "production"
+			others = []runtime.Object{
+				runningSource("production"),
+				// 다른 네임스페이스나 다른 클러스터에 대한 그랜트는 허용하지 않는다
+				&v1alpha1.MySQLCloneGrant{
+					ObjectMeta: v1.ObjectMeta{Name: "other-namespace", Namespace: "production"},
+					Spec:       v1alpha1.MySQLCloneGrantSpec{Cluster: "sample", Namespaces: []string{"staging"}},
+				},
+				&v1alpha1.MySQLCloneGrant{
+					ObjectMeta: v1.ObjectMeta{Name: "other-cluster", Namespace: "production"},
+					Spec:       v1alpha1.MySQLCloneGrantSpec{Cluster: "other", Namespaces: []string{"default"}},
+				},
+				&v1alpha1.MySQLCloneGrant{
+					ObjectMeta: v1.ObjectMeta{Name: "sample", Namespace: "development"},
+					Spec:       v1alpha1.MySQLCloneGrantSpec{Cluster: "sample", Namespaces: []string{"default"}},
+				},
+			}
+			reconcileMySQL()
+			Expect(bootstrapCondition()).Should(Equal("CloneNotPermitted"))
+			Expect(mysql.Status.Bootstrap).Should(BeNil())
+
+			Expect(r.Client.Create(context.TODO(), &v1alpha1.MySQLCloneGrant{
+				ObjectMeta: v1.ObjectMeta{Name: "default", Namespace: "production"},
+				Spec:       v1alpha1.MySQLCloneGrantSpec{Cluster: "sample", Namespaces: []string{"staging", "default"}},
+			})).Should(Succeed())
+			reconcileMySQL()
+			Expect(bootstrapCondition()).Should(Equal("Restoring"))
+			Expect(mysql.Status.Bootstrap.CloneFrom.Host).Should(Equal("sample-1.sample.production"))
+		})
+	})
+
+	It("should not restore into a cluster that already has a StatefulSet", func() {
+		reconcileMySQL()
+		mysql.Spec.Bootstrap = nil
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+		reconcileMySQL()
+		mysql.Spec.Bootstrap = &v1beta1.BootstrapSpec{FromBackup: &v1beta1.BootstrapBackup{Name: "nightly"}}
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "nightly"}, backup)).Should(Succeed())
+		backup.Status.Phase = v1alpha1.BackupPhaseCompleted
+		Expect(r.Client.Status().Update(context.TODO(), backup)).Should(Succeed())
+		reconcileMySQL()
+		Expect(mysql.Status.Bootstrap).Should(BeNil())
+	})
+})
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+)
+
+const (
+	mib = 1024 * 1024
+	// configHashAnnotation 은 파드 템플릿에 기록하는 설정 해시이다
+	configHashAnnotation = "mysql.sample.com/config-hash"
+	// primaryConfigKey 는 현재 프라이머리 파드의 이름을 기록하는 컨피그맵 키이다. 파드는 시작할 때 이 값으로 역할을 정한다
+	primaryConfigKey = "primary"
+	// gtidConfigKey 는 Status.GTIDMode 에 맞춘 GTID 설정을 기록하는 컨피그맵 키이다. 모든 파드가 역할과 관계없이 사용한다
+	gtidConfigKey = "gtid.cnf"
+)
+
+// replicationOptions 는 모든 파드에 적용하는 복제 설정이다. 레플리카도 바이너리 로그를 기록해야
+// 페일오버 시 프라이머리로 승격되고 나머지 레플리카가 GTID 자동 위치로 따라갈 수 있다
+var replicationOptions = []string{
+	"log-bin",
+	"log-slave-updates",
+}
+
+// configMapName 은 오퍼레이터가 생성하는 MySQL 설정 컨피그맵의 이름을 반환한다
+func configMapName(mysql *mysqlv1beta1.MySQL) string {
+	return mysql.Name + "-config"
+}
+
+func (r *MySQLReconciler) syncConfigMap(mysql *mysqlv1beta1.MySQL) error {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: configMapName(mysql)}, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		r.Log.Info("Could not find config map. Create a new one")
+		return createConfigMap(r, mysql)
+	}
+	// 설정이나 리소스가 변경되었거나 누군가 직접 수정한 경우 생성한 설정으로 되돌린다
+	desired := newConfigMap(mysql)
+	return r.correctDrift(cm, "ConfigMap", cm.Name, func() bool {
+		if reflect.DeepEqual(cm.Data, desired.Data) {
+			return false
+		}
+		cm.Data = desired.Data
+		return true
+	})
+}
+
+func createConfigMap(r *MySQLReconciler, mysql *mysqlv1beta1.MySQL) error {
+	cm := newConfigMap(mysql)
+	if err := controllerutil.SetControllerReference(mysql, cm, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(context.TODO(), cm); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func newConfigMap(mysql *mysqlv1beta1.MySQL) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: mysql.Namespace,
+			Name:      configMapName(mysql),
+		},
+		Data: configMapData(mysql),
+	}
+}
+
+// configMapData 는 역할별 my.cnf 를 생성한다. 같은 옵션이 여러 번 나오면 mysqld 는 마지막 값을 사용하므로
+// 기본 옵션, 자동으로 계산한 설정, 사용자가 지정한 설정 순서로 기록한다
+func configMapData(mysql *mysqlv1beta1.MySQL) map[string]string {
+	primary := append(append([]string{}, replicationOptions...), tuningOptions(mysql)...)
+	replica := append(append([]string{"super-read-only"}, replicationOptions...), tuningOptions(mysql)...)
+	return map[string]string{
+		"master.cnf":     roleConfig("# Apply this config only on the master.", primary, mysql.Spec.Server.Config.Primary),
+		"slave.cnf":      roleConfig("# Apply this config only on slaves.", replica, mysql.Spec.Server.Config.Replica),
+		gtidConfigKey:    roleConfig("# GTID mode managed by sample-mysql-operator.", gtidOptions(mysql.Status.GTIDMode), nil),
+		primaryConfigKey: primaryPodName(mysql),
+	}
+}
+
+// gtidOptions 는 모든 서버가 도달한 gtid_mode 에 맞춘 설정이다. 파드가 다시 시작해도 다른 서버와 같은 단계로 시작하게 한다.
+// ON 이 되기 전의 단계에서는 GTID 와 함께 쓸 수 없는 구문을 오류 대신 경고로 남긴다
+func gtidOptions(mode string) []string {
+	switch mode {
+	case mysqlclient.GTIDModeOffPermissive, mysqlclient.GTIDModeOnPermissive:
+		return []string{"gtid_mode = " + mode, "enforce_gtid_consistency = WARN"}
+	case mysqlclient.GTIDModeOn:
+		return []string{"gtid_mode = ON", "enforce_gtid_consistency = ON"}
+	}
+	return nil
+}
+
+// roleConfig 는 역할별 기본 옵션 뒤에 사용자가 지정한 옵션을 이름 순서대로 기록한다
+func roleConfig(header string, defaults []string, overrides map[string]string) string {
+	var b strings.Builder
+	b.WriteString(header + "\n[mysqld]\n")
+	for _, option := range defaults {
+		b.WriteString(option + "\n")
+	}
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if overrides[key] == "" {
+			b.WriteString(key + "\n")
+			continue
+		}
+		fmt.Fprintf(&b, "%s = %s\n", key, overrides[key])
+	}
+	return b.String()
+}
+
+// configHash 는 컨피그맵 내용의 해시를 반환한다. 파드 템플릿에 기록하여 설정이 바뀌면 파드를 재시작하게 한다.
+// 프라이머리와 GTID 모드가 바뀌는 것은 오퍼레이터가 서버에 직접 반영하므로 파드를 재시작하지 않도록 해시에서 제외한다
+func configHash(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		if key == primaryConfigKey || key == gtidConfigKey {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s\x00%s\x00", key, data[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// tuningOptions 는 mysql 컨테이너의 메모리 제한량(없으면 요청량)에 맞춘 mysqld 옵션을 생성한다
+func tuningOptions(mysql *mysqlv1beta1.MySQL) []string {
+	resources := mysql.Spec.PodTemplate.Resources.MySQL
+	memory, ok := resources.Limits[corev1.ResourceMemory]
+	if !ok {
+		memory, ok = resources.Requests[corev1.ResourceMemory]
+	}
+	if !ok || memory.Value() <= 0 {
+		return nil
+	}
+
+	t := innodbTuning(memory.Value())
+	return []string{
+		fmt.Sprintf("# Tuned by sample-mysql-operator for %s of memory.", memory.String()),
+		fmt.Sprintf("innodb_buffer_pool_size = %dM", t.bufferPoolSize),
+		fmt.Sprintf("innodb_buffer_pool_instances = %d", t.bufferPoolInstances),
+		fmt.Sprintf("innodb_log_file_size = %dM", t.logFileSize),
+		fmt.Sprintf("max_connections = %d", t.maxConnections),
+	}
+}
+
+// tuning 은 메모리 크기로부터 계산한 mysqld 설정이다. 크기의 단위는 MiB 이다
+type tuning struct {
+	bufferPoolSize      int64
+	bufferPoolInstances int64
+	logFileSize         int64
+	maxConnections      int64
+}
+
+// innodbTuning 은 메모리 크기(byte)에 맞추어 버퍼 풀, 리두 로그, 최대 연결 수를 계산한다.
+// 메모리가 작을수록 연결별 버퍼와 운영체제를 위해 남겨두는 비율을 늘린다
+func innodbTuning(memory int64) tuning {
+	memMiB := memory / mib
+
+	var t tuning
+	switch {
+	case memMiB <= 1024:
+		t.bufferPoolSize = memMiB * 50 / 100
+	case memMiB <= 4096:
+		t.bufferPoolSize = memMiB * 60 / 100
+	default:
+		t.bufferPoolSize = memMiB * 75 / 100
+	}
+	// 버퍼 풀은 기본 청크 크기(128M)의 배수로 맞춘다. 청크 하나보다 작으면 128M 로 올렸을 때 메모리 제한을
+	// 넘을 수 있으므로 계산한 크기를 그대로 쓰되, mysqld 가 허용하는 최소 크기(5M)보다 작아지지 않게 한다
+	if t.bufferPoolSize >= 128 {
+		t.bufferPoolSize = t.bufferPoolSize / 128 * 128
+	} else if t.bufferPoolSize < 5 {
+		t.bufferPoolSize = 5
+	}
+	t.bufferPoolInstances = clamp(t.bufferPoolSize/1024, 1, 8)
+	// 두 개의 리두 로그 파일이 버퍼 풀의 25% 정도가 되도록 한다
+	t.logFileSize = clamp(t.bufferPoolSize/8/16*16, 48, 2048)
+	t.maxConnections = clamp(memMiB/16, 50, 4000)
+	return t
+}
+
+func clamp(v, min, max int64) int64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
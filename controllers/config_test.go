@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql config", func() {
+	var (
+		r   *MySQLReconciler
+		req reconcile.Request
+	)
+
+	memoryLimit := func(quantity string) v1beta1.MySQLResources {
+		return v1beta1.MySQLResources{
+			MySQL: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(quantity)},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		mysql := &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+				PodTemplate: v1beta1.PodTemplateSpec{Resources: memoryLimit("2Gi")},
+			},
+		}
+		r = &MySQLReconciler{
+			Client: fake.NewFakeClientWithScheme(s, mysql),
+			Log:    ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme: s,
+		}
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should create a config map with tuned settings", func() {
+		cm := &corev1.ConfigMap{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-config"}, cm)).Should(Succeed())
+		Expect(cm.Data["master.cnf"]).Should(ContainSubstring("innodb_buffer_pool_size = 1152M"))
+		Expect(cm.Data["master.cnf"]).Should(ContainSubstring("max_connections = 128"))
+	})
+
+	It("should set container resources", func() {
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Spec.Containers[0].Resources.Limits.Memory().String()).Should(Equal("2Gi"))
+		Expect(sf.Spec.Template.Spec.InitContainers[0].Command).Should(ContainElement(initMySQLScript))
+	})
+
+	It("should update the config and template when resources change", func() {
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		mysql.Spec.PodTemplate.Resources = memoryLimit("8Gi")
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		cm := &corev1.ConfigMap{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-config"}, cm)).Should(Succeed())
+		Expect(cm.Data["master.cnf"]).Should(ContainSubstring("innodb_buffer_pool_size = 6144M"))
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Spec.Containers[0].Resources.Limits.Memory().String()).Should(Equal("8Gi"))
+	})
+
+	It("should render overrides for each role", func() {
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		mysql.Spec.Server.Config = v1beta1.MySQLConfig{
+			Primary: map[string]string{"sync_binlog": "1", "innodb_buffer_pool_size": "512M"},
+			Replica: map[string]string{"skip-name-resolve": ""},
+		}
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		cm := &corev1.ConfigMap{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-config"}, cm)).Should(Succeed())
+		Expect(cm.Data["master.cnf"]).Should(HaveSuffix("innodb_buffer_pool_size = 512M\nsync_binlog = 1\n"))
+		Expect(cm.Data["master.cnf"]).ShouldNot(ContainSubstring("skip-name-resolve"))
+		Expect(cm.Data["slave.cnf"]).Should(HaveSuffix("\nskip-name-resolve\n"))
+		Expect(cm.Data["slave.cnf"]).Should(ContainSubstring("super-read-only"))
+	})
+
+	It("should mount the config map of the cluster", func() {
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		var names []string
+		for _, volume := range sf.Spec.Template.Spec.Volumes {
+			if volume.ConfigMap != nil {
+				names = append(names, volume.ConfigMap.Name)
+			}
+		}
+		Expect(names).Should(Equal([]string{"sample-config"}))
+	})
+
+	It("should change the config hash when the config changes", func() {
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		hash := sf.Spec.Template.Annotations[configHashAnnotation]
+		Expect(hash).ShouldNot(BeEmpty())
+
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		mysql.Spec.Server.Config.Replica = map[string]string{"slow_query_log": "ON"}
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Annotations[configHashAnnotation]).ShouldNot(Equal(hash))
+	})
+
+	It("should derive settings from the memory size", func() {
+		Expect(innodbTuning(512 * mib)).Should(Equal(tuning{bufferPoolSize: 256, bufferPoolInstances: 1, logFileSize: 48, maxConnections: 50}))
+		Expect(innodbTuning(2048 * mib)).Should(Equal(tuning{bufferPoolSize: 1152, bufferPoolInstances: 1, logFileSize: 144, maxConnections: 128}))
+		Expect(innodbTuning(8192 * mib)).Should(Equal(tuning{bufferPoolSize: 6144, bufferPoolInstances: 6, logFileSize: 768, maxConnections: 512}))
+		Expect(innodbTuning(65536 * mib)).Should(Equal(tuning{bufferPoolSize: 49152, bufferPoolInstances: 8, logFileSize: 2048, maxConnections: 4000}))
+	})
+
+	It("should keep the buffer pool within small memory limits", func() {
+		for _, c := range []struct {
+			memory int64
+			pool   int64
+		}{
+			{memory: 8 * mib, pool: 5},
+			{memory: 64 * mib, pool: 32},
+			{memory: 200 * mib, pool: 100},
+			{memory: 255 * mib, pool: 127},
+			{memory: 256 * mib, pool: 128},
+		} {
+			t := innodbTuning(c.memory)
+			Expect(t.bufferPoolSize).Should(Equal(c.pool), "memory %dMi", c.memory/mib)
+			Expect(t.bufferPoolInstances).Should(Equal(int64(1)))
+			Expect(t.maxConnections).Should(Equal(int64(50)))
+		}
+	})
+
+	It("should render the gtid mode all servers have reached", func() {
+		mysql := &v1beta1.MySQL{}
+		Expect(gtidOptions(mysql.Status.GTIDMode)).Should(BeEmpty())
+		before := configHash(configMapData(mysql))
+
+		mysql.Status.GTIDMode = mysqlclient.GTIDModeOnPermissive
+		Expect(configMapData(mysql)[gtidConfigKey]).Should(HaveSuffix("gtid_mode = ON_PERMISSIVE\nenforce_gtid_consistency = WARN\n"))
+		mysql.Status.GTIDMode = mysqlclient.GTIDModeOn
+		Expect(configMapData(mysql)[gtidConfigKey]).Should(HaveSuffix("gtid_mode = ON\nenforce_gtid_consistency = ON\n"))
+		Expect(configHash(configMapData(mysql))).Should(Equal(before))
+	})
+
+	It("should fall back to defaults without a memory size", func() {
+		Expect(tuningOptions(&v1beta1.MySQL{})).Should(BeEmpty())
+	})
+})
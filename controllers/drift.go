@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// driftCorrections 는 하위 객체가 원하는 상태와 달라져 오퍼레이터가 되돌린 횟수이다
+var driftCorrections = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "mysql_operator_drift_corrections_total",
+		Help: "Number of patches applied to objects owned by a MySQL that differed from their desired state",
+	},
+	[]string{"kind"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(driftCorrections)
+}
+
+// correctDrift 는 mutate 가 객체를 원하는 상태로 바꾼 경우 변경 사항을 패치하고 기록한다.
+// mutate 는 객체가 이미 원하는 상태이면 아무것도 바꾸지 않고 false 를 반환해야 한다
+func (r *MySQLReconciler) correctDrift(obj runtime.Object, kind, name string, mutate func() bool) error {
+	patch := client.MergeFrom(obj.DeepCopyObject())
+	if !mutate() {
+		return nil
+	}
+	r.Log.Info("Correct drift from the desired state", "kind", kind, "name", name)
+	if err := r.Patch(context.TODO(), obj, patch); err != nil {
+		return err
+	}
+	driftCorrections.WithLabelValues(kind).Inc()
+	return nil
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql drift", func() {
+	var (
+		r   *MySQLReconciler
+		req reconcile.Request
+	)
+
+	BeforeEach(func() {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		mysql := &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+			},
+		}
+		r = &MySQLReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, mysql),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme:   s,
+			Recorder: record.NewFakeRecorder(10),
+		}
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should not patch objects in the desired state", func() {
+		before := testutil.ToFloat64(driftCorrections.WithLabelValues("StatefulSet"))
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(testutil.ToFloat64(driftCorrections.WithLabelValues("StatefulSet"))).Should(Equal(before))
+	})
+
+	It("should correct the selector and ports of a service", func() {
+		before := testutil.ToFloat64(driftCorrections.WithLabelValues("Service"))
+		svc := &corev1.Service{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-read"}, svc)).Should(Succeed())
+		svc.Spec.Selector = map[string]string{"app": "other"}
+		svc.Spec.Ports[0].Port = 3307
+		Expect(r.Client.Update(context.TODO(), svc)).Should(Succeed())
+
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-read"}, svc)).Should(Succeed())
+		Expect(svc.Spec.Selector).Should(Equal(map[string]string{"app": "sample"}))
+		Expect(svc.Spec.Ports[0].Port).Should(Equal(int32(3306)))
+		Expect(testutil.ToFloat64(driftCorrections.WithLabelValues("Service"))).Should(Equal(before + 1))
+	})
+
+	It("should correct the pod template of the statefulset", func() {
+		before := testutil.ToFloat64(driftCorrections.WithLabelValues("StatefulSet"))
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		sf.Spec.Template.Spec.Containers[0].Image = "mysql:latest"
+		sf.Spec.Template.Spec.Containers = sf.Spec.Template.Spec.Containers[:1]
+		Expect(r.Client.Update(context.TODO(), sf)).Should(Succeed())
+
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Spec.Containers).Should(HaveLen(2))
+		Expect(sf.Spec.Template.Spec.Containers[0].Image).Should(Equal("mysql:5.7"))
+		Expect(testutil.ToFloat64(driftCorrections.WithLabelValues("StatefulSet"))).Should(Equal(before + 1))
+	})
+
+	It("should remove env vars, mounts and sidecars added to the pod template", func() {
+		before := testutil.ToFloat64(driftCorrections.WithLabelValues("StatefulSet"))
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		mysql := &sf.Spec.Template.Spec.Containers[0]
+		env, mounts := len(mysql.Env), len(mysql.VolumeMounts)
+		mysql.Env = append(mysql.Env, corev1.EnvVar{Name: "DEBUG", Value: "1"})
+		Expect(r.Client.Update(context.TODO(), sf)).Should(Succeed())
+
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		sf = &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Spec.Containers[0].Env).Should(HaveLen(env))
+		Expect(testutil.ToFloat64(driftCorrections.WithLabelValues("StatefulSet"))).Should(Equal(before + 1))
+
+		mysql = &sf.Spec.Template.Spec.Containers[0]
+		mysql.VolumeMounts = append(mysql.VolumeMounts, corev1.VolumeMount{Name: "data", MountPath: "/tmp/data"})
+		sf.Spec.Template.Spec.Containers = append(sf.Spec.Template.Spec.Containers, corev1.Container{Name: "sidecar", Image: "busybox"})
+		Expect(r.Client.Update(context.TODO(), sf)).Should(Succeed())
+
+		_, err = r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		sf = &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Spec.Containers).Should(HaveLen(2))
+		Expect(sf.Spec.Template.Spec.Containers[0].VolumeMounts).Should(HaveLen(mounts))
+		Expect(testutil.ToFloat64(driftCorrections.WithLabelValues("StatefulSet"))).Should(Equal(before + 2))
+	})
+
+	It("should correct the replicas of the statefulset", func() {
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		replicas := int32(5)
+		sf.Spec.Replicas = &replicas
+		Expect(r.Client.Update(context.TODO(), sf)).Should(Succeed())
+
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(*sf.Spec.Replicas).Should(Equal(int32(2)))
+	})
+
+	It("should correct the generated config", func() {
+		cm := &corev1.ConfigMap{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-config"}, cm)).Should(Succeed())
+		desired := cm.Data["master.cnf"]
+		cm.Data["master.cnf"] = "[mysqld]\n"
+		Expect(r.Client.Update(context.TODO(), cm)).Should(Succeed())
+
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-config"}, cm)).Should(Succeed())
+		Expect(cm.Data["master.cnf"]).Should(Equal(desired))
+	})
+	It("should apply the pod template to the statefulset", func() {
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		mysql.Spec.PodTemplate.Metadata.Labels = map[string]string{"team": "db"}
+		mysql.Spec.PodTemplate.NodeSelector = map[string]string{"disktype": "ssd"}
+		mysql.Spec.PodTemplate.PriorityClassName = "high"
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Labels).Should(Equal(map[string]string{"team": "db", "app": "sample"}))
+		Expect(sf.Spec.Template.Spec.NodeSelector).Should(Equal(map[string]string{"disktype": "ssd"}))
+		Expect(sf.Spec.Template.Spec.PriorityClassName).Should(Equal("high"))
+	})
+})
@@ -0,0 +1,370 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+)
+
+const (
+	// failoverTimeout 은 프라이머리가 준비되지 않은 상태로 이 시간이 지나면 페일오버한다
+	failoverTimeout = 60 * time.Second
+	// failoverCheckInterval 은 프라이머리가 준비되지 않은 동안 상태를 다시 확인하는 주기이다
+	failoverCheckInterval = 10 * time.Second
+	// relayLogApplyTimeout 은 승격할 레플리카가 받은 트랜잭션을 모두 적용할 때까지 기다리는 시간이다
+	relayLogApplyTimeout = 30 * time.Second
+)
+
+// syncFailover 는 프라이머리 파드가 failoverTimeout 동안 준비되지 않으면 다른 레플리카의 트랜잭션을 모두 가진 레플리카를 승격하고
+// 나머지 레플리카가 새 프라이머리를 복제하도록 한다
+func (r *MySQLReconciler) syncFailover(mysql *mysqlv1beta1.MySQL) error {
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	oldPrimary := primaryPodName(mysql)
+	var primaryPod *corev1.Pod
+	var candidates []corev1.Pod
+	for i := range pods {
+		if pods[i].Name == oldPrimary {
+			primaryPod = &pods[i]
+		} else if isPodReady(&pods[i]) && pods[i].Status.PodIP != "" {
+			candidates = append(candidates, pods[i])
+		}
+	}
+	primaryReady := primaryPod != nil && isPodReady(primaryPod)
+	if primaryReady {
+		r.rejoinOldPrimary(mysql, pods)
+	}
+	// 업그레이드는 프라이머리를 직접 재시작하고, 준비된 레플리카가 없으면 승격할 대상이 없다.
+	// 승격할 레플리카는 GTID 로 고르므로 모든 서버가 GTID 를 사용하기 전에는 페일오버하지 않는다
+	if primaryReady || len(candidates) == 0 || mysql.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeUpgrading) ||
+		mysql.Status.GTIDMode != mysqlclient.GTIDModeOn {
+		return r.updatePrimaryUnavailableSince(mysql, nil)
+	}
+	if mysql.Status.PrimaryUnavailableSince == nil {
+		r.Log.Info("Primary is not ready", "primary", oldPrimary)
+		now := metav1.Now()
+		return r.updatePrimaryUnavailableSince(mysql, &now)
+	}
+	if time.Since(mysql.Status.PrimaryUnavailableSince.Time) < failoverTimeout {
+		return nil
+	}
+
+	fence, fenced, err := r.fencePrimary(mysql, primaryPod)
+	if err != nil || !fenced {
+		return err
+	}
+	if fence != nil {
+		defer fence.Close()
+	}
+
+	reason := fmt.Sprintf("Primary %s has not been ready since %s", oldPrimary, mysql.Status.PrimaryUnavailableSince.Format(time.RFC3339))
+	r.Recorder.Event(mysql, corev1.EventTypeNormal, "FailoverStarted", reason)
+	newPrimary, err := r.failover(mysql, candidates)
+	if err != nil {
+		// 승격하지 못했으면 읽기 전용으로 바꾼 기존 프라이머리가 다시 쓰기를 받을 수 있도록 되돌린다
+		if fence != nil {
+			if rollbackErr := fence.Promote(context.TODO()); rollbackErr != nil {
+				r.Log.Error(rollbackErr, "Could not make the primary writable again", "pod", oldPrimary)
+			}
+		}
+		r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "FailoverFailed", "Failed to fail over from %s: %v", oldPrimary, err)
+		return err
+	}
+
+	r.Log.Info("Failover completed", "from", oldPrimary, "to", newPrimary)
+	mysql.Status.Primary = newPrimary
+	mysql.Status.PrimaryUnavailableSince = nil
+	mysql.Status.LastFailover = &mysqlv1beta1.FailoverStatus{
+		Time:       metav1.Now(),
+		OldPrimary: oldPrimary,
+		NewPrimary: newPrimary,
+		Reason:     reason,
+	}
+	if err := r.Status().Update(context.TODO(), mysql); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "FailoverCompleted", "Promoted %s to primary in place of %s", newPrimary, oldPrimary)
+	return nil
+}
+
+// fencePrimary 는 레플리카를 승격하기 전에 기존 프라이머리가 쓰기를 받지 못하게 한다. 준비되지 않았을 뿐 동작하고 있는
+// 프라이머리가 쓰기를 계속 받으면 데이터가 갈라지기 때문이다. 접속할 수 있으면 super_read_only 를 켜고 그 연결을 반환한다.
+// 접속할 수 없으면 파드를 삭제하고, 삭제가 끝나 서버가 멈춘 것을 확인할 때까지 false 를 반환한다.
+// 노드와 연결이 끊겨 파드가 삭제되지 않으면 노드가 제거되거나 파드를 강제로 삭제할 때까지 페일오버하지 않는다
+func (r *MySQLReconciler) fencePrimary(mysql *mysqlv1beta1.MySQL, pod *corev1.Pod) (mysqlclient.Client, bool, error) {
+	// mysql 컨테이너가 실행 중이 아니면 쓰기를 받을 서버가 없다
+	if pod == nil || !isContainerRunning(pod, "mysql") {
+		return nil, true, nil
+	}
+	ctx := context.TODO()
+	if pod.DeletionTimestamp == nil {
+		rootPassword, err := r.secretValue(mysql, rootPasswordSecretRef(mysql))
+		if err != nil {
+			return nil, false, err
+		}
+		c, err := r.connect(pod, rootPassword)
+		if err == nil {
+			if err = c.SetReadOnly(ctx); err == nil {
+				r.Log.Info("Made the old primary read-only", "pod", pod.Name)
+				return c, true, nil
+			}
+			c.Close()
+		}
+		r.Log.Info("Could not make the old primary read-only. Delete the pod", "pod", pod.Name, "error", err.Error())
+		if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			return nil, false, err
+		}
+		r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "PrimaryDeleted", "Deleted %s to stop it from accepting writes before failover: %v", pod.Name, err)
+	}
+
+	// 삭제가 끝나기 전에는 서버가 아직 쓰기를 받고 있을 수 있다. 스테이트풀셋이 다시 만든 파드는 새로 시작한 서버이다
+	found := &corev1.Pod{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, found); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+	if found.UID != pod.UID {
+		return nil, true, nil
+	}
+	r.Log.Info("Waiting for the old primary to be deleted before failover", "pod", pod.Name)
+	return nil, false, nil
+}
+
+// rejoinOldPrimary 는 페일오버 뒤에 다시 준비된 기존 프라이머리가 새 프라이머리를 복제하도록 한다. 페일오버 전의 설정으로
+// 다시 시작하여 쓰기를 받고 있을 수 있으므로 먼저 super_read_only 를 켠다. 실패하면 경고 이벤트를 남기고 다음에 다시 시도한다
+func (r *MySQLReconciler) rejoinOldPrimary(mysql *mysqlv1beta1.MySQL, pods []corev1.Pod) {
+	last := mysql.Status.LastFailover
+	primary := primaryPodName(mysql)
+	if last == nil || last.OldPrimary == primary {
+		return
+	}
+	var pod *corev1.Pod
+	for i := range pods {
+		if pods[i].Name == last.OldPrimary && isPodReady(&pods[i]) && pods[i].Status.PodIP != "" {
+			pod = &pods[i]
+		}
+	}
+	if pod == nil {
+		return
+	}
+	if err := r.followPrimary(mysql, pod, primary); err != nil {
+		r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "RejoinFailed", "Failed to make %s follow %s: %v", pod.Name, primary, err)
+	}
+}
+
+// followPrimary 는 파드의 쓰기를 막고 primary 를 복제하도록 한다. 이미 primary 를 복제하고 있으면 아무것도 하지 않는다
+func (r *MySQLReconciler) followPrimary(mysql *mysqlv1beta1.MySQL, pod *corev1.Pod, primary string) error {
+	rootPassword, err := r.secretValue(mysql, rootPasswordSecretRef(mysql))
+	if err != nil {
+		return err
+	}
+	c, err := r.connect(pod, rootPassword)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx := context.TODO()
+	status, err := c.ReplicaStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if status != nil && status.SourceHost == podHost(mysql, primary) {
+		return nil
+	}
+	r.Log.Info("Old primary is back. Make it follow the new primary", "pod", pod.Name, "primary", primary)
+	if err := c.SetReadOnly(ctx); err != nil {
+		return err
+	}
+	user, password, err := r.replicationCredentials(mysql)
+	if err != nil {
+		return err
+	}
+	r.repoint(ctx, mysql, pod.Name, c, primary, user, password)
+	r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "OldPrimaryRejoined", "Made %s read-only and repointed it to %s", pod.Name, primary)
+	return nil
+}
+
+func (r *MySQLReconciler) updatePrimaryUnavailableSince(mysql *mysqlv1beta1.MySQL, since *metav1.Time) error {
+	if (since == nil) == (mysql.Status.PrimaryUnavailableSince == nil) {
+		return nil
+	}
+	mysql.Status.PrimaryUnavailableSince = since
+	return r.Status().Update(context.TODO(), mysql)
+}
+
+// failoverCandidate 는 승격할 수 있는 레플리카와 그 복제 상태이다
+type failoverCandidate struct {
+	pod    *corev1.Pod
+	client mysqlclient.Client
+	status *mysqlclient.ReplicaStatus
+	// gtidSet 은 실행했거나 릴레이 로그로 받은 트랜잭션의 GTID 집합이다
+	gtidSet mysqlclient.GTIDSet
+}
+
+// failover 는 준비된 레플리카 중 다른 레플리카의 트랜잭션을 모두 가진 파드를 승격하고 그 이름을 반환한다
+func (r *MySQLReconciler) failover(mysql *mysqlv1beta1.MySQL, pods []corev1.Pod) (string, error) {
+	rootPassword, err := r.secretValue(mysql, rootPasswordSecretRef(mysql))
+	if err != nil {
+		return "", err
+	}
+	user, password, err := r.replicationCredentials(mysql)
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.TODO()
+	var candidates []*failoverCandidate
+	for i := range pods {
+		c, err := r.connectCandidate(ctx, &pods[i], rootPassword)
+		if err != nil {
+			r.Log.Error(err, "Skip failover candidate", "pod", pods[i].Name)
+			continue
+		}
+		defer c.client.Close()
+		candidates = append(candidates, c)
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no replica is reachable")
+	}
+	best, err := r.selectFailoverCandidate(candidates)
+	if err != nil {
+		return "", err
+	}
+
+	r.Log.Info("Promote replica", "pod", best.pod.Name, "gtidSet", best.gtidSet.String())
+	// 복제가 설정되지 않은 파드는 이전 페일오버에서 이미 승격되었지만 상태를 기록하지 못한 파드일 수 있다
+	if best.status != nil {
+		if err := best.client.StopReplicaIO(ctx); err != nil {
+			return "", err
+		}
+		err := best.client.WaitForExecutedGTIDSet(ctx, best.status.RetrievedGTIDSet, relayLogApplyTimeout)
+		if err == nil {
+			err = best.client.Promote(ctx)
+		}
+		if err != nil {
+			// 승격하지 못했으면 멈춘 복제를 다시 시작하여 레플리카로 되돌린다
+			if rollbackErr := best.client.StartReplica(ctx); rollbackErr != nil {
+				r.Log.Error(rollbackErr, "Could not restart replication", "pod", best.pod.Name)
+			}
+			return "", err
+		}
+	} else if err := best.client.Promote(ctx); err != nil {
+		return "", err
+	}
+
+	host := podHost(mysql, best.pod.Name)
+	for _, c := range candidates {
+		if c == best || c.status != nil && c.status.SourceHost == host {
+			continue
+		}
+		r.repoint(ctx, mysql, c.pod.Name, c.client, best.pod.Name, user, password)
+	}
+	return best.pod.Name, nil
+}
+
+// repoint 는 레플리카가 새 프라이머리를 복제하도록 한다. 바꾸지 못한 레플리카는 다시 시작할 때 컨피그맵의 프라이머리를
+// 따라가므로 실패로 처리하지 않고 경고 이벤트만 남긴다
+func (r *MySQLReconciler) repoint(ctx context.Context, mysql *mysqlv1beta1.MySQL, pod string, c mysqlclient.Client, primary, user, password string) {
+	r.Log.Info("Repoint replica", "pod", pod, "primary", primary)
+	if err := c.SetSource(ctx, podHost(mysql, primary), user, password); err != nil {
+		r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "RepointFailed", "Failed to repoint %s to %s: %v", pod, primary, err)
+	}
+}
+
+// connect 는 파드 IP 로 MySQL 서버에 root 로 접속한다. 오퍼레이터는 다른 네임스페이스에서 동작할 수 있으므로 헤드리스 서비스 이름을 사용하지 않는다
+func (r *MySQLReconciler) connect(pod *corev1.Pod, rootPassword string) (mysqlclient.Client, error) {
+	return r.MySQLClient(pod.Status.PodIP, "root", rootPassword)
+}
+
+func (r *MySQLReconciler) connectCandidate(ctx context.Context, pod *corev1.Pod, rootPassword string) (*failoverCandidate, error) {
+	client, err := r.connect(pod, rootPassword)
+	if err != nil {
+		return nil, err
+	}
+	status, err := client.ReplicaStatus(ctx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	executed, err := client.ExecutedGTIDSet(ctx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	var retrieved string
+	if status != nil {
+		retrieved = status.RetrievedGTIDSet
+	}
+	gtidSet, err := parseGTIDSets(executed, retrieved)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &failoverCandidate{pod: pod, client: client, status: status, gtidSet: gtidSet}, nil
+}
+
+// parseGTIDSets 는 GTID 집합들을 해석하여 합친다
+func parseGTIDSets(gtidSets ...string) (mysqlclient.GTIDSet, error) {
+	union := mysqlclient.GTIDSet{}
+	for _, s := range gtidSets {
+		set, err := mysqlclient.ParseGTIDSet(s)
+		if err != nil {
+			return nil, err
+		}
+		union = union.Union(set)
+	}
+	return union, nil
+}
+
+// selectFailoverCandidate 는 다른 후보의 트랜잭션을 모두 가진 후보를 고른다. 트랜잭션의 수가 같아도 다른 트랜잭션을
+// 가질 수 있으므로 GTID 집합의 포함 관계로 비교한다. 어느 후보와도 포함 관계가 없는 후보는 다른 서버에 없는 트랜잭션을
+// 가진 것이므로 제외한다. 남은 후보 중 나머지를 모두 포함하는 후보가 없으면 어느 후보를 승격해도 커밋된 데이터를 잃으므로
+// 승격하지 않는다. 같은 집합을 가진 후보끼리는 순번이 낮은 후보를 고른다
+func (r *MySQLReconciler) selectFailoverCandidate(candidates []*failoverCandidate) (*failoverCandidate, error) {
+	comparable := func(a, b *failoverCandidate) bool {
+		return a.gtidSet.Contains(b.gtidSet) || b.gtidSet.Contains(a.gtidSet)
+	}
+	var eligible []*failoverCandidate
+	for _, c := range candidates {
+		ok := len(candidates) == 1
+		for _, other := range candidates {
+			if other != c && comparable(c, other) {
+				ok = true
+			}
+		}
+		if !ok {
+			r.Log.Info("Skip failover candidate with transactions no other replica has", "pod", c.pod.Name, "gtidSet", c.gtidSet.String())
+			continue
+		}
+		eligible = append(eligible, c)
+	}
+	sort.Slice(eligible, func(i, j int) bool {
+		return podOrdinal(eligible[i].pod.Name) < podOrdinal(eligible[j].pod.Name)
+	})
+	for _, c := range eligible {
+		containsAll := true
+		for _, other := range eligible {
+			if !c.gtidSet.Contains(other.gtidSet) {
+				containsAll = false
+			}
+		}
+		if containsAll {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("no replica has all transactions of the other replicas")
+}
@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// fakeMySQL 은 MySQL 서버의 복제 상태를 흉내내고 받은 요청을 기록한다
+type fakeMySQL struct {
+	status   *mysqlclient.ReplicaStatus
+	readOnly bool
+	promoted bool
+	stopped  bool
+	source   string
+	// catchUpErr 는 WaitForExecutedGTIDSet 이 반환할 오류이다
+	catchUpErr error
+	// promoteErr 는 Promote 가 반환할 오류이다
+	promoteErr error
+	// gtidMode 는 gtid_mode 의 값이다. 비어 있으면 ON 이다
+	gtidMode string
+	// anonymous 는 GTID 없이 실행 중인 트랜잭션의 수이다
+	anonymous int64
+	// waitedFor 는 WaitForBinaryLogPosition 으로 기다린 바이너리 로그 위치이다
+	waitedFor string
+}
+
+func (f *fakeMySQL) ReplicaStatus(ctx context.Context) (*mysqlclient.ReplicaStatus, error) {
+	return f.status, nil
+}
+
+func (f *fakeMySQL) ExecutedGTIDSet(ctx context.Context) (string, error) {
+	if f.status == nil {
+		return "", nil
+	}
+	return f.status.ExecutedGTIDSet, nil
+}
+
+func (f *fakeMySQL) ReadOnly(ctx context.Context) (bool, bool, error) {
+	return f.readOnly, f.readOnly, nil
+}
+
+func (f *fakeMySQL) SetReadOnly(ctx context.Context) error {
+	f.readOnly = true
+	return nil
+}
+
+func (f *fakeMySQL) StopReplicaIO(ctx context.Context) error {
+	f.status.IORunning = false
+	return nil
+}
+
+func (f *fakeMySQL) StopReplica(ctx context.Context) error {
+	f.stopped = true
+	if f.status != nil {
+		f.status.IORunning = false
+		f.status.SQLRunning = false
+	}
+	return nil
+}
+
+func (f *fakeMySQL) StartReplica(ctx context.Context) error {
+	if f.status != nil {
+		f.status.IORunning = true
+		f.status.SQLRunning = true
+	}
+	return nil
+}
+
+func (f *fakeMySQL) WaitForExecutedGTIDSet(ctx context.Context, gtidSet string, timeout time.Duration) error {
+	return f.catchUpErr
+}
+
+func (f *fakeMySQL) Promote(ctx context.Context) error {
+	if f.promoteErr != nil {
+		return f.promoteErr
+	}
+	f.promoted = true
+	f.readOnly = false
+	f.status = nil
+	return nil
+}
+
+func (f *fakeMySQL) SetSource(ctx context.Context, host, user, password string) error {
+	f.source = host
+	f.readOnly = true
+	f.status = &mysqlclient.ReplicaStatus{SourceHost: host, IORunning: true, SQLRunning: true}
+	return nil
+}
+
+func (f *fakeMySQL) FlushBinaryLogs(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeMySQL) GTIDMode(ctx context.Context) (string, error) {
+	if f.gtidMode == "" {
+		return mysqlclient.GTIDModeOn, nil
+	}
+	return f.gtidMode, nil
+}
+
+func (f *fakeMySQL) SetGTIDMode(ctx context.Context, mode string) error {
+	current, _ := f.GTIDMode(ctx)
+	if mysqlclient.GTIDModeIndex(mode)-mysqlclient.GTIDModeIndex(current) != 1 {
+		return fmt.Errorf("cannot change gtid_mode from %s to %s", current, mode)
+	}
+	f.gtidMode = mode
+	return nil
+}
+
+func (f *fakeMySQL) OngoingAnonymousTransactions(ctx context.Context) (int64, error) {
+	return f.anonymous, nil
+}
+
+func (f *fakeMySQL) BinaryLogPosition(ctx context.Context) (string, int64, error) {
+	return "mysql-bin.000003", 154, nil
+}
+
+func (f *fakeMySQL) WaitForBinaryLogPosition(ctx context.Context, file string, position int64, timeout time.Duration) error {
+	f.waitedFor = fmt.Sprintf("%s:%d", file, position)
+	return nil
+}
+
+func (f *fakeMySQL) Close() error {
+	return nil
+}
+
+var _ = Describe("mysql failover", func() {
+	const uuid = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	var (
+		mysql    *v1beta1.MySQL
+		r        *MySQLReconciler
+		servers  map[string]*fakeMySQL
+		recorder *record.FakeRecorder
+		req      = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	newPod := func(name, ip string, ready bool) *corev1.Pod {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"app": "sample"},
+			},
+			Status: corev1.PodStatus{
+				PodIP:      ip,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+			},
+		}
+	}
+
+	// running 은 파드의 mysql 컨테이너가 실행 중이라고 보고된 상태로 만든다
+	running := func(pod *corev1.Pod) *corev1.Pod {
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  "mysql",
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		}}
+		return pod
+	}
+
+	replica := func(executed, retrieved string) *fakeMySQL {
+		return &fakeMySQL{status: &mysqlclient.ReplicaStatus{
+			SourceHost:       "sample-0.sample",
+			IORunning:        true,
+			SQLRunning:       true,
+			ExecutedGTIDSet:  executed,
+			RetrievedGTIDSet: retrieved,
+		}}
+	}
+
+	// setupWith 는 주어진 오브젝트와 가짜 MySQL 서버로 리컨실러를 만든다
+	setupWith := func(objs ...runtime.Object) {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		r = &MySQLReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, append(objs, mysql)...),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme:   s,
+			Recorder: recorder,
+			MySQLClient: func(host, user, password string) (mysqlclient.Client, error) {
+				if server, ok := servers[host]; ok {
+					return server, nil
+				}
+				return nil, fmt.Errorf("dial tcp %s:3306: connect: connection refused", host)
+			},
+		}
+	}
+
+	reconcileWith := func(objs ...runtime.Object) {
+		setupWith(objs...)
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		// 가짜 클라이언트는 읽은 값을 기존 오브젝트에 덮어쓰므로 비워진 필드가 남지 않도록 새 오브젝트로 읽는다
+		mysql = &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+	}
+
+	candidate := func(name, gtidSet string) *failoverCandidate {
+		set, err := mysqlclient.ParseGTIDSet(gtidSet)
+		Expect(err).ShouldNot(HaveOccurred())
+		return &failoverCandidate{pod: newPod(name, "", true), gtidSet: set}
+	}
+
+	BeforeEach(func() {
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+			},
+			Status: v1beta1.MySQLStatus{
+				Primary: "sample-0",
+			},
+		}
+		servers = map[string]*fakeMySQL{
+			"10.0.0.1": replica(uuid+":1-10", uuid+":8-12"),
+			"10.0.0.2": replica(uuid+":1-11", ""),
+		}
+	})
+
+	It("should wait before failing over", func() {
+		reconcileWith(newPod("sample-0", "10.0.0.0", false), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(mysql.Status.PrimaryUnavailableSince).ShouldNot(BeNil())
+		Expect(servers["10.0.0.1"].promoted).Should(BeFalse())
+	})
+
+	It("should not fail over while the primary is ready", func() {
+		since := v1.NewTime(time.Now().Add(-time.Hour))
+		mysql.Status.PrimaryUnavailableSince = &since
+		reconcileWith(newPod("sample-0", "10.0.0.0", true), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(mysql.Status.PrimaryUnavailableSince).Should(BeNil())
+	})
+
+	It("should promote the most up-to-date replica", func() {
+		since := v1.NewTime(time.Now().Add(-2 * failoverTimeout))
+		mysql.Status.PrimaryUnavailableSince = &since
+		reconcileWith(newPod("sample-0", "10.0.0.0", false), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		Expect(servers["10.0.0.1"].promoted).Should(BeTrue())
+		Expect(servers["10.0.0.2"].promoted).Should(BeFalse())
+		Expect(servers["10.0.0.2"].source).Should(Equal("sample-1.sample"))
+
+		Expect(mysql.Status.Primary).Should(Equal("sample-1"))
+		Expect(mysql.Status.PrimaryUnavailableSince).Should(BeNil())
+		Expect(mysql.Status.LastFailover).ShouldNot(BeNil())
+		Expect(mysql.Status.LastFailover.OldPrimary).Should(Equal("sample-0"))
+		Expect(mysql.Status.LastFailover.NewPrimary).Should(Equal("sample-1"))
+		Expect(recorder.Events).Should(Receive(ContainSubstring("FailoverStarted")))
+		Expect(recorder.Events).Should(Receive(ContainSubstring("FailoverCompleted")))
+
+		cm := &corev1.ConfigMap{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-config"}, cm)).Should(Succeed())
+		Expect(cm.Data[primaryConfigKey]).Should(Equal("sample-1"))
+		pod := &corev1.Pod{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-1"}, pod)).Should(Succeed())
+		Expect(pod.Labels[roleLabel]).Should(Equal(rolePrimary))
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-0"}, pod)).Should(Succeed())
+		Expect(pod.Labels[roleLabel]).Should(Equal(roleReplica))
+	})
+
+	It("should prefer the lowest ordinal between equal replicas", func() {
+		servers["10.0.0.1"] = replica(uuid+":1-11", "")
+		since := v1.NewTime(time.Now().Add(-2 * failoverTimeout))
+		mysql.Status.PrimaryUnavailableSince = &since
+		reconcileWith(newPod("sample-0", "10.0.0.0", false), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		Expect(mysql.Status.Primary).Should(Equal("sample-1"))
+		Expect(servers["10.0.0.2"].promoted).Should(BeFalse())
+	})
+
+	It("should not promote a fresh replica that has no transactions", func() {
+		// sample-1 은 방금 준비되어 아직 프라이머리를 복제하도록 설정되지 않았다
+		servers["10.0.0.1"] = &fakeMySQL{}
+		since := v1.NewTime(time.Now().Add(-2 * failoverTimeout))
+		mysql.Status.PrimaryUnavailableSince = &since
+		reconcileWith(newPod("sample-0", "10.0.0.0", false), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		Expect(mysql.Status.Primary).Should(Equal("sample-2"))
+		Expect(servers["10.0.0.2"].promoted).Should(BeTrue())
+		Expect(servers["10.0.0.1"].promoted).Should(BeFalse())
+		Expect(servers["10.0.0.1"].source).Should(Equal("sample-2.sample"))
+	})
+
+	It("should restart replication on the candidate when the promotion fails", func() {
+		servers["10.0.0.1"].catchUpErr = fmt.Errorf("timed out")
+		since := v1.NewTime(time.Now().Add(-2 * failoverTimeout))
+		mysql.Status.PrimaryUnavailableSince = &since
+		setupWith(newPod("sample-0", "10.0.0.0", false), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+		_, err := r.Reconcile(req)
+		Expect(err).Should(HaveOccurred())
+
+		Expect(servers["10.0.0.1"].promoted).Should(BeFalse())
+		Expect(servers["10.0.0.1"].status.IORunning).Should(BeTrue())
+		Expect(servers["10.0.0.1"].status.SQLRunning).Should(BeTrue())
+		mysql = &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(recorder.Events).Should(Receive(ContainSubstring("FailoverStarted")))
+		Expect(recorder.Events).Should(Receive(ContainSubstring("FailoverFailed")))
+	})
+
+	It("should choose the replica that has the transactions of all the others", func() {
+		r = &MySQLReconciler{Log: ctrl.Log.WithName("controllers").WithName("MySQL")}
+		const other = "4f22fb58-82db-22f2-af44-d91bba53a673"
+
+		By("comparing the transactions instead of counting them")
+		best, err := r.selectFailoverCandidate([]*failoverCandidate{
+			candidate("sample-1", uuid+":1-10"),
+			candidate("sample-2", uuid+":1-5:7-11"),
+			candidate("sample-3", uuid+":1-11"),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(best.pod.Name).Should(Equal("sample-3"))
+
+		By("skipping a replica that is not comparable with any other")
+		best, err = r.selectFailoverCandidate([]*failoverCandidate{
+			candidate("sample-1", other+":1"),
+			candidate("sample-2", uuid+":1-10"),
+			candidate("sample-3", uuid+":1-11"),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(best.pod.Name).Should(Equal("sample-3"))
+
+		By("refusing to promote when every choice loses transactions")
+		_, err = r.selectFailoverCandidate([]*failoverCandidate{
+			candidate("sample-1", uuid+":1-5"),
+			candidate("sample-2", uuid+":1-4:6"),
+			candidate("sample-3", uuid+":1-3"),
+		})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should make a running primary read-only before promoting a replica", func() {
+		servers["10.0.0.0"] = &fakeMySQL{}
+		since := v1.NewTime(time.Now().Add(-2 * failoverTimeout))
+		mysql.Status.PrimaryUnavailableSince = &since
+		reconcileWith(running(newPod("sample-0", "10.0.0.0", false)), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		Expect(servers["10.0.0.0"].readOnly).Should(BeTrue())
+		Expect(servers["10.0.0.1"].promoted).Should(BeTrue())
+		Expect(mysql.Status.Primary).Should(Equal("sample-1"))
+	})
+
+	It("should delete an unreachable primary before promoting a replica", func() {
+		since := v1.NewTime(time.Now().Add(-2 * failoverTimeout))
+		mysql.Status.PrimaryUnavailableSince = &since
+		reconcileWith(running(newPod("sample-0", "10.0.0.0", false)), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-0"}, &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+		Expect(recorder.Events).Should(Receive(ContainSubstring("PrimaryDeleted")))
+		Expect(servers["10.0.0.1"].promoted).Should(BeTrue())
+		Expect(mysql.Status.Primary).Should(Equal("sample-1"))
+	})
+
+	It("should wait for the old primary to be deleted before promoting a replica", func() {
+		since := v1.NewTime(time.Now().Add(-2 * failoverTimeout))
+		mysql.Status.PrimaryUnavailableSince = &since
+		deleted := v1.Now()
+		primary := running(newPod("sample-0", "10.0.0.0", false))
+		primary.DeletionTimestamp = &deleted
+		reconcileWith(primary, newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		Expect(servers["10.0.0.1"].promoted).Should(BeFalse())
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(mysql.Status.PrimaryUnavailableSince).ShouldNot(BeNil())
+	})
+
+	It("should make the old primary follow the new primary when it comes back", func() {
+		mysql.Status.Primary = "sample-1"
+		mysql.Status.LastFailover = &v1beta1.FailoverStatus{Time: v1.Now(), OldPrimary: "sample-0", NewPrimary: "sample-1"}
+		// 기존 프라이머리가 페일오버 전의 설정으로 다시 시작하여 쓰기를 받고 있다
+		servers["10.0.0.0"] = &fakeMySQL{}
+		servers["10.0.0.1"] = &fakeMySQL{}
+		reconcileWith(newPod("sample-0", "10.0.0.0", true), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		Expect(servers["10.0.0.0"].readOnly).Should(BeTrue())
+		Expect(servers["10.0.0.0"].source).Should(Equal("sample-1.sample"))
+		Expect(servers["10.0.0.1"].readOnly).Should(BeFalse())
+		Expect(mysql.Status.Primary).Should(Equal("sample-1"))
+		Expect(recorder.Events).Should(Receive(ContainSubstring("OldPrimaryRejoined")))
+	})
+
+	It("should not fail over during an upgrade", func() {
+		since := v1.NewTime(time.Now().Add(-2 * failoverTimeout))
+		mysql.Status.PrimaryUnavailableSince = &since
+		mysql.Status.Conditions.SetCondition(condition.Condition{
+			Type:   v1beta1.ConditionTypeUpgrading,
+			Status: corev1.ConditionTrue,
+			Reason: "RollingPods",
+		})
+		reconcileWith(newPod("sample-0", "10.0.0.0", false), newPod("sample-1", "10.0.0.1", true), newPod("sample-2", "10.0.0.2", true))
+
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(servers["10.0.0.1"].promoted).Should(BeFalse())
+	})
+
+	It("should not change the config hash on failover", func() {
+		before := configHash(configMapData(mysql))
+		mysql.Status.Primary = "sample-2"
+		Expect(configHash(configMapData(mysql))).Should(Equal(before))
+	})
+})
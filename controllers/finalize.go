@@ -2,32 +2,257 @@ package controllers
 
 import (
 	"context"
-	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
 )
 
-// MysqlFinalizer is string for mysql finalizer
-const MysqlFinalizer = "finalizer.sample-mysql-operator.com"
-
-func (r *MySQLReconciler) finalize(mysql *mysqlv1alpha1.MySQL) error {
-	// MySQL이 삭제되었는지 확인
-	isMysqlMarkedToBeDeleted := mysql.GetDeletionTimestamp() != nil
-	if isMysqlMarkedToBeDeleted {
-		// 파이널라이저가 남아있는 경우
-		if contains(mysql.GetFinalizers(), MysqlFinalizer) {
-			// 클린업 로직 수행
-			if err := cleanUp(r, mysql); err != nil {
-				return err
+const (
+	// MysqlFinalizer is string for mysql finalizer
+	MysqlFinalizer = "finalizer.sample-mysql-operator.com"
+	// retainedLabel 은 Retain 정책으로 남겨둔 데이터 볼륨 클레임에 붙이는 레이블이다. 값은 클러스터 이름이다
+	retainedLabel = "mysql.sample.com/retained-from"
+	// retainedAtAnnotation 은 데이터 볼륨 클레임을 남겨둔 시각이다
+	retainedAtAnnotation = "mysql.sample.com/retained-at"
+	// finalBackupLabel 은 삭제하기 전에 받은 마지막 백업에 붙이는 클러스터 이름 레이블이다
+	finalBackupLabel = "mysql.sample.com/final-backup-of"
+	// finalBackupCheckInterval 은 마지막 백업이 끝났는지 확인하는 주기이다
+	finalBackupCheckInterval = 15 * time.Second
+)
+
+// ensureFinalizer 는 삭제할 때 데이터 볼륨을 정리할 수 있도록 파이널라이저를 추가한다
+func (r *MySQLReconciler) ensureFinalizer(mysql *mysqlv1beta1.MySQL) error {
+	if contains(mysql.GetFinalizers(), MysqlFinalizer) {
+		return nil
+	}
+	controllerutil.AddFinalizer(mysql, MysqlFinalizer)
+	return r.Update(context.TODO(), mysql)
+}
+
+// finalize 는 삭제 정책에 따라 데이터 볼륨을 정리한 뒤 파이널라이저를 제거한다.
+// 마지막 백업을 기다리는 동안이나 백업에 실패한 경우에는 Deleting 컨디션에 이유를 남기고 파이널라이저를 유지한다
+func (r *MySQLReconciler) finalize(mysql *mysqlv1beta1.MySQL) (ctrl.Result, error) {
+	if !contains(mysql.GetFinalizers(), MysqlFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	switch deletionPolicy(mysql) {
+	case mysqlv1beta1.DeletionPolicyBackupThenDelete:
+		done, err := r.syncFinalBackup(mysql)
+		if err != nil || !done {
+			return ctrl.Result{RequeueAfter: finalBackupCheckInterval}, err
+		}
+		if err := r.deleteDataClaims(mysql); err != nil {
+			return ctrl.Result{}, err
+		}
+	case mysqlv1beta1.DeletionPolicyDelete:
+		if err := r.deleteDataClaims(mysql); err != nil {
+			return ctrl.Result{}, err
+		}
+	default:
+		if err := r.retainDataClaims(mysql); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	r.Log.Info("Successfully clean up", "policy", deletionPolicy(mysql))
+	controllerutil.RemoveFinalizer(mysql, MysqlFinalizer)
+	return ctrl.Result{}, r.Update(context.TODO(), mysql)
+}
+
+// blockDeletion 은 웹훅을 거치지 않고 삭제가 요청된 보호된 클러스터를 Deleting 컨디션과 이벤트로 알린다.
+// 파이널라이저가 남아 있는 동안 클러스터는 평소처럼 동작하지만, foreground 삭제는 쿠버네티스가 스테이트풀셋과 서비스 같은
+// 하위 리소스를 먼저 지우므로 오퍼레이터가 막을 수 없다. 이 경우 지워지는 리소스를 다시 만들지 않도록 true 를 반환한다.
+// 데이터 볼륨 클레임은 클러스터가 소유하지 않으므로 어느 경우에도 남는다
+func (r *MySQLReconciler) blockDeletion(mysql *mysqlv1beta1.MySQL) (bool, error) {
+	foreground := contains(mysql.GetFinalizers(), metav1.FinalizerDeleteDependents)
+	reason, message := "DeletionProtected", "Deletion is blocked until spec.deletionProtection is set to false"
+	if foreground {
+		reason = "ForegroundDeletionProtected"
+		message += "; foreground deletion removes the StatefulSet, pods and services first and can not be stopped, but the data volume claims are kept"
+	}
+	if cond := findCondition(mysql.Status.Conditions, mysqlv1beta1.ConditionTypeDeleting); cond != nil && cond.Reason == reason {
+		return foreground, nil
+	}
+	r.Recorder.Event(mysql, corev1.EventTypeWarning, reason, message)
+	return foreground, r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeDeleting,
+		Status:  corev1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// deletionPolicy 는 삭제 정책을 반환한다. 웹훅을 거치지 않아 비어 있으면 Retain 이다
+func deletionPolicy(mysql *mysqlv1beta1.MySQL) mysqlv1beta1.DeletionPolicy {
+	if mysql.Spec.DeletionPolicy == "" {
+		return mysqlv1beta1.DeletionPolicyRetain
+	}
+	return mysql.Spec.DeletionPolicy
+}
+
+// finalBackupName 은 삭제하기 전에 받는 마지막 백업의 이름이다. 같은 이름의 클러스터를 다시 만들어 삭제해도 겹치지 않도록 삭제 시각을 붙인다
+func finalBackupName(mysql *mysqlv1beta1.MySQL) string {
+	return fmt.Sprintf("%s-final-%s", mysql.Name, mysql.GetDeletionTimestamp().UTC().Format("20060102-150405"))
+}
+
+// syncFinalBackup 은 프라이머리에서 마지막 백업을 받고 완료되었는지 반환한다. 백업은 클러스터와 함께 지워지지 않도록 소유자를 지정하지 않는다
+func (r *MySQLReconciler) syncFinalBackup(mysql *mysqlv1beta1.MySQL) (bool, error) {
+	if mysql.Spec.Backup.FinalBackupStorage == nil {
+		return false, r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeDeleting,
+			Status:  corev1.ConditionFalse,
+			Reason:  "FinalBackupStorageMissing",
+			Message: "spec.backup.finalBackupStorage is required to take the final backup; set it or change spec.deletionPolicy",
+		})
+	}
+
+	name := finalBackupName(mysql)
+	backup := &mysqlv1alpha1.MySQLBackup{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: name}, backup); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		backup = &mysqlv1alpha1.MySQLBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: mysql.Namespace,
+				Labels:    map[string]string{finalBackupLabel: mysql.Name},
+			},
+			Spec: mysqlv1alpha1.MySQLBackupSpec{
+				Cluster: mysql.Name,
+				// 레플리카는 지연될 수 있으므로 마지막 트랜잭션까지 담기 위해 프라이머리에서 받는다
+				Source:  mysqlv1alpha1.BackupSourcePrimary,
+				Storage: mysqlv1alpha1.ConvertBackupStorageFrom(mysql.Spec.Backup.FinalBackupStorage),
+			},
+		}
+		// 클러스터가 삭제된 뒤에도 백업의 소유자를 알 수 있도록 소유자 정보를 함께 기록한다
+		setOwnerMetadata(backup, mysql)
+		r.Log.Info("Create final backup", "backup", name)
+		if err := r.Create(context.TODO(), backup); err != nil && !errors.IsAlreadyExists(err) {
+			return false, err
+		}
+		r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "FinalBackupStarted", "Taking final backup %s before deleting volumes", name)
+	}
+
+	switch backup.Status.Phase {
+	case mysqlv1alpha1.BackupPhaseCompleted:
+		r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "FinalBackupCompleted", "Final backup %s stored at %s", name, backup.Status.Location)
+		return true, nil
+	case mysqlv1alpha1.BackupPhaseFailed:
+		return false, r.setCondition(mysql, condition.Condition{
+			Type:   mysqlv1beta1.ConditionTypeDeleting,
+			Status: corev1.ConditionFalse,
+			Reason: "FinalBackupFailed",
+			Message: fmt.Sprintf("Final backup %s failed: %s. Delete the backup to retry, or change spec.deletionPolicy to Delete or Retain",
+				name, backup.Status.Message),
+		})
+	}
+	return false, r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeDeleting,
+		Status:  corev1.ConditionTrue,
+		Reason:  "BackingUp",
+		Message: fmt.Sprintf("Waiting for final backup %s to complete before deleting volumes", name),
+	})
+}
+
+// dataClaims 는 클러스터의 데이터 볼륨 클레임을 반환한다. 축소되어 파드가 없는 순번의 클레임도 포함한다
+func (r *MySQLReconciler) dataClaims(mysql *mysqlv1beta1.MySQL) ([]corev1.PersistentVolumeClaim, error) {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(context.TODO(), pvcs, client.InNamespace(mysql.Namespace), client.MatchingLabels{"app": mysql.Name}); err != nil {
+		return nil, err
+	}
+	var claims []corev1.PersistentVolumeClaim
+	for _, pvc := range pvcs.Items {
+		if strings.HasPrefix(pvc.Name, "data-"+mysql.Name+"-") {
+			claims = append(claims, pvc)
+		}
+	}
+	return claims, nil
+}
+
+// deleteDataClaims 는 파드가 볼륨을 놓도록 스테이트풀셋을 먼저 삭제한 뒤 데이터 볼륨 클레임을 삭제한다
+func (r *MySQLReconciler) deleteDataClaims(mysql *mysqlv1beta1.MySQL) error {
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: mysql.Name, Namespace: mysql.Namespace}}
+	if err := r.Delete(context.TODO(), sts); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	claims, err := r.dataClaims(mysql)
+	if err != nil {
+		return err
+	}
+	for i := range claims {
+		r.Log.Info("Delete persistent volume claim", "pvc", claims[i].Name)
+		if err := r.Delete(context.TODO(), &claims[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "VolumesDeleted", "Deleted %d data volume claim(s)", len(claims))
+	return nil
+}
+
+// retainDataClaims 는 데이터 볼륨 클레임에 남겨둔 클러스터와 시각을 기록하고 클러스터와의 소유 관계를 끊는다.
+// 같은 이름의 MySQL 을 다시 만들면 스테이트풀셋이 이름으로 클레임을 찾아 그대로 사용한다
+func (r *MySQLReconciler) retainDataClaims(mysql *mysqlv1beta1.MySQL) error {
+	claims, err := r.dataClaims(mysql)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i := range claims {
+		pvc := &claims[i]
+		patch := client.MergeFrom(pvc.DeepCopy())
+		if pvc.Labels == nil {
+			pvc.Labels = map[string]string{}
+		}
+		pvc.Labels[retainedLabel] = mysql.Name
+		if pvc.Annotations == nil {
+			pvc.Annotations = map[string]string{}
+		}
+		pvc.Annotations[retainedAtAnnotation] = now
+		var refs []metav1.OwnerReference
+		for _, ref := range pvc.OwnerReferences {
+			if ref.UID != mysql.UID {
+				refs = append(refs, ref)
 			}
-			// 파이널라이저를 제거
-			controllerutil.RemoveFinalizer(mysql, MysqlFinalizer)
-			return r.Update(context.TODO(), mysql)
+		}
+		pvc.OwnerReferences = refs
+		if err := r.Patch(context.TODO(), pvc, patch); err != nil {
+			return err
 		}
 	}
-	// 파이널라이저가 없는 경우 추가
-	if !contains(mysql.GetFinalizers(), MysqlFinalizer) {
-		controllerutil.AddFinalizer(mysql, MysqlFinalizer)
-		return r.Update(context.TODO(), mysql)
+	r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "VolumesRetained", "Retained %d data volume claim(s)", len(claims))
+	return nil
+}
+
+// adoptRetainedClaims 는 삭제된 같은 이름의 클러스터가 남겨둔 데이터 볼륨 클레임을 다시 사용하게 되면 남겨둔 기록을 지운다
+func (r *MySQLReconciler) adoptRetainedClaims(mysql *mysqlv1beta1.MySQL) error {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(context.TODO(), pvcs, client.InNamespace(mysql.Namespace), client.MatchingLabels{retainedLabel: mysql.Name}); err != nil {
+		return err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		r.Log.Info("Adopt retained persistent volume claim", "pvc", pvc.Name, "retainedAt", pvc.Annotations[retainedAtAnnotation])
+		patch := client.MergeFrom(pvc.DeepCopy())
+		delete(pvc.Labels, retainedLabel)
+		delete(pvc.Annotations, retainedAtAnnotation)
+		if err := r.Patch(context.TODO(), pvc, patch); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -40,9 +265,3 @@ func contains(list []string, s string) bool {
 	}
 	return false
 }
-
-func cleanUp(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
-	// 추후 MySQL을 제거할 때 필요한 로직이 있으면 여기에 추가한다
-	r.Log.Info("Successfully clean up", "mysql", mysql)
-	return nil
-}
@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql finalizer", func() {
+	var (
+		r   *MySQLReconciler
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	newClaim := func(name string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "sample"}},
+		}
+	}
+
+	// setup 은 삭제가 요청된 클러스터와 데이터 볼륨 클레임을 만든다
+	setup := func(policy v1beta1.DeletionPolicy) {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		deletedAt := v1.NewTime(time.Date(2020, 6, 1, 3, 0, 0, 0, time.UTC))
+		mysql := &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:              "sample",
+				Namespace:         "default",
+				Finalizers:        []string{MysqlFinalizer},
+				DeletionTimestamp: &deletedAt,
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication:    v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:          v1beta1.OwnerSpec{Name: "woohyung han"},
+				DeletionPolicy: policy,
+				Backup: v1beta1.BackupSpec{
+					FinalBackupStorage: &v1beta1.BackupStorage{
+						PersistentVolumeClaim: &v1beta1.PVCBackupStorage{ClaimName: "backups"},
+					},
+				},
+			},
+		}
+		objs := []runtime.Object{
+			mysql,
+			&v12.StatefulSet{ObjectMeta: v1.ObjectMeta{Name: "sample", Namespace: "default"}},
+			newClaim("data-sample-0"),
+			newClaim("data-sample-1"),
+			// 축소되어 파드가 없는 순번의 클레임도 정리한다
+			newClaim("data-sample-2"),
+			// 같은 레이블이어도 데이터 볼륨이 아닌 클레임은 건드리지 않는다
+			newClaim("logs-sample"),
+		}
+		r = &MySQLReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, objs...),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme:   s,
+			Recorder: record.NewFakeRecorder(10),
+		}
+	}
+
+	reconcileMySQL := func() ctrl.Result {
+		result, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		return result
+	}
+
+	getMySQL := func() *v1beta1.MySQL {
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		return mysql
+	}
+
+	claimExists := func(name string) bool {
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, &corev1.PersistentVolumeClaim{})
+		if errors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).ShouldNot(HaveOccurred())
+		return true
+	}
+
+	configMapExists := func() bool {
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-config"}, &corev1.ConfigMap{})
+		if errors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).ShouldNot(HaveOccurred())
+		return true
+	}
+
+	getFinalBackup := func() (*v1alpha1.MySQLBackup, error) {
+		backup := &v1alpha1.MySQLBackup{}
+		return backup, r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-final-20200601-030000"}, backup)
+	}
+
+	setBackupPhase := func(phase v1alpha1.BackupPhase, message string) {
+		backup, err := getFinalBackup()
+		Expect(err).ShouldNot(HaveOccurred())
+		backup.Status.Phase = phase
+		backup.Status.Message = message
+		Expect(r.Client.Status().Update(context.TODO(), backup)).Should(Succeed())
+	}
+
+	deletingCondition := func() string {
+		cond := findCondition(getMySQL().Status.Conditions, v1beta1.ConditionTypeDeleting)
+		Expect(cond).ShouldNot(BeNil())
+		return cond.Reason
+	}
+
+	It("Should add the finalizer to a new cluster", func() {
+		setup(v1beta1.DeletionPolicyRetain)
+		mysql := getMySQL()
+		mysql.DeletionTimestamp = nil
+		mysql.Finalizers = nil
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+
+		reconcileMySQL()
+		Expect(getMySQL().Finalizers).Should(ContainElement(MysqlFinalizer))
+	})
+
+	It("Should delete the data volume claims with the Delete policy", func() {
+		setup(v1beta1.DeletionPolicyDelete)
+		reconcileMySQL()
+
+		Expect(getMySQL().Finalizers).ShouldNot(ContainElement(MysqlFinalizer))
+		Expect(claimExists("data-sample-0")).Should(BeFalse())
+		Expect(claimExists("data-sample-1")).Should(BeFalse())
+		Expect(claimExists("data-sample-2")).Should(BeFalse())
+		Expect(claimExists("logs-sample")).Should(BeTrue())
+		err := r.Client.Get(context.TODO(), req.NamespacedName, &v12.StatefulSet{})
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("Should label the data volume claims with the Retain policy and adopt them again", func() {
+		setup("")
+		reconcileMySQL()
+
+		Expect(getMySQL().Finalizers).ShouldNot(ContainElement(MysqlFinalizer))
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "data-sample-0"}, pvc)).Should(Succeed())
+		Expect(pvc.Labels).Should(HaveKeyWithValue(retainedLabel, "sample"))
+		Expect(pvc.Annotations).Should(HaveKey(retainedAtAnnotation))
+		Expect(claimExists("data-sample-1")).Should(BeTrue())
+
+		By("recreating a cluster with the same name")
+		mysql := getMySQL()
+		mysql.DeletionTimestamp = nil
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+		reconcileMySQL()
+		// 가짜 클라이언트는 읽은 값을 기존 오브젝트에 덮어쓰므로 지워진 레이블이 남지 않도록 새 오브젝트로 읽는다
+		pvc = &corev1.PersistentVolumeClaim{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "data-sample-0"}, pvc)).Should(Succeed())
+		Expect(pvc.Labels).ShouldNot(HaveKey(retainedLabel))
+		Expect(pvc.Annotations).ShouldNot(HaveKey(retainedAtAnnotation))
+	})
+
+	It("Should take a final backup before deleting the data volume claims", func() {
+		setup(v1beta1.DeletionPolicyBackupThenDelete)
+		Expect(reconcileMySQL().RequeueAfter).Should(Equal(finalBackupCheckInterval))
+
+		backup, err := getFinalBackup()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(backup.Spec.Cluster).Should(Equal("sample"))
+		Expect(backup.Spec.Source).Should(Equal(v1alpha1.BackupSourcePrimary))
+		Expect(backup.Spec.Storage.PersistentVolumeClaim.ClaimName).Should(Equal("backups"))
+		Expect(backup.Labels).Should(HaveKeyWithValue(finalBackupLabel, "sample"))
+		Expect(backup.OwnerReferences).Should(BeEmpty())
+		Expect(deletingCondition()).Should(Equal("BackingUp"))
+		Expect(getMySQL().Finalizers).Should(ContainElement(MysqlFinalizer))
+		Expect(claimExists("data-sample-0")).Should(BeTrue())
+
+		setBackupPhase(v1alpha1.BackupPhaseCompleted, "")
+		reconcileMySQL()
+		Expect(getMySQL().Finalizers).ShouldNot(ContainElement(MysqlFinalizer))
+		Expect(claimExists("data-sample-0")).Should(BeFalse())
+		_, err = getFinalBackup()
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("Should keep the finalizer and report when the final backup fails", func() {
+		setup(v1beta1.DeletionPolicyBackupThenDelete)
+		reconcileMySQL()
+		setBackupPhase(v1alpha1.BackupPhaseFailed, "xtrabackup exited with 1")
+
+		reconcileMySQL()
+		Expect(deletingCondition()).Should(Equal("FinalBackupFailed"))
+		Expect(findCondition(getMySQL().Status.Conditions, v1beta1.ConditionTypeDeleting).Message).Should(ContainSubstring("xtrabackup exited with 1"))
+		Expect(getMySQL().Finalizers).Should(ContainElement(MysqlFinalizer))
+		Expect(claimExists("data-sample-0")).Should(BeTrue())
+
+		By("switching to the Retain policy")
+		mysql := getMySQL()
+		mysql.Spec.DeletionPolicy = v1beta1.DeletionPolicyRetain
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+		reconcileMySQL()
+		Expect(getMySQL().Finalizers).ShouldNot(ContainElement(MysqlFinalizer))
+		Expect(claimExists("data-sample-0")).Should(BeTrue())
+	})
+
+	It("Should keep the data of a protected cluster deleted without the webhook", func() {
+		setup(v1beta1.DeletionPolicyDelete)
+		mysql := getMySQL()
+		protected := true
+		mysql.Spec.DeletionProtection = &protected
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+
+		Expect(reconcileMySQL().RequeueAfter).Should(Equal(topologyRefreshInterval))
+		Expect(deletingCondition()).Should(Equal("DeletionProtected"))
+		Expect(getMySQL().Finalizers).Should(ContainElement(MysqlFinalizer))
+		Expect(claimExists("data-sample-0")).Should(BeTrue())
+
+		By("reconciling the cluster while the deletion is blocked")
+		Expect(configMapExists()).Should(BeTrue())
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(*sf.Spec.Replicas).Should(Equal(int32(2)))
+
+		By("disabling the deletion protection")
+		mysql = getMySQL()
+		protected = false
+		mysql.Spec.DeletionProtection = &protected
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+		reconcileMySQL()
+		Expect(getMySQL().Finalizers).ShouldNot(ContainElement(MysqlFinalizer))
+		Expect(claimExists("data-sample-0")).Should(BeFalse())
+	})
+
+	It("Should not recreate the resources of a protected cluster deleted in the foreground", func() {
+		setup(v1beta1.DeletionPolicyDelete)
+		mysql := getMySQL()
+		protected := true
+		mysql.Spec.DeletionProtection = &protected
+		mysql.Finalizers = append(mysql.Finalizers, v1.FinalizerDeleteDependents)
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+
+		reconcileMySQL()
+		Expect(deletingCondition()).Should(Equal("ForegroundDeletionProtected"))
+		Expect(findCondition(getMySQL().Status.Conditions, v1beta1.ConditionTypeDeleting).Message).Should(ContainSubstring("can not be stopped"))
+		Expect(getMySQL().Finalizers).Should(ContainElement(MysqlFinalizer))
+		Expect(claimExists("data-sample-0")).Should(BeTrue())
+		Expect(configMapExists()).Should(BeFalse())
+	})
+})
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+)
+
+// gtidCatchUpTimeout 은 gtid_mode 를 ON 으로 바꾸기 전에 레플리카가 GTID 없이 기록된 트랜잭션을 모두 적용할 때까지 기다리는 시간이다
+const gtidCatchUpTimeout = 30 * time.Second
+
+// syncGTIDMode 는 GTID 를 사용하지 않던 클러스터의 gtid_mode 를 재시작 없이 한 단계씩 ON 으로 바꾼다. 새 클러스터는 처음부터 ON 으로 시작한다.
+// 한 번에 모든 서버를 다음 단계로 옮기고 Status.GTIDMode 에 기록하여, 다시 시작한 파드도 설정 파일로 같은 단계에서 시작하게 한다
+func (r *MySQLReconciler) syncGTIDMode(mysql *mysqlv1beta1.MySQL) error {
+	if mysql.Status.GTIDMode == mysqlclient.GTIDModeOn {
+		return nil
+	}
+	sf := &appsv1.StatefulSet{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}, sf); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if mysql.Status.GTIDMode == "" {
+			return r.updateGTIDMode(mysql, mysqlclient.GTIDModeOn)
+		}
+		return nil
+	}
+	// 모든 서버를 같은 단계로 옮겨야 하므로 업그레이드 중이거나 준비되지 않은 파드가 있으면 기다린다
+	if sf.Spec.Replicas == nil || mysql.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeUpgrading) {
+		return nil
+	}
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	if int32(len(pods)) != *sf.Spec.Replicas {
+		return nil
+	}
+	for i := range pods {
+		if !isPodReady(&pods[i]) || pods[i].Status.PodIP == "" {
+			return nil
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return podOrdinal(pods[i].Name) < podOrdinal(pods[j].Name) })
+
+	rootPassword, err := r.secretValue(mysql, rootPasswordSecretRef(mysql))
+	if err != nil {
+		return err
+	}
+	ctx := context.TODO()
+	servers := make([]mysqlclient.Client, len(pods))
+	modes := make([]int, len(pods))
+	lowest := len(mysqlclient.GTIDModes) - 1
+	for i := range pods {
+		c, err := r.connect(&pods[i], rootPassword)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		mode, err := c.GTIDMode(ctx)
+		if err != nil {
+			return err
+		}
+		servers[i], modes[i] = c, mysqlclient.GTIDModeIndex(mode)
+		if modes[i] < 0 {
+			modes[i] = 0
+		}
+		if modes[i] < lowest {
+			lowest = modes[i]
+		}
+	}
+	// 처음 확인하는 클러스터는 가장 낮은 단계의 서버에서 시작한다
+	current := mysqlclient.GTIDModeIndex(mysql.Status.GTIDMode)
+	if current < 0 {
+		current = lowest
+	}
+	if current == len(mysqlclient.GTIDModes)-1 {
+		return r.updateGTIDMode(mysql, mysqlclient.GTIDModeOn)
+	}
+	next := current + 1
+	primary := primaryPodName(mysql)
+
+	if mysqlclient.GTIDModes[next] == mysqlclient.GTIDModeOn {
+		// GTID 없이 실행 중인 트랜잭션이 끝나고 레플리카가 이를 모두 적용한 뒤에야 ON 으로 바꿀 수 있다
+		for i := range servers {
+			count, err := servers[i].OngoingAnonymousTransactions(ctx)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				r.Log.Info("Waiting for anonymous transactions to finish before enabling GTID", "pod", pods[i].Name, "transactions", count)
+				return nil
+			}
+		}
+		var file string
+		var position int64
+		for i := range pods {
+			if pods[i].Name == primary {
+				if file, position, err = servers[i].BinaryLogPosition(ctx); err != nil {
+					return err
+				}
+			}
+		}
+		for i := range pods {
+			if pods[i].Name == primary {
+				continue
+			}
+			if err := servers[i].WaitForBinaryLogPosition(ctx, file, position, gtidCatchUpTimeout); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.Log.Info("Change gtid_mode", "from", mysqlclient.GTIDModes[current], "to", mysqlclient.GTIDModes[next])
+	for i := range servers {
+		for mode := modes[i] + 1; mode <= next; mode++ {
+			if err := servers[i].SetGTIDMode(ctx, mysqlclient.GTIDModes[mode]); err != nil {
+				r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "GTIDModeChangeFailed", "Failed to change gtid_mode of %s to %s: %v", pods[i].Name, mysqlclient.GTIDModes[mode], err)
+				return err
+			}
+		}
+	}
+	if mysqlclient.GTIDModes[next] == mysqlclient.GTIDModeOn {
+		// 레플리카는 바이너리 로그 위치 대신 GTID 자동 위치로 복제하도록 바꾼다
+		user, password, err := r.replicationCredentials(mysql)
+		if err != nil {
+			return err
+		}
+		for i := range pods {
+			if pods[i].Name != primary {
+				r.repoint(ctx, mysql, pods[i].Name, servers[i], primary, user, password)
+			}
+		}
+	}
+	r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "GTIDModeChanged", "Changed gtid_mode of all servers to %s", mysqlclient.GTIDModes[next])
+	return r.updateGTIDMode(mysql, mysqlclient.GTIDModes[next])
+}
+
+func (r *MySQLReconciler) updateGTIDMode(mysql *mysqlv1beta1.MySQL, mode string) error {
+	mysql.Status.GTIDMode = mode
+	return r.Status().Update(context.TODO(), mysql)
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("mysql gtid mode", func() {
+	var (
+		mysql    *v1beta1.MySQL
+		r        *MySQLReconciler
+		servers  map[string]*fakeMySQL
+		recorder *record.FakeRecorder
+		key      = types.NamespacedName{Namespace: "default", Name: "sample"}
+	)
+
+	// syncWith 는 주어진 오브젝트로 클라이언트를 만들고 gtid_mode 를 한 번 조정한다
+	syncWith := func(objs ...runtime.Object) {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		r = &MySQLReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, append(objs, mysql)...),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme:   s,
+			Recorder: recorder,
+			MySQLClient: func(host, user, password string) (mysqlclient.Client, error) {
+				return servers[host], nil
+			},
+		}
+		Expect(r.syncGTIDMode(mysql)).Should(Succeed())
+		Expect(r.Client.Get(context.TODO(), key, mysql)).Should(Succeed())
+	}
+
+	// cluster 는 세 개의 파드가 모두 준비된 기존 클러스터의 오브젝트를 만든다
+	cluster := func() []runtime.Object {
+		replicas := int32(3)
+		objs := []runtime.Object{
+			&v12.StatefulSet{
+				ObjectMeta: v1.ObjectMeta{Name: "sample", Namespace: "default"},
+				Spec:       v12.StatefulSetSpec{Replicas: &replicas},
+			},
+			&corev1.Secret{
+				ObjectMeta: v1.ObjectMeta{Name: "sample-root", Namespace: "default"},
+				Data:       map[string][]byte{"password": []byte("root")},
+			},
+			&corev1.Secret{
+				ObjectMeta: v1.ObjectMeta{Name: "sample-replication", Namespace: "default"},
+				Data:       map[string][]byte{"username": []byte("replication"), "password": []byte("secret")},
+			},
+		}
+		for i := 0; i < 3; i++ {
+			objs = append(objs, &corev1.Pod{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      fmt.Sprintf("sample-%d", i),
+					Namespace: "default",
+					Labels:    map[string]string{"app": "sample"},
+				},
+				Status: corev1.PodStatus{
+					PodIP:      fmt.Sprintf("10.0.0.%d", i),
+					Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+				},
+			})
+		}
+		return objs
+	}
+
+	BeforeEach(func() {
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+			},
+			Status: v1beta1.MySQLStatus{Primary: "sample-0"},
+		}
+		servers = map[string]*fakeMySQL{
+			"10.0.0.0": {gtidMode: mysqlclient.GTIDModeOff},
+			"10.0.0.1": {gtidMode: mysqlclient.GTIDModeOff, status: &mysqlclient.ReplicaStatus{SourceHost: "sample-0.sample"}},
+			"10.0.0.2": {gtidMode: mysqlclient.GTIDModeOff, status: &mysqlclient.ReplicaStatus{SourceHost: "sample-0.sample"}},
+		}
+	})
+
+	It("should start a new cluster with gtid_mode ON", func() {
+		syncWith()
+
+		Expect(mysql.Status.GTIDMode).Should(Equal(mysqlclient.GTIDModeOn))
+	})
+
+	It("should move an existing cluster one step at a time", func() {
+		syncWith(cluster()...)
+
+		Expect(mysql.Status.GTIDMode).Should(Equal(mysqlclient.GTIDModeOffPermissive))
+		for _, server := range servers {
+			Expect(server.gtidMode).Should(Equal(mysqlclient.GTIDModeOffPermissive))
+		}
+		Expect(recorder.Events).Should(Receive(ContainSubstring("GTIDModeChanged")))
+	})
+
+	It("should wait while a pod is not ready", func() {
+		objs := cluster()
+		objs[len(objs)-1].(*corev1.Pod).Status.Conditions[0].Status = corev1.ConditionFalse
+		syncWith(objs...)
+
+		Expect(mysql.Status.GTIDMode).Should(BeEmpty())
+		Expect(servers["10.0.0.0"].gtidMode).Should(Equal(mysqlclient.GTIDModeOff))
+	})
+
+	It("should wait for anonymous transactions before turning gtid_mode ON", func() {
+		mysql.Status.GTIDMode = mysqlclient.GTIDModeOnPermissive
+		for _, server := range servers {
+			server.gtidMode = mysqlclient.GTIDModeOnPermissive
+		}
+		servers["10.0.0.0"].anonymous = 2
+		syncWith(cluster()...)
+
+		Expect(mysql.Status.GTIDMode).Should(Equal(mysqlclient.GTIDModeOnPermissive))
+		Expect(servers["10.0.0.0"].gtidMode).Should(Equal(mysqlclient.GTIDModeOnPermissive))
+	})
+
+	It("should turn gtid_mode ON after the replicas catch up", func() {
+		mysql.Status.GTIDMode = mysqlclient.GTIDModeOnPermissive
+		for _, server := range servers {
+			server.gtidMode = mysqlclient.GTIDModeOnPermissive
+		}
+		syncWith(cluster()...)
+
+		Expect(mysql.Status.GTIDMode).Should(Equal(mysqlclient.GTIDModeOn))
+		Expect(servers["10.0.0.0"].gtidMode).Should(Equal(mysqlclient.GTIDModeOn))
+		Expect(servers["10.0.0.0"].waitedFor).Should(BeEmpty())
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+			Expect(servers[ip].waitedFor).Should(Equal("mysql-bin.000003:154"))
+			Expect(servers[ip].gtidMode).Should(Equal(mysqlclient.GTIDModeOn))
+			Expect(servers[ip].source).Should(Equal("sample-0.sample"))
+		}
+	})
+
+	It("should not fail over before gtid_mode is ON", func() {
+		mysql.Status.GTIDMode = mysqlclient.GTIDModeOffPermissive
+		since := v1.Now()
+		mysql.Status.PrimaryUnavailableSince = &since
+		objs := cluster()
+		objs[3].(*corev1.Pod).Status.Conditions[0].Status = corev1.ConditionFalse
+		syncWith(objs...)
+		Expect(r.syncFailover(mysql)).Should(Succeed())
+
+		Expect(mysql.Status.PrimaryUnavailableSince).Should(BeNil())
+	})
+})
@@ -18,45 +18,90 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/woohhan/kubebuilder-util/pkg/condition"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/binlog"
+	"sample-mysql-operator/pkg/mysqlclient"
+)
+
+const (
+	// versionAnnotation 은 파드 템플릿에 기록하는 MySQL 서버 버전이다
+	versionAnnotation = "mysql.sample.com/version"
+	// storageResizeCheckInterval 은 볼륨 확장이 끝났는지 확인하는 주기이다
+	storageResizeCheckInterval = 30 * time.Second
 )
 
 // MySQLReconciler reconciles a MySQL object
 type MySQLReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// MySQLClient 는 페일오버할 때 MySQL 서버에 접속하는 클라이언트를 만든다
+	MySQLClient mysqlclient.Factory
+	// AgentImage 는 백업에서 클러스터를 복원하거나 바이너리 로그를 보관할 때 에이전트를 실행할 이미지이다
+	AgentImage string
+	// BinlogArchiveStatus 는 프라이머리의 아카이버에서 바이너리 로그 보관 상태를 가져온다
+	BinlogArchiveStatus binlog.StatusFunc
 }
 
 // +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqls,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqls/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqlbackups,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqlclonegrants,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is reconcile loop for MySQL
 func (r *MySQLReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	r.Log = r.Log.WithValues("mysql", req.NamespacedName)
 
-	mysql := &mysqlv1alpha1.MySQL{}
+	mysql := &mysqlv1beta1.MySQL{}
 	if err := r.Get(context.TODO(), req.NamespacedName, mysql); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	if err := r.finalize(mysql); err != nil {
+	// 삭제 중인 클러스터는 삭제 정책에 따른 정리만 한다. 웹훅을 거치지 않고 삭제가 요청된 보호된 클러스터는
+	// 보호가 해제될 때까지 데이터를 정리하지 않고, 그동안 페일오버 등이 동작하도록 평소처럼 조정한다
+	if mysql.GetDeletionTimestamp() != nil {
+		if !contains(mysql.GetFinalizers(), MysqlFinalizer) || !mysql.Spec.IsDeletionProtected() {
+			return r.finalize(mysql)
+		}
+		if foreground, err := r.blockDeletion(mysql); err != nil || foreground {
+			return ctrl.Result{}, err
+		}
+	}
+	if err := r.ensureFinalizer(mysql); err != nil {
 		return ctrl.Result{}, err
 	}
 	if err := r.syncReadService(mysql); err != nil {
@@ -65,14 +110,70 @@ func (r *MySQLReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	if err := r.syncHeadlessService(mysql); err != nil {
 		return ctrl.Result{}, err
 	}
+	if err := r.syncPrimaryService(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncSecrets(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncGTIDMode(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncFailover(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncSwitchover(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncConfigMap(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncBootstrap(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncScaleDown(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
 	if err := r.syncStatefulSet(mysql); err != nil {
 		return ctrl.Result{}, err
 	}
-
-	return ctrl.Result{}, nil
+	if err := r.syncPodRoles(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncTopology(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncServing(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncStorage(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncBinlogArchive(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncBackupCondition(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncOwnership(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.adoptRetainedClaims(mysql); err != nil {
+		return ctrl.Result{}, err
+	}
+	// 볼륨 클레임은 감시하지 않으므로 확장이 끝날 때까지 주기적으로 확인한다
+	if mysql.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeStorageResizing) {
+		return ctrl.Result{RequeueAfter: storageResizeCheckInterval}, nil
+	}
+	// 파드는 감시하지 않으므로 프라이머리가 준비되지 않은 동안 페일오버할 시점이 되었는지 주기적으로 확인한다
+	if mysql.Status.PrimaryUnavailableSince != nil {
+		return ctrl.Result{RequeueAfter: failoverCheckInterval}, nil
+	}
+	// 복제 상태는 이벤트로 알 수 없으므로 주기적으로 다시 확인한다
+	return ctrl.Result{RequeueAfter: topologyRefreshInterval}, nil
 }
 
-func (r *MySQLReconciler) syncReadService(mysql *mysqlv1alpha1.MySQL) error {
+func (r *MySQLReconciler) syncReadService(mysql *mysqlv1beta1.MySQL) error {
 	svc := &corev1.Service{}
 	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name + "-read"}, svc); err != nil {
 		if !errors.IsNotFound(err) {
@@ -82,11 +183,31 @@ func (r *MySQLReconciler) syncReadService(mysql *mysqlv1alpha1.MySQL) error {
 		r.Log.Info("Could not find read service. Create a new one")
 		return createReadService(r, mysql)
 	}
+	return r.correctServiceDrift(svc, newReadService(mysql))
+}
+
+func createReadService(r *MySQLReconciler, mysql *mysqlv1beta1.MySQL) error {
+	svc := newReadService(mysql)
+	if err := controllerutil.SetControllerReference(mysql, svc, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(context.TODO(), svc); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
 	return nil
 }
 
-func createReadService(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
-	svc := &corev1.Service{
+func newReadService(mysql *mysqlv1beta1.MySQL) *corev1.Service {
+	selector := map[string]string{
+		"app": mysql.Name,
+	}
+	if mysql.Spec.Services.Read.ExcludePrimary {
+		selector[roleLabel] = roleReplica
+	}
+	if mysql.Spec.Services.Read.MaxLagSeconds != nil {
+		selector[servingLabel] = servingTrue
+	}
+	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: mysql.Namespace,
 			Name:      mysql.Name + "-read",
@@ -94,25 +215,17 @@ func createReadService(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
 				{
-					Name: "mysql",
-					Port: 3306,
+					Name:       "mysql",
+					Port:       3306,
+					TargetPort: intstr.FromInt(3306),
 				},
 			},
-			Selector: map[string]string{
-				"app": mysql.Name,
-			},
+			Selector: selector,
 		},
 	}
-	if err := controllerutil.SetControllerReference(mysql, svc, r.Scheme); err != nil {
-		return err
-	}
-	if err := r.Create(context.TODO(), svc); err != nil && !errors.IsAlreadyExists(err) {
-		return err
-	}
-	return nil
 }
 
-func (r *MySQLReconciler) syncHeadlessService(mysql *mysqlv1alpha1.MySQL) error {
+func (r *MySQLReconciler) syncHeadlessService(mysql *mysqlv1beta1.MySQL) error {
 	svc := &corev1.Service{}
 	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}, svc); err != nil {
 		if !errors.IsNotFound(err) {
@@ -121,11 +234,22 @@ func (r *MySQLReconciler) syncHeadlessService(mysql *mysqlv1alpha1.MySQL) error
 		r.Log.Info("Could not find headless service. Create a new one")
 		return createHeadlessService(r, mysql)
 	}
+	return r.correctServiceDrift(svc, newHeadlessService(mysql))
+}
+
+func createHeadlessService(r *MySQLReconciler, mysql *mysqlv1beta1.MySQL) error {
+	svc := newHeadlessService(mysql)
+	if err := controllerutil.SetControllerReference(mysql, svc, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(context.TODO(), svc); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
 	return nil
 }
 
-func createHeadlessService(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
-	svc := &corev1.Service{
+func newHeadlessService(mysql *mysqlv1beta1.MySQL) *corev1.Service {
+	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: mysql.Namespace,
 			Name:      mysql.Name,
@@ -133,8 +257,9 @@ func createHeadlessService(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
 				{
-					Name: "mysql",
-					Port: 3306,
+					Name:       "mysql",
+					Port:       3306,
+					TargetPort: intstr.FromInt(3306),
 				},
 			},
 			Selector: map[string]string{
@@ -143,6 +268,22 @@ func createHeadlessService(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error
 			ClusterIP: "None",
 		},
 	}
+}
+
+func (r *MySQLReconciler) syncPrimaryService(mysql *mysqlv1beta1.MySQL) error {
+	svc := &corev1.Service{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name + "-primary"}, svc); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		r.Log.Info("Could not find primary service. Create a new one")
+		return createPrimaryService(r, mysql)
+	}
+	return r.correctServiceDrift(svc, newPrimaryService(mysql))
+}
+
+func createPrimaryService(r *MySQLReconciler, mysql *mysqlv1beta1.MySQL) error {
+	svc := newPrimaryService(mysql)
 	if err := controllerutil.SetControllerReference(mysql, svc, r.Scheme); err != nil {
 		return err
 	}
@@ -152,7 +293,46 @@ func createHeadlessService(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error
 	return nil
 }
 
-func (r *MySQLReconciler) syncStatefulSet(mysql *mysqlv1alpha1.MySQL) error {
+// newPrimaryService 는 쓰기 요청을 받는 프라이머리 파드로만 연결되는 서비스를 만든다
+func newPrimaryService(mysql *mysqlv1beta1.MySQL) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: mysql.Namespace,
+			Name:      mysql.Name + "-primary",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "mysql",
+					Port:       3306,
+					TargetPort: intstr.FromInt(3306),
+				},
+			},
+			Selector: map[string]string{
+				"app":     mysql.Name,
+				roleLabel: rolePrimary,
+			},
+		},
+	}
+}
+
+// correctServiceDrift 는 서비스의 포트나 셀렉터가 원하는 상태와 다르면 되돌린다.
+// ClusterIP 는 변경할 수 없으므로 비교하지 않는다
+func (r *MySQLReconciler) correctServiceDrift(svc, desired *corev1.Service) error {
+	return r.correctDrift(svc, "Service", svc.Name, func() bool {
+		// API 서버가 채우는 기본값(프로토콜 등)은 무시한다
+		if len(svc.Spec.Ports) == len(desired.Spec.Ports) &&
+			equality.Semantic.DeepDerivative(desired.Spec.Ports, svc.Spec.Ports) &&
+			equality.Semantic.DeepEqual(desired.Spec.Selector, svc.Spec.Selector) {
+			return false
+		}
+		svc.Spec.Ports = desired.Spec.Ports
+		svc.Spec.Selector = desired.Spec.Selector
+		return true
+	})
+}
+
+func (r *MySQLReconciler) syncStatefulSet(mysql *mysqlv1beta1.MySQL) error {
 	// 클라이언트로 MySQL 스테이트풀셋 객체를 가져온다
 	sf := &appsv1.StatefulSet{}
 	err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}, sf)
@@ -160,30 +340,145 @@ func (r *MySQLReconciler) syncStatefulSet(mysql *mysqlv1alpha1.MySQL) error {
 		if !errors.IsNotFound(err) {
 			return err
 		}
+		// 복원할 백업이 준비되지 않았으면 빈 클러스터를 만들지 않고 기다린다
+		if bootstrapPending(mysql) {
+			return nil
+		}
 		// MySQL 스테이트풀셋을 생성한다
 		r.Log.Info("Could not find mysql StatefulSet. Create a new one")
 		return createStatefulSet(r, mysql)
 	}
-	// 스테이트풀셋의 레플리카 수가 변경된 경우 업데이트한다
-	if mysql.Spec.Replicas != *sf.Spec.Replicas {
-		r.Log.Info("Update replica size")
-		clonedStatefulSet := sf.DeepCopy()
-		*clonedStatefulSet.Spec.Replicas = mysql.Spec.Replicas
-		if err := r.Update(context.TODO(), clonedStatefulSet); err != nil {
-			return err
+	// 레플리카 수, 리소스, 설정 등이 변경되었거나 누군가 직접 수정한 경우 원하는 상태로 되돌린다.
+	// 변경된 템플릿은 syncUpgrade 에서 파드를 하나씩 재시작하여 반영한다
+	if err := r.correctStatefulSetDrift(sf, mysql); err != nil {
+		return err
+	}
+	if err := r.syncUpgrade(sf, mysql); err != nil {
+		return err
+	}
+	if err := r.updateVersionStatus(sf, mysql); err != nil {
+		return err
+	}
+	return r.updateRunningCondition(sf, mysql)
+}
+
+// correctStatefulSetDrift 는 스테이트풀셋에서 변경할 수 있는 필드(레플리카 수, 업데이트 전략, 파드 템플릿)를 원하는 상태로 되돌린다
+func (r *MySQLReconciler) correctStatefulSetDrift(sf *appsv1.StatefulSet, mysql *mysqlv1beta1.MySQL) error {
+	// 버전은 syncUpgrade 가 사전 검사를 마친 뒤에 변경하므로 현재 템플릿의 버전을 유지한다
+	version, image := templateVersion(sf, mysql)
+	desired := newStatefulSet(mysql, version, image, r.AgentImage)
+	// 레플리카 수는 syncScaleDown 이 제거될 파드를 준비한 뒤에 줄인다
+	if sf.Spec.Replicas != nil && *sf.Spec.Replicas > *desired.Spec.Replicas {
+		desired.Spec.Replicas = sf.Spec.Replicas
+	}
+	return r.correctDrift(sf, "StatefulSet", sf.Name, func() bool {
+		if sf.Spec.Replicas != nil && *sf.Spec.Replicas == *desired.Spec.Replicas &&
+			equality.Semantic.DeepDerivative(desired.Spec.UpdateStrategy, sf.Spec.UpdateStrategy) &&
+			templateMatches(&desired.Spec.Template, &sf.Spec.Template) {
+			return false
+		}
+		sf.Spec.Replicas = desired.Spec.Replicas
+		sf.Spec.UpdateStrategy = desired.Spec.UpdateStrategy
+		sf.Spec.Template = desired.Spec.Template
+		return true
+	})
+}
+
+// templateVersion 은 스테이트풀셋 템플릿에 기록된 MySQL 버전과 그 버전에 사용할 이미지를 반환한다
+func templateVersion(sf *appsv1.StatefulSet, mysql *mysqlv1beta1.MySQL) (string, string) {
+	version := sf.Spec.Template.Annotations[versionAnnotation]
+	if version == "" {
+		// 버전이 기록되기 전에 만들어진 스테이트풀셋은 기본 버전으로 동작하고 있다
+		version = mysqlv1beta1.DefaultVersion
+	}
+	if version == mysql.Spec.ServerVersion() {
+		return version, mysql.Spec.ServerImage()
+	}
+	for _, c := range sf.Spec.Template.Spec.Containers {
+		if c.Name == "mysql" {
+			return version, c.Image
 		}
+	}
+	return version, "mysql:" + version
+}
+
+// templateMatches 는 파드 템플릿이 원하는 상태와 같은지 확인한다. API 서버가 채운 기본값은 무시하지만,
+// 컨테이너, 볼륨, 환경 변수, 마운트, 포트가 추가되거나 리소스가 제거된 경우도 찾아내야 하므로 이들은 정확히 비교한다
+func templateMatches(desired, live *corev1.PodTemplateSpec) bool {
+	if len(desired.Spec.Volumes) != len(live.Spec.Volumes) ||
+		len(desired.Spec.InitContainers) != len(live.Spec.InitContainers) ||
+		len(desired.Spec.Containers) != len(live.Spec.Containers) {
+		return false
+	}
+	if !equality.Semantic.DeepDerivative(*desired, *live) {
+		return false
+	}
+	for i := range desired.Spec.InitContainers {
+		if !containerMatches(&desired.Spec.InitContainers[i], &live.Spec.InitContainers[i]) {
+			return false
+		}
+	}
+	for i := range desired.Spec.Containers {
+		if !containerMatches(&desired.Spec.Containers[i], &live.Spec.Containers[i]) {
+			return false
+		}
+	}
+	// 사용자가 지운 스케줄링 설정도 찾아내야 하므로 정확히 비교한다
+	return equality.Semantic.DeepEqual(desired.Spec.NodeSelector, live.Spec.NodeSelector) &&
+		equality.Semantic.DeepEqual(desired.Spec.Tolerations, live.Spec.Tolerations) &&
+		desired.Spec.PriorityClassName == live.Spec.PriorityClassName
+}
+
+// containerMatches 는 DeepDerivative 가 추가된 항목을 무시하는 목록과 리소스를 정확히 비교한다.
+// API 서버가 기본값을 채우지 않도록 원하는 상태의 포트에는 프로토콜을 지정한다
+func containerMatches(desired, live *corev1.Container) bool {
+	return equality.Semantic.DeepEqual(desired.Env, live.Env) &&
+		equality.Semantic.DeepEqual(desired.VolumeMounts, live.VolumeMounts) &&
+		equality.Semantic.DeepEqual(desired.Ports, live.Ports) &&
+		equality.Semantic.DeepEqual(desired.Resources, live.Resources)
+}
+
+// mergeMaps 는 base 에 overrides 를 덮어쓴 새 맵을 반환한다. 사용자가 지정한 레이블이나 어노테이션이 오퍼레이터의 값을 덮어쓰지 못하게 한다
+func mergeMaps(base, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (r *MySQLReconciler) updateVersionStatus(sf *appsv1.StatefulSet, mysql *mysqlv1beta1.MySQL) error {
+	// 모든 파드가 최신 템플릿으로 동작하기 전에는 실제 버전을 알 수 없다
+	if !isRolledOut(sf) {
 		return nil
 	}
-	return r.updateRunningCondition(sf, mysql)
+	version := sf.Spec.Template.Annotations[versionAnnotation]
+	if version == "" || mysql.Status.Version == version {
+		return nil
+	}
+	r.Log.Info("Update running version", "version", version)
+	mysql.Status.Version = version
+	return r.Status().Update(context.TODO(), mysql)
 }
 
-func (r *MySQLReconciler) updateRunningCondition(sf *appsv1.StatefulSet, mysql *mysqlv1alpha1.MySQL) error {
-	isRunning := sf.Status.ReadyReplicas == mysql.Spec.Replicas
+// isRolledOut 은 스테이트풀셋의 모든 파드가 최신 템플릿으로 준비되었는지 확인한다
+func isRolledOut(sf *appsv1.StatefulSet) bool {
+	if sf.Spec.Replicas == nil || sf.Status.ObservedGeneration < sf.Generation {
+		return false
+	}
+	return sf.Status.UpdatedReplicas == *sf.Spec.Replicas && sf.Status.ReadyReplicas == *sf.Spec.Replicas
+}
+
+func (r *MySQLReconciler) updateRunningCondition(sf *appsv1.StatefulSet, mysql *mysqlv1beta1.MySQL) error {
+	isRunning := sf.Status.ReadyReplicas == mysql.Spec.Replication.Replicas
 	// 변경할 필요가 없는 경우 바로 리턴
-	if isRunning && mysql.Status.Conditions.IsTrueFor(mysqlv1alpha1.ConditionTypeRunning) {
+	if isRunning && mysql.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeRunning) {
 		return nil
 	}
-	if !isRunning && mysql.Status.Conditions.IsFalseFor(mysqlv1alpha1.ConditionTypeRunning) {
+	if !isRunning && mysql.Status.Conditions.IsFalseFor(mysqlv1beta1.ConditionTypeRunning) {
 		return nil
 	}
 	r.Log.Info("Update running condition", "condition", isRunning)
@@ -191,18 +486,30 @@ func (r *MySQLReconciler) updateRunningCondition(sf *appsv1.StatefulSet, mysql *
 	return r.Status().Update(context.TODO(), mysql)
 }
 
+// setCondition 은 컨디션이 변경된 경우에만 MySQL 상태를 업데이트한다
+func (r *MySQLReconciler) setCondition(mysql *mysqlv1beta1.MySQL, cond condition.Condition) error {
+	for _, c := range mysql.Status.Conditions {
+		if c.Type == cond.Type && c.Status == cond.Status && c.Reason == cond.Reason && c.Message == cond.Message {
+			return nil
+		}
+	}
+	r.Log.Info("Update condition", "type", cond.Type, "status", cond.Status, "reason", cond.Reason)
+	mysql.Status.Conditions.SetCondition(cond)
+	return r.Status().Update(context.TODO(), mysql)
+}
+
 func getRunningCondition(isRunning bool) condition.Condition {
 	var cond condition.Condition
 	if isRunning {
 		cond = condition.Condition{
-			Type:    mysqlv1alpha1.ConditionTypeRunning,
+			Type:    mysqlv1beta1.ConditionTypeRunning,
 			Status:  corev1.ConditionTrue,
 			Reason:  "MysqlRunning",
 			Message: "MySQL is running",
 		}
 	} else {
 		cond = condition.Condition{
-			Type:    mysqlv1alpha1.ConditionTypeRunning,
+			Type:    mysqlv1beta1.ConditionTypeRunning,
 			Status:  corev1.ConditionFalse,
 			Reason:  "MysqlNotRunning",
 			Message: "StatefulSet is not ready yet",
@@ -211,7 +518,21 @@ func getRunningCondition(isRunning bool) condition.Condition {
 	return cond
 }
 
-func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
+func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1beta1.MySQL) error {
+	statefulSet := newStatefulSet(mysql, mysql.Spec.ServerVersion(), mysql.Spec.ServerImage(), r.AgentImage)
+	if err := controllerutil.SetControllerReference(mysql, statefulSet, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(context.TODO(), statefulSet); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// newStatefulSet 은 MySQL 스테이트풀셋의 원하는 상태를 만든다. 서버 버전과 이미지는 업그레이드 진행 상황에 따라 호출하는 쪽에서 정한다.
+// 백업에서 시작하는 클러스터는 에이전트 이미지로 백업을 복원하는 초기화 컨테이너를 가진다
+func newStatefulSet(mysql *mysqlv1beta1.MySQL, version, image, agentImage string) *appsv1.StatefulSet {
+	replicas := mysql.Spec.Replication.Replicas
 	volumeMount := []corev1.VolumeMount{
 		{
 			Name:      "data",
@@ -223,13 +544,13 @@ func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
 			MountPath: "/etc/mysql/conf.d",
 		},
 	}
-	statefulSet := &appsv1.StatefulSet{
+	sf := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      mysql.Name,
 			Namespace: mysql.Namespace,
 		},
 		Spec: appsv1.StatefulSetSpec{
-			Replicas: &mysql.Spec.Replicas,
+			Replicas: &replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app": mysql.Name,
@@ -237,11 +558,18 @@ func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
+					Labels: mergeMaps(mysql.Spec.PodTemplate.Metadata.Labels, map[string]string{
 						"app": mysql.Name,
-					},
+					}),
+					Annotations: mergeMaps(mysql.Spec.PodTemplate.Metadata.Annotations, map[string]string{
+						versionAnnotation:    version,
+						configHashAnnotation: configHash(configMapData(mysql)),
+					}),
 				},
 				Spec: corev1.PodSpec{
+					NodeSelector:      mysql.Spec.PodTemplate.NodeSelector,
+					Tolerations:       mysql.Spec.PodTemplate.Tolerations,
+					PriorityClassName: mysql.Spec.PodTemplate.PriorityClassName,
 					Volumes: []corev1.Volume{
 						{
 							Name: "conf",
@@ -254,7 +582,7 @@ func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
 							VolumeSource: corev1.VolumeSource{
 								ConfigMap: &corev1.ConfigMapVolumeSource{
 									LocalObjectReference: corev1.LocalObjectReference{
-										Name: "mysql",
+										Name: configMapName(mysql),
 									},
 								},
 							},
@@ -262,8 +590,9 @@ func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
 					},
 					InitContainers: []corev1.Container{
 						{
-							Name:  "init-mysql",
-							Image: "quay.io/sample-mysql-operator/init-mysql:latest",
+							Name:    "init-mysql",
+							Image:   "quay.io/sample-mysql-operator/init-mysql:latest",
+							Command: []string{"bash", "-c", initMySQLScript},
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "conf",
@@ -279,7 +608,7 @@ func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
 							Name:         "clone-mysql",
 							Image:        "quay.io/sample-mysql-operator/clone-mysql:latest",
 							VolumeMounts: volumeMount,
-							Env: []corev1.EnvVar{
+							Env: append([]corev1.EnvVar{
 								{
 									Name:  "POD_NAME",
 									Value: mysql.Name,
@@ -288,62 +617,72 @@ func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
 									Name:  "SVC_NAME",
 									Value: mysql.Name,
 								},
-							},
+							}, credentialEnv(mysql)...),
 						},
 					},
 					Containers: []corev1.Container{
 						{
-							Name:  "mysql",
-							Image: "mysql:5.7",
-							Env: []corev1.EnvVar{
-								{
-									Name:  "MYSQL_ALLOW_EMPTY_PASSWORD",
-									Value: "1",
-								},
-							},
+							Name:         "mysql",
+							Image:        image,
+							Env:          credentialEnv(mysql),
 							VolumeMounts: volumeMount,
+							Resources:    mysql.Spec.PodTemplate.Resources.MySQL,
 							LivenessProbe: &corev1.Probe{
 								Handler: corev1.Handler{
 									Exec: &corev1.ExecAction{
 										Command: []string{
-											"mysqladmin", "ping",
+											"sh", "-c", `MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysqladmin -u root ping`,
 										},
 									},
 								},
 								InitialDelaySeconds: 30,
 								TimeoutSeconds:      5,
 								PeriodSeconds:       10,
+								SuccessThreshold:    1,
+								FailureThreshold:    3,
 							},
 							ReadinessProbe: &corev1.Probe{
 								Handler: corev1.Handler{
 									Exec: &corev1.ExecAction{
 										Command: []string{
-											"mysql", "-h", "127.0.0.1", "-e", "SELECT 1",
+											"sh", "-c", `MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysql -h 127.0.0.1 -u root -e "SELECT 1"`,
 										},
 									},
 								},
 								InitialDelaySeconds: 5,
 								TimeoutSeconds:      1,
 								PeriodSeconds:       2,
+								SuccessThreshold:    1,
+								FailureThreshold:    3,
 							},
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "mysql",
 									ContainerPort: 3306,
+									Protocol:      corev1.ProtocolTCP,
 								},
 							},
 						},
 						{
-							Name:  "xtrabackup",
-							Image: "quay.io/sample-mysql-operator/xtrabackup:latest",
+							Name:    "xtrabackup",
+							Image:   "quay.io/sample-mysql-operator/xtrabackup:latest",
+							Command: []string{"bash", "-c", xtrabackupScript},
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "xtrabackup",
 									ContainerPort: 3307,
+									Protocol:      corev1.ProtocolTCP,
 								},
 							},
-							VolumeMounts: volumeMount,
-							Env: []corev1.EnvVar{
+							// 현재 프라이머리를 확인하기 위해 컨피그맵을 마운트한다
+							VolumeMounts: append([]corev1.VolumeMount{
+								{
+									Name:      "config-map",
+									MountPath: "/mnt/config-map",
+								},
+							}, volumeMount...),
+							Resources: mysql.Spec.PodTemplate.Resources.Xtrabackup,
+							Env: append([]corev1.EnvVar{
 								{
 									Name:  "POD_NAME",
 									Value: mysql.Name,
@@ -352,7 +691,7 @@ func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
 									Name:  "SVC_NAME",
 									Value: mysql.Name,
 								},
-							},
+							}, credentialEnv(mysql)...),
 						},
 					},
 				},
@@ -363,33 +702,65 @@ func createStatefulSet(r *MySQLReconciler, mysql *mysqlv1alpha1.MySQL) error {
 						Name: "data",
 					},
 					Spec: corev1.PersistentVolumeClaimSpec{
-						AccessModes: []corev1.PersistentVolumeAccessMode{
-							"ReadWriteOnce",
-						},
+						AccessModes:      mysql.Spec.StorageAccessModes(),
+						StorageClassName: mysql.Spec.Storage.StorageClassName,
 						Resources: corev1.ResourceRequirements{
 							Requests: map[corev1.ResourceName]resource.Quantity{
-								corev1.ResourceStorage: resource.MustParse("2Gi")},
+								corev1.ResourceStorage: mysql.Spec.StorageSize()},
 						},
 					},
 				},
 			},
 			ServiceName: mysql.Name,
+			// 업그레이드 시 프라이머리를 마지막에 재시작하기 위해 오퍼레이터가 파드를 직접 재시작한다
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type: appsv1.OnDeleteStatefulSetStrategyType,
+			},
 		},
 	}
-	if err := controllerutil.SetControllerReference(mysql, statefulSet, r.Scheme); err != nil {
-		return err
+	// 복원은 설정 파일을 준비한 뒤, 레플리카가 프라이머리를 복제하기 전에 실행한다
+	if restore, volumes := restoreInitContainers(mysql, agentImage, volumeMount); restore != nil {
+		spec := &sf.Spec.Template.Spec
+		spec.Volumes = append(spec.Volumes, volumes...)
+		spec.InitContainers = append(spec.InitContainers[:1], append(restore, spec.InitContainers[1:]...)...)
 	}
-	if err := r.Create(context.TODO(), statefulSet); err != nil && !errors.IsAlreadyExists(err) {
-		return err
+	// 복원한 프라이머리는 xtrabackup 컨테이너가 바이너리 로그를 모두 재생할 때까지 준비되지 않은 상태로 둔다
+	if pitr := pointInTimeRecovery(mysql); pitr != nil {
+		containers := sf.Spec.Template.Spec.Containers
+		mysqlContainer, xtrabackup := &containers[0], &containers[1]
+		mysqlContainer.VolumeMounts = append(mysqlContainer.VolumeMounts, pitrVolumeMount())
+		probe := &mysqlContainer.ReadinessProbe.Exec.Command[2]
+		*probe = fmt.Sprintf("test ! -d %s/binlogs && %s", pitrMountPath, *probe)
+		xtrabackup.VolumeMounts = append(xtrabackup.VolumeMounts, pitrVolumeMount())
+		xtrabackup.Env = append(xtrabackup.Env, pointInTimeEnv(pitr)...)
 	}
-	return nil
+	if mysql.Spec.Backup.BinlogArchive != nil {
+		archiver, volumes := binlogArchiverContainer(mysql, agentImage)
+		spec := &sf.Spec.Template.Spec
+		spec.Containers = append(spec.Containers, archiver)
+		spec.Volumes = append(spec.Volumes, volumes...)
+	}
+	return sf
 }
 
 // SetupWithManager setup new manager for mysql
 func (r *MySQLReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&mysqlv1alpha1.MySQL{}).
+		For(&mysqlv1beta1.MySQL{}).
 		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
 		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.Job{}).
+		// 백업은 소유자 참조가 없으므로 Spec.Cluster 로 클러스터를 찾는다
+		Watches(&source.Kind{Type: &mysqlv1alpha1.MySQLBackup{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+				b, ok := o.Object.(*mysqlv1alpha1.MySQLBackup)
+				if !ok || b.Spec.Cluster == "" {
+					return nil
+				}
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: b.Namespace, Name: b.Spec.Cluster}}}
+			}),
+		}).
 		Complete(r)
 }
@@ -11,6 +11,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -19,21 +20,22 @@ import (
 var _ = Describe("mysql reconcile", func() {
 	Context("with not created yet", func() {
 		var (
-			mysql *v1alpha1.MySQL
+			mysql *v1beta1.MySQL
 			r     *MySQLReconciler
 		)
 
 		BeforeEach(func() {
 			s := scheme.Scheme
 			Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
-			mysql = &v1alpha1.MySQL{
+			Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+			mysql = &v1beta1.MySQL{
 				ObjectMeta: v1.ObjectMeta{
 					Name:      "sample",
 					Namespace: "default",
 				},
-				Spec: v1alpha1.MySQLSpec{
-					Replicas:  2,
-					OwnerName: "woohyung han",
+				Spec: v1beta1.MySQLSpec{
+					Replication: v1beta1.ReplicationSpec{Replicas: 2},
+					Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
 				},
 			}
 			objs := []runtime.Object{mysql}
@@ -79,5 +81,59 @@ var _ = Describe("mysql reconcile", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(r.Client.Get(context.TODO(), types.NamespacedName{Name: "sample", Namespace: "default"}, &v12.StatefulSet{})).Should(Succeed())
 		})
+
+		It("should use the default mysql image", func() {
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "sample",
+			}})
+			Expect(err).ShouldNot(HaveOccurred())
+			sf := &v12.StatefulSet{}
+			Expect(r.Client.Get(context.TODO(), types.NamespacedName{Name: "sample", Namespace: "default"}, sf)).Should(Succeed())
+			Expect(sf.Spec.Template.Spec.Containers[0].Image).Should(Equal("mysql:5.7"))
+			Expect(sf.Spec.Template.Annotations[versionAnnotation]).Should(Equal("5.7"))
+		})
+	})
+
+	Context("with version and image", func() {
+		var r *MySQLReconciler
+
+		BeforeEach(func() {
+			s := scheme.Scheme
+			Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+			Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+			mysql := &v1beta1.MySQL{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "sample",
+					Namespace: "default",
+				},
+				Spec: v1beta1.MySQLSpec{
+					Replication: v1beta1.ReplicationSpec{Replicas: 2},
+					Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+					Server: v1beta1.ServerSpec{
+						Version: "8.0.20",
+						Image:   "registry.example.com/mysql:8.0.20",
+					},
+				},
+			}
+			client := fake.NewFakeClientWithScheme(s, mysql)
+			r = &MySQLReconciler{
+				Client: client,
+				Log:    ctrl.Log.WithName("controllers").WithName("MySQL"),
+				Scheme: s,
+			}
+		})
+
+		It("should use the image and record the version", func() {
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "sample",
+			}})
+			Expect(err).ShouldNot(HaveOccurred())
+			sf := &v12.StatefulSet{}
+			Expect(r.Client.Get(context.TODO(), types.NamespacedName{Name: "sample", Namespace: "default"}, sf)).Should(Succeed())
+			Expect(sf.Spec.Template.Spec.Containers[0].Image).Should(Equal("registry.example.com/mysql:8.0.20"))
+			Expect(sf.Spec.Template.Annotations[versionAnnotation]).Should(Equal("8.0.20"))
+		})
 	})
 })
@@ -0,0 +1,390 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/backup"
+	"sample-mysql-operator/pkg/storage"
+)
+
+const (
+	// backupLabel 은 백업 잡과 그 파드에 붙이는 백업 이름 레이블이다
+	backupLabel = "mysql.sample.com/backup"
+	// backupRetryInterval 은 백업을 받을 파드가 없을 때 다시 확인하는 주기이다
+	backupRetryInterval = 30 * time.Second
+	// backupStorageMountPath 는 백업 잡이 볼륨 클레임 저장소를 마운트하는 경로이다
+	backupStorageMountPath = "/backup"
+	// xtrabackupPort 는 xtrabackup 사이드카가 백업 스트림을 보내는 포트이다
+	xtrabackupPort = 3307
+	// backupArtifactsFinalizer 는 MySQLBackup 을 삭제할 때 저장소의 백업 파일도 삭제하도록 하는 파이널라이저이다
+	backupArtifactsFinalizer = "mysql.sample.com/backup-artifacts"
+)
+
+// MySQLBackupReconciler reconciles a MySQLBackup object
+type MySQLBackupReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// AgentImage 는 백업 잡이 실행할 에이전트 이미지이다. 보통 오퍼레이터와 같은 이미지이다
+	AgentImage string
+}
+
+// +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqlbackups,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqlbackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqls,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile is reconcile loop for MySQLBackup
+func (r *MySQLBackupReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("mysqlbackup", req.NamespacedName)
+
+	b := &mysqlv1alpha1.MySQLBackup{}
+	if err := r.Get(context.TODO(), req.NamespacedName, b); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if b.GetDeletionTimestamp() != nil {
+		return r.deleteArtifacts(b)
+	}
+	// 끝난 백업은 다시 받지 않는다
+	if b.Status.Phase == mysqlv1alpha1.BackupPhaseCompleted || b.Status.Phase == mysqlv1alpha1.BackupPhaseFailed {
+		return ctrl.Result{}, nil
+	}
+	if b.Status.Phase == mysqlv1alpha1.BackupPhaseRunning {
+		return ctrl.Result{}, r.syncBackupJob(b)
+	}
+
+	if err := storage.Validate(&b.Spec.Storage); err != nil {
+		return ctrl.Result{}, r.failBackup(b, fmt.Sprintf("Invalid storage: %v", err))
+	}
+	mysql := &mysqlv1beta1.MySQL{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: b.Namespace, Name: b.Spec.Cluster}, mysql); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, r.failBackup(b, fmt.Sprintf("MySQL %s not found", b.Spec.Cluster))
+		}
+		return ctrl.Result{}, err
+	}
+	source := backupSourcePod(mysql, b.Spec.Source)
+	if source == "" {
+		// 복제가 정상인 레플리카가 생기면 백업을 시작한다
+		log.Info("No pod to back up from", "source", b.Spec.Source)
+		return ctrl.Result{RequeueAfter: backupRetryInterval}, r.updateBackupStatus(b, mysqlv1alpha1.BackupPhasePending,
+			fmt.Sprintf("Waiting for a healthy %s of %s", backupSource(b.Spec.Source), mysql.Name))
+	}
+
+	job := newAgentJob(b, backupJobName(b), r.AgentImage, []string{
+		"backup",
+		"--host=" + podHost(mysql, source),
+		fmt.Sprintf("--port=%d", xtrabackupPort),
+		"--key=" + backupKey(b),
+	})
+	if err := controllerutil.SetControllerReference(b, job, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+	log.Info("Start backup", "source", source, "job", job.Name)
+	if err := r.Create(context.TODO(), job); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+	now := metav1.Now()
+	b.Status.SourcePod = source
+	b.Status.ServerVersion = mysql.Status.Version
+	b.Status.StartTime = &now
+	r.Recorder.Eventf(b, corev1.EventTypeNormal, "BackupStarted", "Backing up %s from %s", mysql.Name, source)
+	return ctrl.Result{}, r.updateBackupStatus(b, mysqlv1alpha1.BackupPhaseRunning, fmt.Sprintf("Backing up from %s", source))
+}
+
+// syncBackupJob 은 백업 잡이 끝나면 잡이 남긴 결과를 상태에 기록한다
+func (r *MySQLBackupReconciler) syncBackupJob(b *mysqlv1alpha1.MySQLBackup) error {
+	job := &batchv1.Job{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: b.Namespace, Name: backupJobName(b)}, job); err != nil {
+		if errors.IsNotFound(err) {
+			return r.failBackup(b, "Backup job was deleted")
+		}
+		return err
+	}
+	finished, failed := jobFinished(job)
+	if !finished {
+		return nil
+	}
+
+	result, message, err := r.backupResult(job)
+	if err != nil {
+		return err
+	}
+	if failed || result == nil {
+		if message == "" {
+			message = "Backup job failed"
+		}
+		return r.failBackup(b, message)
+	}
+
+	now := metav1.Now()
+	b.Status.Location = result.Location
+	b.Status.Size = resource.NewQuantity(result.Size, resource.BinarySI)
+	if result.ServerVersion != "" {
+		b.Status.ServerVersion = result.ServerVersion
+	}
+	b.Status.BinlogFile = result.BinlogFile
+	b.Status.BinlogPosition = result.BinlogPosition
+	b.Status.GTIDSet = result.GTIDSet
+	b.Status.CompletionTime = &now
+	r.Log.Info("Backup completed", "mysqlbackup", b.Name, "location", result.Location)
+	r.Recorder.Eventf(b, corev1.EventTypeNormal, "BackupCompleted", "Stored backup at %s", result.Location)
+	return r.updateBackupStatus(b, mysqlv1alpha1.BackupPhaseCompleted, "Backup completed")
+}
+
+// deleteArtifacts 는 파이널라이저가 있으면 에이전트 잡으로 저장소의 백업 파일을 삭제한 뒤 파이널라이저를 제거한다.
+// 삭제에 실패하면 잡을 지우고 다시 시도한다
+func (r *MySQLBackupReconciler) deleteArtifacts(b *mysqlv1alpha1.MySQLBackup) (ctrl.Result, error) {
+	if !contains(b.GetFinalizers(), backupArtifactsFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	if b.Status.Location == "" {
+		// 저장소에 아무것도 올리지 않은 백업이다
+		controllerutil.RemoveFinalizer(b, backupArtifactsFinalizer)
+		return ctrl.Result{}, r.Update(context.TODO(), b)
+	}
+
+	job := &batchv1.Job{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: b.Namespace, Name: backupDeleteJobName(b)}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		job = newAgentJob(b, backupDeleteJobName(b), r.AgentImage, []string{"delete", "--key=" + backupKey(b)})
+		if err := controllerutil.SetControllerReference(b, job, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Log.Info("Delete backup artifacts", "mysqlbackup", b.Name, "location", b.Status.Location)
+		if err := r.Create(context.TODO(), job); err != nil && !errors.IsAlreadyExists(err) {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	finished, failed := jobFinished(job)
+	if !finished {
+		return ctrl.Result{}, nil
+	}
+	if failed {
+		r.Recorder.Eventf(b, corev1.EventTypeWarning, "DeleteFailed", "Failed to delete %s", b.Status.Location)
+		if err := r.updateBackupStatus(b, b.Status.Phase, fmt.Sprintf("Failed to delete %s, retrying", b.Status.Location)); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.Delete(context.TODO(), job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: backupRetryInterval}, nil
+	}
+	r.Log.Info("Deleted backup artifacts", "mysqlbackup", b.Name, "location", b.Status.Location)
+	controllerutil.RemoveFinalizer(b, backupArtifactsFinalizer)
+	return ctrl.Result{}, r.Update(context.TODO(), b)
+}
+
+// backupResult 는 백업 잡 파드의 종료 메시지에서 백업 결과를 읽는다. 실패한 잡이면 종료 메시지를 그대로 반환한다
+func (r *MySQLBackupReconciler) backupResult(job *batchv1.Job) (*backup.Result, string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(context.TODO(), pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return nil, "", err
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if terminated == nil || terminated.Message == "" {
+				continue
+			}
+			if terminated.ExitCode != 0 {
+				return nil, terminated.Message, nil
+			}
+			result := &backup.Result{}
+			if err := json.Unmarshal([]byte(terminated.Message), result); err != nil {
+				return nil, fmt.Sprintf("Invalid backup result: %v", err), nil
+			}
+			return result, "", nil
+		}
+	}
+	return nil, "", nil
+}
+
+func (r *MySQLBackupReconciler) failBackup(b *mysqlv1alpha1.MySQLBackup, message string) error {
+	now := metav1.Now()
+	b.Status.CompletionTime = &now
+	r.Log.Info("Backup failed", "mysqlbackup", b.Name, "message", message)
+	r.Recorder.Event(b, corev1.EventTypeWarning, "BackupFailed", message)
+	return r.updateBackupStatus(b, mysqlv1alpha1.BackupPhaseFailed, message)
+}
+
+func (r *MySQLBackupReconciler) updateBackupStatus(b *mysqlv1alpha1.MySQLBackup, phase mysqlv1alpha1.BackupPhase, message string) error {
+	if b.Status.Phase == phase && b.Status.Message == message {
+		return nil
+	}
+	b.Status.Phase = phase
+	b.Status.Message = message
+	return r.Status().Update(context.TODO(), b)
+}
+
+func backupSource(source mysqlv1alpha1.BackupSource) mysqlv1alpha1.BackupSource {
+	if source == "" {
+		return mysqlv1alpha1.BackupSourceReplica
+	}
+	return source
+}
+
+// backupSourcePod 는 백업을 받을 파드를 고른다. 레플리카는 복제가 정상인 것 중 지연이 가장 적은 파드를 고른다
+func backupSourcePod(mysql *mysqlv1beta1.MySQL, source mysqlv1alpha1.BackupSource) string {
+	if backupSource(source) == mysqlv1alpha1.BackupSourcePrimary {
+		for _, instance := range mysql.Status.Instances {
+			if instance.Name == mysql.Status.Primary && instance.Role == rolePrimary {
+				return instance.Name
+			}
+		}
+		return ""
+	}
+	var best *mysqlv1beta1.InstanceStatus
+	for i := range mysql.Status.Instances {
+		instance := &mysql.Status.Instances[i]
+		if instance.Role != roleReplica || !instance.IOThreadRunning || !instance.SQLThreadRunning || instance.SecondsBehindSource == nil {
+			continue
+		}
+		if best == nil || *instance.SecondsBehindSource < *best.SecondsBehindSource {
+			best = instance
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.Name
+}
+
+func backupJobName(b *mysqlv1alpha1.MySQLBackup) string {
+	return b.Name + "-backup"
+}
+
+func backupDeleteJobName(b *mysqlv1alpha1.MySQLBackup) string {
+	return b.Name + "-delete"
+}
+
+// backupKey 는 저장소에서 백업을 저장할 키를 반환한다
+func backupKey(b *mysqlv1alpha1.MySQLBackup) string {
+	return fmt.Sprintf("%s/%s.xbstream", b.Spec.Cluster, b.Name)
+}
+
+// newAgentJob 은 백업 저장소에 접근할 수 있도록 설정한 에이전트 잡을 만든다. args 의 첫 번째 값은 에이전트 명령이다
+func newAgentJob(b *mysqlv1alpha1.MySQLBackup, name, image string, args []string) *batchv1.Job {
+	env, volumes, volumeMounts := backupStorageAccess(&b.Spec.Storage, "backup")
+	backoffLimit := int32(0)
+	labels := map[string]string{backupLabel: b.Name}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: b.Namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:         "agent",
+							Image:        image,
+							Command:      []string{"/agent"},
+							Args:         args,
+							Env:          env,
+							VolumeMounts: volumeMounts,
+							// 실패하면 로그의 마지막 부분을 종료 메시지로 남긴다
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+}
+
+// backupStorageAccess 는 에이전트가 저장소에 접근하는 데 필요한 환경 변수와 볼륨을 반환한다.
+// 저장소는 JSON 으로 BACKUP_STORAGE 환경 변수에 넘기고, 볼륨 클레임 저장소는 volume 이름의 볼륨으로 마운트한다
+func backupStorageAccess(spec *mysqlv1alpha1.BackupStorage, volume string) ([]corev1.EnvVar, []corev1.Volume, []corev1.VolumeMount) {
+	storageSpec, _ := json.Marshal(spec)
+	env := []corev1.EnvVar{{Name: "BACKUP_STORAGE", Value: string(storageSpec)}}
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	if pvc := spec.PersistentVolumeClaim; pvc != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: volume,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.ClaimName},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: volume, MountPath: backupStorageMountPath})
+	}
+	if s3 := spec.S3; s3 != nil {
+		env = append(env, s3CredentialEnv(s3)...)
+	}
+	return env, volumes, volumeMounts
+}
+
+// s3CredentialEnv 는 S3 호환 저장소의 인증 정보를 시크릿에서 읽는 환경 변수를 반환한다
+func s3CredentialEnv(s3 *mysqlv1alpha1.S3BackupStorage) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name: "AWS_ACCESS_KEY_ID",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: s3.CredentialsSecret,
+				Key:                  "accessKeyID",
+			}},
+		},
+		{
+			Name: "AWS_SECRET_ACCESS_KEY",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: s3.CredentialsSecret,
+				Key:                  "secretAccessKey",
+			}},
+		},
+	}
+}
+
+// SetupWithManager setup new manager for mysqlbackup
+func (r *MySQLBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mysqlv1alpha1.MySQLBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql backup", func() {
+	var (
+		mysql  *v1beta1.MySQL
+		backup *v1alpha1.MySQLBackup
+		r      *MySQLBackupReconciler
+		req    = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "nightly"}}
+	)
+
+	reconcileBackup := func() ctrl.Result {
+		if r == nil {
+			s := scheme.Scheme
+			Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+			Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+			r = &MySQLBackupReconciler{
+				Client:     fake.NewFakeClientWithScheme(s, mysql, backup),
+				Log:        ctrl.Log.WithName("controllers").WithName("MySQLBackup"),
+				Scheme:     s,
+				Recorder:   record.NewFakeRecorder(10),
+				AgentImage: "controller:latest",
+			}
+		}
+		result, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		// 가짜 클라이언트는 읽은 값을 기존 오브젝트에 덮어쓰므로 제거된 파이널라이저가 남지 않도록 새 오브젝트로 읽는다
+		backup = &v1alpha1.MySQLBackup{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, backup)).Should(Succeed())
+		return result
+	}
+
+	getJob := func() *batchv1.Job {
+		job := &batchv1.Job{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "nightly-backup"}, job)).Should(Succeed())
+		return job
+	}
+
+	// finishJob 은 백업 잡이 종료 메시지를 남기고 끝난 것처럼 만든다
+	finishJob := func(jobCondition batchv1.JobConditionType, exitCode int32, message string) {
+		job := getJob()
+		job.Status.Conditions = []batchv1.JobCondition{{Type: jobCondition, Status: corev1.ConditionTrue}}
+		Expect(r.Client.Status().Update(context.TODO(), job)).Should(Succeed())
+		Expect(r.Client.Create(context.TODO(), &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "nightly-backup-abcde",
+				Namespace: "default",
+				Labels:    map[string]string{"job-name": job.Name},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: "agent",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: exitCode,
+						Message:  message,
+					}},
+				}},
+			},
+		})).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		r = nil
+		lag1, lag2 := int64(8), int64(2)
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+			},
+			Status: v1beta1.MySQLStatus{
+				Primary: "sample-0",
+				Version: "8.0.20",
+				Instances: []v1beta1.InstanceStatus{
+					{Name: "sample-0", Role: rolePrimary},
+					{Name: "sample-1", Role: roleReplica, IOThreadRunning: true, SQLThreadRunning: true, SecondsBehindSource: &lag1},
+					{Name: "sample-2", Role: roleReplica, IOThreadRunning: true, SQLThreadRunning: true, SecondsBehindSource: &lag2},
+				},
+			},
+		}
+		backup = &v1alpha1.MySQLBackup{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "nightly",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.MySQLBackupSpec{
+				Cluster: "sample",
+				Storage: v1alpha1.BackupStorage{
+					S3: &v1alpha1.S3BackupStorage{
+						Endpoint:          "minio.minio:9000",
+						Bucket:            "backups",
+						CredentialsSecret: corev1.LocalObjectReference{Name: "minio"},
+					},
+				},
+			},
+		}
+	})
+
+	It("should back up from the replica with the least lag", func() {
+		reconcileBackup()
+
+		Expect(backup.Status.Phase).Should(Equal(v1alpha1.BackupPhaseRunning))
+		Expect(backup.Status.SourcePod).Should(Equal("sample-2"))
+		Expect(backup.Status.StartTime).ShouldNot(BeNil())
+
+		container := getJob().Spec.Template.Spec.Containers[0]
+		Expect(container.Image).Should(Equal("controller:latest"))
+		Expect(container.Args).Should(ContainElement("--host=sample-2.sample"))
+		Expect(container.Args).Should(ContainElement("--key=sample/nightly.xbstream"))
+		Expect(container.Env[1].ValueFrom.SecretKeyRef.Name).Should(Equal("minio"))
+	})
+
+	It("should back up from the primary when requested", func() {
+		backup.Spec.Source = v1alpha1.BackupSourcePrimary
+		reconcileBackup()
+		Expect(backup.Status.SourcePod).Should(Equal("sample-0"))
+	})
+
+	It("should wait for a healthy replica", func() {
+		for i := range mysql.Status.Instances {
+			mysql.Status.Instances[i].SQLThreadRunning = false
+		}
+		Expect(reconcileBackup().RequeueAfter).Should(Equal(backupRetryInterval))
+		Expect(backup.Status.Phase).Should(Equal(v1alpha1.BackupPhasePending))
+	})
+
+	It("should mount the claim of a filesystem storage", func() {
+		backup.Spec.Storage = v1alpha1.BackupStorage{
+			PersistentVolumeClaim: &v1alpha1.PVCBackupStorage{ClaimName: "backups"},
+		}
+		reconcileBackup()
+
+		spec := getJob().Spec.Template.Spec
+		Expect(spec.Volumes[0].PersistentVolumeClaim.ClaimName).Should(Equal("backups"))
+		Expect(spec.Containers[0].VolumeMounts[0].MountPath).Should(Equal(backupStorageMountPath))
+	})
+
+	It("should record the result of the backup job", func() {
+		reconcileBackup()
+		finishJob(batchv1.JobComplete, 0, `{"location":"s3://backups/sample/nightly.xbstream","size":1048576,"serverVersion":"8.0.20",`+
+			`"binlogFile":"binlog.000003","binlogPosition":1234,"gtidSet":"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10"}`)
+		reconcileBackup()
+
+		Expect(backup.Status.Phase).Should(Equal(v1alpha1.BackupPhaseCompleted))
+		Expect(backup.Status.Location).Should(Equal("s3://backups/sample/nightly.xbstream"))
+		Expect(backup.Status.Size.String()).Should(Equal("1Mi"))
+		Expect(backup.Status.BinlogFile).Should(Equal("binlog.000003"))
+		Expect(backup.Status.BinlogPosition).Should(Equal(int64(1234)))
+		Expect(backup.Status.GTIDSet).Should(Equal("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10"))
+		Expect(backup.Status.CompletionTime).ShouldNot(BeNil())
+	})
+
+	It("should fail when the backup job fails", func() {
+		reconcileBackup()
+		finishJob(batchv1.JobFailed, 1, "dial tcp: connection refused")
+		reconcileBackup()
+
+		Expect(backup.Status.Phase).Should(Equal(v1alpha1.BackupPhaseFailed))
+		Expect(backup.Status.Message).Should(Equal("dial tcp: connection refused"))
+	})
+
+	It("should delete the stored backup before it is removed", func() {
+		deletedAt := v1.Now()
+		backup.DeletionTimestamp = &deletedAt
+		backup.Finalizers = []string{backupArtifactsFinalizer}
+		backup.Status.Phase = v1alpha1.BackupPhaseCompleted
+		backup.Status.Location = "s3://backups/sample/nightly.xbstream"
+		reconcileBackup()
+
+		job := &batchv1.Job{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "nightly-delete"}, job)).Should(Succeed())
+		Expect(job.Spec.Template.Spec.Containers[0].Args).Should(Equal([]string{"delete", "--key=sample/nightly.xbstream"}))
+		Expect(backup.Finalizers).Should(ContainElement(backupArtifactsFinalizer))
+
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(r.Client.Status().Update(context.TODO(), job)).Should(Succeed())
+		reconcileBackup()
+		Expect(backup.Finalizers).ShouldNot(ContainElement(backupArtifactsFinalizer))
+	})
+
+	It("should fail without a storage", func() {
+		backup.Spec.Storage = v1alpha1.BackupStorage{}
+		reconcileBackup()
+		Expect(backup.Status.Phase).Should(Equal(v1alpha1.BackupPhaseFailed))
+	})
+})
@@ -0,0 +1,351 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+)
+
+const (
+	// scheduleLabel 은 스케줄이 만든 백업에 붙이는 스케줄 이름 레이블이다
+	scheduleLabel = "mysql.sample.com/schedule"
+	// scheduledAtAnnotation 은 스케줄이 만든 백업이 예정되었던 시각이다. 보존 정책은 이 시각을 기준으로 적용한다
+	scheduledAtAnnotation = "mysql.sample.com/scheduled-at"
+)
+
+// MySQLBackupScheduleReconciler reconciles a MySQLBackupSchedule object
+type MySQLBackupScheduleReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// now 는 현재 시각을 반환한다. 비어 있으면 time.Now 를 사용하며, 테스트에서 시각을 고정할 때 사용한다
+	now func() time.Time
+}
+
+// +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqlbackupschedules,verbs=get;list;watch
+// +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqlbackupschedules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mysql.sample.com,resources=mysqlbackups,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile is reconcile loop for MySQLBackupSchedule
+func (r *MySQLBackupScheduleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("mysqlbackupschedule", req.NamespacedName)
+
+	schedule := &mysqlv1alpha1.MySQLBackupSchedule{}
+	if err := r.Get(context.TODO(), req.NamespacedName, schedule); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	status := schedule.Status.DeepCopy()
+
+	backups := &mysqlv1alpha1.MySQLBackupList{}
+	if err := r.List(context.TODO(), backups, client.InNamespace(schedule.Namespace), client.MatchingLabels{scheduleLabel: schedule.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	sort.Slice(backups.Items, func(i, j int) bool {
+		return scheduledTime(&backups.Items[i]).After(scheduledTime(&backups.Items[j]))
+	})
+	observeScheduledBackups(schedule, backups.Items)
+
+	var result ctrl.Result
+	sched, loc, err := parseSchedule(&schedule.Spec)
+	if err != nil {
+		setScheduleCondition(schedule, condition.Condition{
+			Type:    mysqlv1alpha1.ConditionTypeScheduleMissed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "InvalidSchedule",
+			Message: err.Error(),
+		})
+	} else {
+		if err := r.pruneBackups(schedule, backups.Items, loc); err != nil {
+			return ctrl.Result{}, err
+		}
+		now := r.clock()
+		if !schedule.Spec.Suspend {
+			if err := r.runSchedule(schedule, backups.Items, sched, loc, now); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		// 다음 예정 시각에 다시 확인한다
+		result.RequeueAfter = sched.Next(now.In(loc)).Sub(now)
+		log.V(1).Info("Next backup", "after", result.RequeueAfter)
+	}
+
+	if !equality.Semantic.DeepEqual(status, &schedule.Status) {
+		if err := r.Status().Update(context.TODO(), schedule); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// runSchedule 은 마지막으로 처리한 시각 이후에 예정된 시각이 지났으면 백업을 만든다. 그 사이에 여러 시각이 지났으면
+// 가장 최근 시각의 백업만 만들고 나머지는 놓친 것으로 기록한다
+func (r *MySQLBackupScheduleReconciler) runSchedule(schedule *mysqlv1alpha1.MySQLBackupSchedule, backups []mysqlv1alpha1.MySQLBackup, sched cron.Schedule, loc *time.Location, now time.Time) error {
+	start := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		start = schedule.Status.LastScheduleTime.Time
+	}
+	if start.IsZero() {
+		return nil
+	}
+	var scheduled time.Time
+	missed := 0
+	for t := sched.Next(start.In(loc)); !t.After(now); t = sched.Next(t) {
+		if !scheduled.IsZero() {
+			missed++
+		}
+		scheduled = t
+	}
+	if scheduled.IsZero() {
+		return nil
+	}
+	scheduledAt := metav1.NewTime(scheduled)
+	schedule.Status.LastScheduleTime = &scheduledAt
+
+	if deadline := schedule.Spec.StartingDeadlineSeconds; deadline != nil && now.Sub(scheduled) > time.Duration(*deadline)*time.Second {
+		r.Recorder.Eventf(schedule, corev1.EventTypeWarning, "MissedSchedule", "Missed the backup scheduled at %s", scheduled.Format(time.RFC3339))
+		setScheduleCondition(schedule, condition.Condition{
+			Type:    mysqlv1alpha1.ConditionTypeScheduleMissed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "MissedDeadline",
+			Message: fmt.Sprintf("Missed %d scheduled backups, the last one at %s", missed+1, scheduled.Format(time.RFC3339)),
+		})
+		return nil
+	}
+	for i := range backups {
+		if phase := backups[i].Status.Phase; phase == "" || phase == mysqlv1alpha1.BackupPhasePending || phase == mysqlv1alpha1.BackupPhaseRunning {
+			// 같은 클러스터의 백업을 동시에 받지 않는다
+			r.Recorder.Eventf(schedule, corev1.EventTypeWarning, "MissedSchedule", "Skipped the backup scheduled at %s because %s is still running",
+				scheduled.Format(time.RFC3339), backups[i].Name)
+			setScheduleCondition(schedule, condition.Condition{
+				Type:    mysqlv1alpha1.ConditionTypeScheduleMissed,
+				Status:  corev1.ConditionTrue,
+				Reason:  "BackupInProgress",
+				Message: fmt.Sprintf("Skipped the backup scheduled at %s because %s is still running", scheduled.Format(time.RFC3339), backups[i].Name),
+			})
+			return nil
+		}
+	}
+
+	b := newScheduledBackup(schedule, scheduled)
+	r.Log.Info("Create scheduled backup", "mysqlbackupschedule", schedule.Name, "mysqlbackup", b.Name)
+	if err := r.Create(context.TODO(), b); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	schedule.Status.LastBackup = b.Name
+	if missed > 0 {
+		r.Recorder.Eventf(schedule, corev1.EventTypeWarning, "MissedSchedule", "Missed %d scheduled backups before %s", missed, scheduled.Format(time.RFC3339))
+		setScheduleCondition(schedule, condition.Condition{
+			Type:    mysqlv1alpha1.ConditionTypeScheduleMissed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "MissedSchedules",
+			Message: fmt.Sprintf("Missed %d scheduled backups before %s", missed, scheduled.Format(time.RFC3339)),
+		})
+		return nil
+	}
+	setScheduleCondition(schedule, condition.Condition{
+		Type:    mysqlv1alpha1.ConditionTypeScheduleMissed,
+		Status:  corev1.ConditionFalse,
+		Reason:  "Scheduled",
+		Message: fmt.Sprintf("Created %s", b.Name),
+	})
+	return nil
+}
+
+// pruneBackups 는 보존 정책에 따라 보존하지 않는 백업을 삭제한다. 저장소의 파일은 백업의 파이널라이저가 삭제한다
+func (r *MySQLBackupScheduleReconciler) pruneBackups(schedule *mysqlv1alpha1.MySQLBackupSchedule, backups []mysqlv1alpha1.MySQLBackup, loc *time.Location) error {
+	for _, b := range expiredBackups(backups, &schedule.Spec.Retention, loc) {
+		if b.GetDeletionTimestamp() != nil {
+			continue
+		}
+		r.Log.Info("Delete expired backup", "mysqlbackupschedule", schedule.Name, "mysqlbackup", b.Name)
+		if err := r.Delete(context.TODO(), b); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MySQLBackupScheduleReconciler) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// observeScheduledBackups 는 가장 최근에 끝난 백업의 결과를 상태에 기록한다. backups 는 최근 순으로 정렬되어 있어야 한다
+func observeScheduledBackups(schedule *mysqlv1alpha1.MySQLBackupSchedule, backups []mysqlv1alpha1.MySQLBackup) {
+	for i := range backups {
+		if backups[i].Status.Phase == mysqlv1alpha1.BackupPhaseCompleted {
+			completedAt := metav1.NewTime(scheduledTime(&backups[i]))
+			schedule.Status.LastSuccessfulTime = &completedAt
+			break
+		}
+	}
+	for i := range backups {
+		switch backups[i].Status.Phase {
+		case mysqlv1alpha1.BackupPhaseCompleted:
+			setScheduleCondition(schedule, condition.Condition{
+				Type:    mysqlv1alpha1.ConditionTypeBackupFailed,
+				Status:  corev1.ConditionFalse,
+				Reason:  "BackupCompleted",
+				Message: fmt.Sprintf("%s completed", backups[i].Name),
+			})
+			return
+		case mysqlv1alpha1.BackupPhaseFailed:
+			setScheduleCondition(schedule, condition.Condition{
+				Type:    mysqlv1alpha1.ConditionTypeBackupFailed,
+				Status:  corev1.ConditionTrue,
+				Reason:  "BackupFailed",
+				Message: fmt.Sprintf("%s failed: %s", backups[i].Name, backups[i].Status.Message),
+			})
+			return
+		}
+	}
+}
+
+// expiredBackups 는 보존 정책이 보존하지 않는 완료된 백업과, 그보다 나중에 완료된 백업이 있는 실패한 백업을 반환한다.
+// 진행 중인 백업은 반환하지 않는다. backups 는 최근 순으로 정렬되어 있어야 한다
+func expiredBackups(backups []mysqlv1alpha1.MySQLBackup, retention *mysqlv1alpha1.BackupRetention, loc *time.Location) []*mysqlv1alpha1.MySQLBackup {
+	if retention.KeepLast == nil && retention.KeepDaily == nil && retention.KeepWeekly == nil {
+		return nil
+	}
+	var expired []*mysqlv1alpha1.MySQLBackup
+	days, weeks := map[string]bool{}, map[string]bool{}
+	completed := 0
+	for i := range backups {
+		b := &backups[i]
+		switch b.Status.Phase {
+		case mysqlv1alpha1.BackupPhaseFailed:
+			if completed > 0 {
+				expired = append(expired, b)
+			}
+			continue
+		case mysqlv1alpha1.BackupPhaseCompleted:
+		default:
+			continue
+		}
+		completed++
+
+		t := scheduledTime(b).In(loc)
+		year, week := t.ISOWeek()
+		day, weekKey := t.Format("2006-01-02"), fmt.Sprintf("%d-%d", year, week)
+		keep := false
+		if retention.KeepLast != nil && completed <= int(*retention.KeepLast) {
+			keep = true
+		}
+		if retention.KeepDaily != nil && !days[day] && len(days) < int(*retention.KeepDaily) {
+			days[day] = true
+			keep = true
+		}
+		if retention.KeepWeekly != nil && !weeks[weekKey] && len(weeks) < int(*retention.KeepWeekly) {
+			weeks[weekKey] = true
+			keep = true
+		}
+		if !keep {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
+
+// parseSchedule 은 cron 표현식과 시간대를 해석한다
+func parseSchedule(spec *mysqlv1alpha1.MySQLBackupScheduleSpec) (cron.Schedule, *time.Location, error) {
+	loc := time.UTC
+	if spec.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(spec.TimeZone); err != nil {
+			return nil, nil, fmt.Errorf("invalid time zone %q: %v", spec.TimeZone, err)
+		}
+	}
+	sched, err := cron.ParseStandard(spec.Schedule)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid schedule %q: %v", spec.Schedule, err)
+	}
+	return sched, loc, nil
+}
+
+// scheduledTime 은 백업이 예정되었던 시각을 반환한다. 어노테이션이 없으면 생성 시각을 사용한다
+func scheduledTime(b *mysqlv1alpha1.MySQLBackup) time.Time {
+	if t, err := time.Parse(time.RFC3339, b.Annotations[scheduledAtAnnotation]); err == nil {
+		return t
+	}
+	return b.CreationTimestamp.Time
+}
+
+// newScheduledBackup 은 예정된 시각의 백업을 만든다. 스케줄을 삭제해도 백업은 남도록 소유자로 지정하지 않는다
+func newScheduledBackup(schedule *mysqlv1alpha1.MySQLBackupSchedule, scheduled time.Time) *mysqlv1alpha1.MySQLBackup {
+	return &mysqlv1alpha1.MySQLBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   schedule.Namespace,
+			Name:        fmt.Sprintf("%s-%s", schedule.Name, scheduled.UTC().Format("20060102-150405")),
+			Labels:      map[string]string{scheduleLabel: schedule.Name},
+			Annotations: map[string]string{scheduledAtAnnotation: scheduled.UTC().Format(time.RFC3339)},
+			Finalizers:  []string{backupArtifactsFinalizer},
+		},
+		Spec: schedule.Spec.Backup,
+	}
+}
+
+// setScheduleCondition 은 컨디션이 변경된 경우에만 컨디션을 기록한다. 상태는 Reconcile 이 한 번에 업데이트한다
+func setScheduleCondition(schedule *mysqlv1alpha1.MySQLBackupSchedule, cond condition.Condition) {
+	if c := findCondition(schedule.Status.Conditions, cond.Type); c != nil &&
+		c.Status == cond.Status && c.Reason == cond.Reason && c.Message == cond.Message {
+		return
+	}
+	schedule.Status.Conditions.SetCondition(cond)
+}
+
+// SetupWithManager setup new manager for mysqlbackupschedule
+func (r *MySQLBackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mysqlv1alpha1.MySQLBackupSchedule{}).
+		// 스케줄이 만든 백업은 소유자 참조가 없으므로 레이블로 스케줄을 찾는다
+		Watches(&source.Kind{Type: &mysqlv1alpha1.MySQLBackup{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+				name := o.Meta.GetLabels()[scheduleLabel]
+				if name == "" {
+					return nil
+				}
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: o.Meta.GetNamespace(), Name: name}}}
+			}),
+		}).
+		Complete(r)
+}
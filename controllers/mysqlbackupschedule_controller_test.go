@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql backup schedule", func() {
+	var (
+		schedule *v1alpha1.MySQLBackupSchedule
+		backups  []runtime.Object
+		now      time.Time
+		r        *MySQLBackupScheduleReconciler
+		req      = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "nightly"}}
+	)
+
+	at := func(value string) time.Time {
+		t, err := time.Parse(time.RFC3339, value)
+		Expect(err).ShouldNot(HaveOccurred())
+		return t
+	}
+
+	scheduledBackup := func(scheduledAt string, phase v1alpha1.BackupPhase) *v1alpha1.MySQLBackup {
+		b := newScheduledBackup(schedule, at(scheduledAt))
+		b.Status.Phase = phase
+		return b
+	}
+
+	reconcileSchedule := func() ctrl.Result {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		r = &MySQLBackupScheduleReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, append(backups, schedule)...),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQLBackupSchedule"),
+			Scheme:   s,
+			Recorder: record.NewFakeRecorder(10),
+			now:      func() time.Time { return now },
+		}
+		result, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, schedule)).Should(Succeed())
+		return result
+	}
+
+	listBackups := func() []string {
+		list := &v1alpha1.MySQLBackupList{}
+		Expect(r.Client.List(context.TODO(), list, client.InNamespace("default"))).Should(Succeed())
+		var names []string
+		for _, b := range list.Items {
+			names = append(names, b.Name)
+		}
+		return names
+	}
+
+	scheduleCondition := func(t condition.ConditionType) (corev1.ConditionStatus, string) {
+		cond := findCondition(schedule.Status.Conditions, t)
+		Expect(cond).ShouldNot(BeNil())
+		return cond.Status, cond.Reason
+	}
+
+	BeforeEach(func() {
+		schedule = &v1alpha1.MySQLBackupSchedule{
+			ObjectMeta: v1.ObjectMeta{
+				Name:              "nightly",
+				Namespace:         "default",
+				CreationTimestamp: v1.NewTime(at("2020-06-09T12:00:00Z")),
+			},
+			Spec: v1alpha1.MySQLBackupScheduleSpec{
+				Schedule: "0 3 * * *",
+				Backup: v1alpha1.MySQLBackupSpec{
+					Cluster: "sample",
+					Storage: v1alpha1.BackupStorage{
+						PersistentVolumeClaim: &v1alpha1.PVCBackupStorage{ClaimName: "backups"},
+					},
+				},
+			},
+		}
+		backups = nil
+		now = at("2020-06-10T03:00:30Z")
+	})
+
+	It("should create a backup when the schedule is due", func() {
+		result := reconcileSchedule()
+
+		b := &v1alpha1.MySQLBackup{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "nightly-20200610-030000"}, b)).Should(Succeed())
+		Expect(b.Spec.Cluster).Should(Equal("sample"))
+		Expect(b.Labels[scheduleLabel]).Should(Equal("nightly"))
+		Expect(b.Finalizers).Should(ContainElement(backupArtifactsFinalizer))
+
+		Expect(schedule.Status.LastScheduleTime.Time).Should(BeTemporally("==", at("2020-06-10T03:00:00Z")))
+		Expect(schedule.Status.LastBackup).Should(Equal("nightly-20200610-030000"))
+		status, reason := scheduleCondition(v1alpha1.ConditionTypeScheduleMissed)
+		Expect(status).Should(Equal(corev1.ConditionFalse))
+		Expect(reason).Should(Equal("Scheduled"))
+		Expect(result.RequeueAfter).Should(Equal(24*time.Hour - 30*time.Second))
+	})
+
+	It("should interpret the schedule in the time zone", func() {
+		schedule.Spec.TimeZone = "Asia/Seoul"
+		now = at("2020-06-09T18:00:10Z")
+		reconcileSchedule()
+		Expect(listBackups()).Should(ConsistOf("nightly-20200609-180000"))
+	})
+
+	It("should not create a backup before the schedule is due", func() {
+		now = at("2020-06-10T02:59:00Z")
+		result := reconcileSchedule()
+		Expect(listBackups()).Should(BeEmpty())
+		Expect(result.RequeueAfter).Should(Equal(time.Minute))
+	})
+
+	It("should not create a backup while suspended", func() {
+		schedule.Spec.Suspend = true
+		reconcileSchedule()
+		Expect(listBackups()).Should(BeEmpty())
+	})
+
+	It("should report missed schedules", func() {
+		last := v1.NewTime(at("2020-06-07T03:00:00Z"))
+		schedule.Status.LastScheduleTime = &last
+		reconcileSchedule()
+
+		Expect(listBackups()).Should(ConsistOf("nightly-20200610-030000"))
+		status, reason := scheduleCondition(v1alpha1.ConditionTypeScheduleMissed)
+		Expect(status).Should(Equal(corev1.ConditionTrue))
+		Expect(reason).Should(Equal("MissedSchedules"))
+	})
+
+	It("should skip a backup past the starting deadline", func() {
+		deadline := int64(60)
+		schedule.Spec.StartingDeadlineSeconds = &deadline
+		now = at("2020-06-10T03:05:00Z")
+		reconcileSchedule()
+
+		Expect(listBackups()).Should(BeEmpty())
+		Expect(schedule.Status.LastScheduleTime.Time).Should(BeTemporally("==", at("2020-06-10T03:00:00Z")))
+		status, reason := scheduleCondition(v1alpha1.ConditionTypeScheduleMissed)
+		Expect(status).Should(Equal(corev1.ConditionTrue))
+		Expect(reason).Should(Equal("MissedDeadline"))
+	})
+
+	It("should skip a backup while the previous one is running", func() {
+		backups = []runtime.Object{scheduledBackup("2020-06-09T03:00:00Z", v1alpha1.BackupPhaseRunning)}
+		reconcileSchedule()
+
+		Expect(listBackups()).Should(ConsistOf("nightly-20200609-030000"))
+		_, reason := scheduleCondition(v1alpha1.ConditionTypeScheduleMissed)
+		Expect(reason).Should(Equal("BackupInProgress"))
+	})
+
+	It("should report the result of the last backup", func() {
+		backups = []runtime.Object{
+			scheduledBackup("2020-06-09T03:00:00Z", v1alpha1.BackupPhaseFailed),
+			scheduledBackup("2020-06-08T03:00:00Z", v1alpha1.BackupPhaseCompleted),
+		}
+		reconcileSchedule()
+
+		Expect(schedule.Status.LastSuccessfulTime.Time).Should(BeTemporally("==", at("2020-06-08T03:00:00Z")))
+		status, _ := scheduleCondition(v1alpha1.ConditionTypeBackupFailed)
+		Expect(status).Should(Equal(corev1.ConditionTrue))
+	})
+
+	It("should delete backups outside the retention policy", func() {
+		keepLast, keepDaily := int32(2), int32(3)
+		schedule.Spec.Retention = v1alpha1.BackupRetention{KeepLast: &keepLast, KeepDaily: &keepDaily}
+		now = at("2020-06-10T12:00:00Z")
+		last := v1.NewTime(at("2020-06-10T03:00:00Z"))
+		schedule.Status.LastScheduleTime = &last
+		backups = []runtime.Object{
+			scheduledBackup("2020-06-10T03:00:00Z", v1alpha1.BackupPhaseCompleted),
+			scheduledBackup("2020-06-09T15:00:00Z", v1alpha1.BackupPhaseCompleted),
+			scheduledBackup("2020-06-09T03:00:00Z", v1alpha1.BackupPhaseCompleted),
+			scheduledBackup("2020-06-08T03:00:00Z", v1alpha1.BackupPhaseCompleted),
+			scheduledBackup("2020-06-07T03:00:00Z", v1alpha1.BackupPhaseCompleted),
+			scheduledBackup("2020-06-06T03:00:00Z", v1alpha1.BackupPhaseFailed),
+		}
+		reconcileSchedule()
+
+		Expect(listBackups()).Should(ConsistOf("nightly-20200610-030000", "nightly-20200609-150000", "nightly-20200608-030000"))
+	})
+
+	It("should keep every backup without a retention policy", func() {
+		last := v1.NewTime(at("2020-06-10T03:00:00Z"))
+		schedule.Status.LastScheduleTime = &last
+		backups = []runtime.Object{
+			scheduledBackup("2020-06-10T03:00:00Z", v1alpha1.BackupPhaseCompleted),
+			scheduledBackup("2020-06-09T03:00:00Z", v1alpha1.BackupPhaseCompleted),
+		}
+		reconcileSchedule()
+		Expect(listBackups()).Should(HaveLen(2))
+	})
+
+	It("should report an invalid schedule", func() {
+		schedule.Spec.Schedule = "every night"
+		reconcileSchedule()
+		_, reason := scheduleCondition(v1alpha1.ConditionTypeScheduleMissed)
+		Expect(reason).Should(Equal("InvalidSchedule"))
+	})
+})
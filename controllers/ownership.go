@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+)
+
+// syncOwnership 은 소유자 정보를 스테이트풀셋, 파드, 데이터 볼륨 클레임, 서비스와 백업의 레이블과 어노테이션에 반영한다.
+// 파드 템플릿을 바꾸면 파드가 재시작되므로 파드와 볼륨 클레임은 직접 수정한다. 새로 만들어진 파드는 다음 동기화에서 반영된다
+func (r *MySQLReconciler) syncOwnership(mysql *mysqlv1beta1.MySQL) error {
+	var objs []runtime.Object
+	sf := &appsv1.StatefulSet{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}, sf); err == nil {
+		objs = append(objs, sf)
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	for _, name := range []string{mysql.Name, mysql.Name + "-read", mysql.Name + "-primary"} {
+		svc := &corev1.Service{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: name}, svc); err == nil {
+			objs = append(objs, svc)
+		} else if !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	// 줄이면서 남겨둔 순번의 볼륨 클레임에도 소유자 정보를 남기도록 레이블로 모두 찾는다
+	claims, err := r.dataClaims(mysql)
+	if err != nil {
+		return err
+	}
+	for i := range claims {
+		objs = append(objs, &claims[i])
+	}
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	for i := range pods {
+		objs = append(objs, &pods[i])
+	}
+	backups := &mysqlv1alpha1.MySQLBackupList{}
+	if err := r.List(context.TODO(), backups, client.InNamespace(mysql.Namespace)); err != nil {
+		return err
+	}
+	for i := range backups.Items {
+		if backups.Items[i].Spec.Cluster == mysql.Name {
+			objs = append(objs, &backups.Items[i])
+		}
+	}
+
+	for _, obj := range objs {
+		patch := client.MergeFrom(obj.DeepCopyObject())
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		if !setOwnerMetadata(accessor, mysql) {
+			continue
+		}
+		r.Log.Info("Update owner metadata", "type", fmt.Sprintf("%T", obj), "name", accessor.GetName())
+		if err := r.Patch(context.TODO(), obj, patch); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// setOwnerMetadata 는 소유자 레이블과 어노테이션을 기록하고 변경되었으면 true 를 반환한다. 소유자 정보에서 지워진 값은 리소스에서도 지운다
+func setOwnerMetadata(obj metav1.Object, mysql *mysqlv1beta1.MySQL) bool {
+	labels, labelsChanged := mergeManagedKeys(obj.GetLabels(), mysql.Spec.OwnerLabels(), mysqlv1beta1.OwnerLabel, mysqlv1beta1.TeamLabel, mysqlv1beta1.CostCenterLabel)
+	annotations, annotationsChanged := mergeManagedKeys(obj.GetAnnotations(), mysql.Spec.OwnerAnnotations(), mysqlv1beta1.OwnerAnnotation)
+	obj.SetLabels(labels)
+	obj.SetAnnotations(annotations)
+	return labelsChanged || annotationsChanged
+}
+
+// mergeManagedKeys 는 keys 에 해당하는 값만 desired 로 맞춘다. 다른 키는 그대로 둔다
+func mergeManagedKeys(current, desired map[string]string, keys ...string) (map[string]string, bool) {
+	changed := false
+	for _, key := range keys {
+		value, ok := desired[key]
+		if old, exists := current[key]; exists == ok && old == value {
+			continue
+		}
+		changed = true
+		if !ok {
+			delete(current, key)
+			continue
+		}
+		if current == nil {
+			current = map[string]string{}
+		}
+		current[key] = value
+	}
+	return current, changed
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql ownership", func() {
+	var (
+		r   *MySQLReconciler
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	BeforeEach(func() {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		mysql := &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 1},
+				Owner:       v1beta1.OwnerSpec{Name: "우형 한", Team: "db", CostCenter: "cc-1234"},
+			},
+		}
+		r = &MySQLReconciler{
+			Client: fake.NewFakeClientWithScheme(s, mysql,
+				&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "sample-0", Namespace: "default", Labels: map[string]string{"app": "sample"}}},
+				&corev1.PersistentVolumeClaim{ObjectMeta: v1.ObjectMeta{Name: "data-sample-0", Namespace: "default", Labels: map[string]string{"app": "sample"}}},
+				// 레플리카를 줄이면서 남겨둔 볼륨 클레임이다
+				&corev1.PersistentVolumeClaim{ObjectMeta: v1.ObjectMeta{Name: "data-sample-2", Namespace: "default", Labels: map[string]string{"app": "sample"}}},
+				&v1alpha1.MySQLBackup{ObjectMeta: v1.ObjectMeta{Name: "daily", Namespace: "default"}, Spec: v1alpha1.MySQLBackupSpec{Cluster: "sample"}},
+				&v1alpha1.MySQLBackup{ObjectMeta: v1.ObjectMeta{Name: "other", Namespace: "default"}, Spec: v1alpha1.MySQLBackupSpec{Cluster: "other"}},
+			),
+			Log:    ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme: s,
+		}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	// metaOf 는 이름으로 찾은 리소스의 메타데이터를 반환한다
+	metaOf := func(name string, obj interface{}) v1.ObjectMeta {
+		key := types.NamespacedName{Namespace: "default", Name: name}
+		switch o := obj.(type) {
+		case *v12.StatefulSet:
+			Expect(r.Client.Get(context.TODO(), key, o)).Should(Succeed())
+			return o.ObjectMeta
+		case *corev1.Service:
+			Expect(r.Client.Get(context.TODO(), key, o)).Should(Succeed())
+			return o.ObjectMeta
+		case *corev1.Pod:
+			Expect(r.Client.Get(context.TODO(), key, o)).Should(Succeed())
+			return o.ObjectMeta
+		case *corev1.PersistentVolumeClaim:
+			Expect(r.Client.Get(context.TODO(), key, o)).Should(Succeed())
+			return o.ObjectMeta
+		case *v1alpha1.MySQLBackup:
+			Expect(r.Client.Get(context.TODO(), key, o)).Should(Succeed())
+			return o.ObjectMeta
+		}
+		Fail("unexpected type")
+		return v1.ObjectMeta{}
+	}
+
+	// owned 는 소유자 정보를 기록해야 하는 리소스이다. 읽을 때마다 새 객체를 사용한다
+	owned := map[string]func() interface{}{
+		"sample":        func() interface{} { return &v12.StatefulSet{} },
+		"sample-read":   func() interface{} { return &corev1.Service{} },
+		"sample-0":      func() interface{} { return &corev1.Pod{} },
+		"data-sample-0": func() interface{} { return &corev1.PersistentVolumeClaim{} },
+		"data-sample-2": func() interface{} { return &corev1.PersistentVolumeClaim{} },
+		"daily":         func() interface{} { return &v1alpha1.MySQLBackup{} },
+	}
+
+	It("should label every child resource with the owner", func() {
+		for name, newObj := range owned {
+			meta := metaOf(name, newObj())
+			Expect(meta.Labels).Should(HaveKeyWithValue(v1beta1.TeamLabel, "db"), name)
+			Expect(meta.Labels).Should(HaveKeyWithValue(v1beta1.CostCenterLabel, "cc-1234"), name)
+			Expect(meta.Labels).Should(HaveKeyWithValue(v1beta1.OwnerLabel, v1beta1.OwnerLabelValue("우형 한")), name)
+			Expect(meta.Annotations).Should(HaveKeyWithValue(v1beta1.OwnerAnnotation, "우형 한"), name)
+		}
+		Expect(metaOf("sample", &corev1.Service{}).Labels).Should(HaveKeyWithValue(v1beta1.TeamLabel, "db"))
+		Expect(metaOf("sample-primary", &corev1.Service{}).Labels).Should(HaveKeyWithValue(v1beta1.TeamLabel, "db"))
+		Expect(metaOf("other", &v1alpha1.MySQLBackup{}).Labels).ShouldNot(HaveKey(v1beta1.TeamLabel))
+		// 파드 템플릿을 바꾸면 파드가 재시작되므로 템플릿에는 기록하지 않는다
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Labels).ShouldNot(HaveKey(v1beta1.TeamLabel))
+	})
+
+	It("should update and remove the owner labels", func() {
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		mysql.Spec.Owner.Team = "platform"
+		mysql.Spec.Owner.CostCenter = ""
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		for name, newObj := range owned {
+			meta := metaOf(name, newObj())
+			Expect(meta.Labels).Should(HaveKeyWithValue(v1beta1.TeamLabel, "platform"), name)
+			Expect(meta.Labels).ShouldNot(HaveKey(v1beta1.CostCenterLabel), name)
+		}
+		Expect(metaOf("sample-0", &corev1.Pod{}).Labels).Should(HaveKeyWithValue("app", "sample"))
+	})
+})
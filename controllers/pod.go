@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+)
+
+const (
+	// roleLabel 은 파드의 복제 역할을 나타내는 레이블이다. 오퍼레이터가 관리하며 프라이머리 서비스가 이 레이블로 파드를 선택한다
+	roleLabel   = "mysql.sample.com/role"
+	rolePrimary = "primary"
+	roleReplica = "replica"
+)
+
+// podName 은 순번에 해당하는 MySQL 파드의 이름을 반환한다
+func podName(mysql *mysqlv1beta1.MySQL, ordinal int) string {
+	return fmt.Sprintf("%s-%d", mysql.Name, ordinal)
+}
+
+// podHost 는 헤드리스 서비스를 통해 파드에 접근할 수 있는 호스트 이름을 반환한다
+func podHost(mysql *mysqlv1beta1.MySQL, name string) string {
+	return name + "." + mysql.Name
+}
+
+// podOrdinal 은 스테이트풀셋 파드 이름에서 순번을 추출한다
+func podOrdinal(name string) int {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return -1
+	}
+	ordinal, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+// primaryPodName 은 현재 프라이머리 역할을 하는 파드의 이름을 반환한다
+func primaryPodName(mysql *mysqlv1beta1.MySQL) string {
+	if mysql.Status.Primary != "" {
+		return mysql.Status.Primary
+	}
+	return podName(mysql, 0)
+}
+
+// syncPodRoles 는 현재 프라이머리를 상태에 기록하고, 각 파드의 역할 레이블을 프라이머리에 맞춘다
+func (r *MySQLReconciler) syncPodRoles(mysql *mysqlv1beta1.MySQL) error {
+	if mysql.Status.Primary == "" {
+		mysql.Status.Primary = primaryPodName(mysql)
+		r.Log.Info("Update primary", "primary", mysql.Status.Primary)
+		if err := r.Status().Update(context.TODO(), mysql); err != nil {
+			return err
+		}
+	}
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	for i := range pods {
+		role := roleReplica
+		if pods[i].Name == mysql.Status.Primary {
+			role = rolePrimary
+		}
+		if err := r.setPodLabel(&pods[i], roleLabel, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPodLabel 은 파드의 레이블 값이 다르면 패치한다
+func (r *MySQLReconciler) setPodLabel(pod *corev1.Pod, key, value string) error {
+	if pod.Labels[key] == value {
+		return nil
+	}
+	r.Log.Info("Update pod label", "pod", pod.Name, "label", key, "value", value)
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	pod.Labels[key] = value
+	return r.Patch(context.TODO(), pod, patch)
+}
+
+func (r *MySQLReconciler) listPods(mysql *mysqlv1beta1.MySQL) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(context.TODO(), pods, client.InNamespace(mysql.Namespace), client.MatchingLabels{"app": mysql.Name}); err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isContainerRunning 은 파드에서 컨테이너가 실행 중이라고 마지막으로 보고되었는지 확인한다
+func isContainerRunning(pod *corev1.Pod, name string) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == name {
+			return status.State.Running != nil
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql roles", func() {
+	var (
+		mysql *v1beta1.MySQL
+		r     *MySQLReconciler
+		req   = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	newPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"app": "sample"},
+			},
+		}
+	}
+
+	reconcileWith := func(objs ...runtime.Object) {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		r = &MySQLReconciler{
+			Client: fake.NewFakeClientWithScheme(s, append(objs, mysql)...),
+			Log:    ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme: s,
+		}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	getService := func(name string) *corev1.Service {
+		svc := &corev1.Service{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, svc)).Should(Succeed())
+		return svc
+	}
+
+	BeforeEach(func() {
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+			},
+		}
+	})
+
+	It("should label pods with their role", func() {
+		reconcileWith(newPod("sample-0"), newPod("sample-1"))
+
+		updated := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, updated)).Should(Succeed())
+		Expect(updated.Status.Primary).Should(Equal("sample-0"))
+		pod := &corev1.Pod{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-0"}, pod)).Should(Succeed())
+		Expect(pod.Labels[roleLabel]).Should(Equal(rolePrimary))
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-1"}, pod)).Should(Succeed())
+		Expect(pod.Labels[roleLabel]).Should(Equal(roleReplica))
+	})
+
+	It("should follow the primary of the status", func() {
+		mysql.Status.Primary = "sample-1"
+		reconcileWith(newPod("sample-0"), newPod("sample-1"))
+
+		pod := &corev1.Pod{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-0"}, pod)).Should(Succeed())
+		Expect(pod.Labels[roleLabel]).Should(Equal(roleReplica))
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-1"}, pod)).Should(Succeed())
+		Expect(pod.Labels[roleLabel]).Should(Equal(rolePrimary))
+	})
+
+	It("should create a primary service", func() {
+		reconcileWith()
+		Expect(getService("sample-primary").Spec.Selector).Should(Equal(map[string]string{"app": "sample", roleLabel: rolePrimary}))
+		Expect(getService("sample-read").Spec.Selector).Should(Equal(map[string]string{"app": "sample"}))
+	})
+
+	It("should exclude the primary from the read service", func() {
+		mysql.Spec.Services.Read.ExcludePrimary = true
+		reconcileWith()
+		Expect(getService("sample-read").Spec.Selector).Should(Equal(map[string]string{"app": "sample", roleLabel: roleReplica}))
+	})
+})
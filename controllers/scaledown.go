@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+)
+
+// syncScaleDown 은 Replicas 가 줄어든 경우 제거될 파드에서 프라이머리를 옮기고 복제를 멈춘 뒤 스테이트풀셋을 줄인다.
+// correctStatefulSetDrift 는 레플리카 수를 줄이지 않으므로 준비가 끝날 때까지 제거될 파드는 그대로 동작한다
+func (r *MySQLReconciler) syncScaleDown(mysql *mysqlv1beta1.MySQL) error {
+	sf := &appsv1.StatefulSet{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}, sf); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	desired := mysql.Spec.Replication.Replicas
+	if sf.Spec.Replicas == nil || *sf.Spec.Replicas <= desired {
+		return r.cleanUpScaledDownClaims(mysql, sf)
+	}
+	current := *sf.Spec.Replicas
+
+	// 업그레이드나 요청된 전환이 파드를 옮기는 동안에는 기다린다
+	if mysql.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeUpgrading) || mysql.SwitchoverTarget() != "" {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeScalingDown,
+			Status:  corev1.ConditionTrue,
+			Reason:  "WaitingForPrimaryChange",
+			Message: fmt.Sprintf("Scaling down to %d replicas will start after the upgrade or switchover", desired),
+		})
+	}
+
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	if podOrdinal(primaryPodName(mysql)) >= int(desired) {
+		// 프라이머리를 옮길 때 GTID 로 대상이 따라잡았는지 확인하므로 모든 서버가 GTID 를 사용할 때까지 기다린다
+		if mysql.Status.GTIDMode != mysqlclient.GTIDModeOn {
+			return r.setCondition(mysql, condition.Condition{
+				Type:    mysqlv1beta1.ConditionTypeScalingDown,
+				Status:  corev1.ConditionTrue,
+				Reason:  "WaitingForGTID",
+				Message: fmt.Sprintf("Scaling down to %d replicas will start after all servers use GTID", desired),
+			})
+		}
+		return r.movePrimaryForScaleDown(mysql, pods)
+	}
+
+	rootPassword, err := r.secretValue(mysql, rootPasswordSecretRef(mysql))
+	if err != nil {
+		return err
+	}
+	for i := range pods {
+		pod := &pods[i]
+		// 준비되지 않은 파드는 복제를 받지 못하고 있으므로 그대로 제거한다
+		if podOrdinal(pod.Name) < int(desired) || !isPodReady(pod) || pod.Status.PodIP == "" {
+			continue
+		}
+		if err := r.stopReplication(pod, rootPassword); err != nil {
+			r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "StopReplicationFailed", "Failed to stop replication on %s: %v", pod.Name, err)
+			return r.setCondition(mysql, condition.Condition{
+				Type:    mysqlv1beta1.ConditionTypeScalingDown,
+				Status:  corev1.ConditionTrue,
+				Reason:  "StopReplicationFailed",
+				Message: fmt.Sprintf("Failed to stop replication on %s: %v", pod.Name, err),
+			})
+		}
+	}
+
+	r.Log.Info("Scale down StatefulSet", "from", current, "to", desired)
+	patch := client.MergeFrom(sf.DeepCopy())
+	sf.Spec.Replicas = &desired
+	if err := r.Patch(context.TODO(), sf, patch); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "ScaledDown", "Scaled down from %d to %d replicas", current, desired)
+	return r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeScalingDown,
+		Status:  corev1.ConditionFalse,
+		Reason:  "ScaledDown",
+		Message: fmt.Sprintf("Scaled down from %d to %d replicas", current, desired),
+	})
+}
+
+// movePrimaryForScaleDown 은 남을 레플리카 중 지연이 가장 적은 파드로 프라이머리를 옮긴다. 실패하면 컨디션에 기록하고 다음 조정에서 다시 시도한다
+func (r *MySQLReconciler) movePrimaryForScaleDown(mysql *mysqlv1beta1.MySQL, pods []corev1.Pod) error {
+	oldPrimary := primaryPodName(mysql)
+	target := scaleDownTarget(mysql)
+	var primaryPod, targetPod *corev1.Pod
+	var replicas []*corev1.Pod
+	for i := range pods {
+		switch {
+		case pods[i].Name == oldPrimary:
+			primaryPod = &pods[i]
+		case pods[i].Name == target:
+			targetPod = &pods[i]
+		case isPodReady(&pods[i]):
+			replicas = append(replicas, &pods[i])
+		}
+	}
+	if primaryPod == nil || targetPod == nil || !isPodReady(primaryPod) || !isPodReady(targetPod) {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeScalingDown,
+			Status:  corev1.ConditionTrue,
+			Reason:  "WaitingForReplica",
+			Message: fmt.Sprintf("Waiting for a healthy replica below %s to take over the primary role from %s", podName(mysql, int(mysql.Spec.Replication.Replicas)), oldPrimary),
+		})
+	}
+
+	r.Log.Info("Move primary off a pod being removed", "from", oldPrimary, "to", target)
+	if err := r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeScalingDown,
+		Status:  corev1.ConditionTrue,
+		Reason:  "MovingPrimary",
+		Message: fmt.Sprintf("Moving primary from %s to %s before scaling down", oldPrimary, target),
+	}); err != nil {
+		return err
+	}
+	if err := r.switchover(mysql, primaryPod, targetPod, replicas); err != nil {
+		r.Log.Error(err, "Could not move primary", "from", oldPrimary, "to", target)
+		r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "MovePrimaryFailed", "Failed to move primary from %s to %s: %v", oldPrimary, target, err)
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeScalingDown,
+			Status:  corev1.ConditionTrue,
+			Reason:  "MovePrimaryFailed",
+			Message: fmt.Sprintf("Failed to move primary from %s to %s: %v", oldPrimary, target, err),
+		})
+	}
+
+	mysql.Status.Primary = target
+	if err := r.Status().Update(context.TODO(), mysql); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "PrimaryMoved", "Moved primary from %s to %s before scaling down", oldPrimary, target)
+	return nil
+}
+
+// scaleDownTarget 은 줄인 뒤에도 남는 레플리카 중 복제가 정상이고 지연이 가장 적은 파드를 고른다
+func scaleDownTarget(mysql *mysqlv1beta1.MySQL) string {
+	var best *mysqlv1beta1.InstanceStatus
+	for i := range mysql.Status.Instances {
+		instance := &mysql.Status.Instances[i]
+		if podOrdinal(instance.Name) >= int(mysql.Spec.Replication.Replicas) || instance.Role != roleReplica ||
+			!instance.IOThreadRunning || !instance.SQLThreadRunning || instance.SecondsBehindSource == nil {
+			continue
+		}
+		if best == nil || *instance.SecondsBehindSource < *best.SecondsBehindSource {
+			best = instance
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.Name
+}
+
+// stopReplication 은 제거될 파드가 프라이머리에서 트랜잭션을 받다가 중간에 종료되지 않도록 복제를 멈춘다
+func (r *MySQLReconciler) stopReplication(pod *corev1.Pod, rootPassword string) error {
+	c, err := r.connect(pod, rootPassword)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	r.Log.Info("Stop replication", "pod", pod.Name)
+	return c.StopReplica(context.TODO())
+}
+
+// cleanUpScaledDownClaims 는 ScaleDownPVCPolicy 가 Delete 이면 줄어든 순번의 파드가 사라진 뒤 데이터 볼륨 클레임을 삭제한다
+func (r *MySQLReconciler) cleanUpScaledDownClaims(mysql *mysqlv1beta1.MySQL, sf *appsv1.StatefulSet) error {
+	if mysql.Spec.Storage.ScaleDownPVCPolicy != mysqlv1beta1.ScaleDownPVCPolicyDelete {
+		return nil
+	}
+	replicas := int(mysql.Spec.Replication.Replicas)
+	if sf.Spec.Replicas != nil && int(*sf.Spec.Replicas) > replicas {
+		replicas = int(*sf.Spec.Replicas)
+	}
+	claims, err := r.dataClaims(mysql)
+	if err != nil {
+		return err
+	}
+	for i := range claims {
+		ordinal := podOrdinal(claims[i].Name)
+		if ordinal < replicas {
+			continue
+		}
+		// 파드가 종료되는 중이면 볼륨을 놓은 뒤에 삭제한다
+		err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: podName(mysql, ordinal)}, &corev1.Pod{})
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		r.Log.Info("Delete persistent volume claim of removed pod", "pvc", claims[i].Name)
+		if err := r.Delete(context.TODO(), &claims[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "VolumeDeleted", "Deleted %s of removed pod %s", claims[i].Name, podName(mysql, ordinal))
+	}
+	return nil
+}
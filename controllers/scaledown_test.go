@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql scale down", func() {
+	var (
+		mysql   *v1beta1.MySQL
+		r       *MySQLReconciler
+		servers map[string]*fakeMySQL
+		req     = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	newPod := func(i int) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      fmt.Sprintf("sample-%d", i),
+				Namespace: "default",
+				Labels:    map[string]string{"app": "sample"},
+			},
+			Status: corev1.PodStatus{
+				PodIP:      fmt.Sprintf("10.0.0.%d", i),
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		}
+	}
+
+	newClaim := func(i int) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: v1.ObjectMeta{Name: fmt.Sprintf("data-sample-%d", i), Namespace: "default", Labels: map[string]string{"app": "sample"}},
+		}
+	}
+
+	reconcileMySQL := func() {
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+	}
+
+	// setup 은 세 개의 파드로 동작하는 클러스터를 만든 뒤 레플리카 수를 replicas 로 줄인다
+	setup := func(primary string, replicas int32, policy v1beta1.ScaleDownPVCPolicy, objs ...runtime.Object) {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+				Storage:     v1beta1.StorageSpec{ScaleDownPVCPolicy: policy},
+			},
+			Status: v1beta1.MySQLStatus{Primary: primary},
+		}
+		r = &MySQLReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, append(objs, mysql)...),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme:   s,
+			Recorder: record.NewFakeRecorder(20),
+			MySQLClient: func(host, user, password string) (mysqlclient.Client, error) {
+				return servers[host], nil
+			},
+		}
+		reconcileMySQL()
+
+		mysql.Spec.Replication.Replicas = replicas
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+	}
+
+	statefulSetReplicas := func() int32 {
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		return *sf.Spec.Replicas
+	}
+
+	scalingDownReason := func() string {
+		cond := findCondition(mysql.Status.Conditions, v1beta1.ConditionTypeScalingDown)
+		Expect(cond).ShouldNot(BeNil())
+		return cond.Reason
+	}
+
+	claimExists := func(i int) bool {
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: fmt.Sprintf("data-sample-%d", i)}, &corev1.PersistentVolumeClaim{})
+		if errors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).ShouldNot(HaveOccurred())
+		return true
+	}
+
+	BeforeEach(func() {
+		behind, closest := int64(5), int64(1)
+		servers = map[string]*fakeMySQL{
+			"10.0.0.0": {status: &mysqlclient.ReplicaStatus{SourceHost: "sample-2.sample", IORunning: true, SQLRunning: true, SecondsBehindSource: &behind}},
+			"10.0.0.1": {status: &mysqlclient.ReplicaStatus{SourceHost: "sample-2.sample", IORunning: true, SQLRunning: true, SecondsBehindSource: &closest}},
+			"10.0.0.2": {},
+		}
+	})
+
+	It("should move the primary off the removed pod before scaling down", func() {
+		setup("sample-2", 2, "", newPod(0), newPod(1), newPod(2))
+
+		reconcileMySQL()
+		Expect(scalingDownReason()).Should(Equal("MovingPrimary"))
+		Expect(mysql.Status.Primary).Should(Equal("sample-1"))
+		Expect(servers["10.0.0.1"].promoted).Should(BeTrue())
+		Expect(servers["10.0.0.2"].source).Should(Equal("sample-1.sample"))
+		Expect(statefulSetReplicas()).Should(Equal(int32(3)))
+
+		reconcileMySQL()
+		Expect(servers["10.0.0.2"].stopped).Should(BeTrue())
+		Expect(servers["10.0.0.0"].stopped).Should(BeFalse())
+		Expect(servers["10.0.0.1"].stopped).Should(BeFalse())
+		Expect(statefulSetReplicas()).Should(Equal(int32(2)))
+		Expect(scalingDownReason()).Should(Equal("ScaledDown"))
+	})
+
+	It("should stop replication on the removed pods when the primary stays", func() {
+		servers["10.0.0.0"] = &fakeMySQL{}
+		servers["10.0.0.2"] = &fakeMySQL{status: &mysqlclient.ReplicaStatus{SourceHost: "sample-0.sample", IORunning: true, SQLRunning: true}}
+		setup("sample-0", 1, "", newPod(0), newPod(1), newPod(2))
+
+		reconcileMySQL()
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(servers["10.0.0.1"].stopped).Should(BeTrue())
+		Expect(servers["10.0.0.2"].stopped).Should(BeTrue())
+		Expect(servers["10.0.0.0"].promoted).Should(BeFalse())
+		Expect(statefulSetReplicas()).Should(Equal(int32(1)))
+	})
+
+	It("should wait while no remaining replica can take over", func() {
+		servers["10.0.0.0"].status.IORunning = false
+		servers["10.0.0.1"].status.IORunning = false
+		setup("sample-2", 2, "", newPod(0), newPod(1), newPod(2))
+
+		reconcileMySQL()
+		Expect(scalingDownReason()).Should(Equal("WaitingForReplica"))
+		Expect(mysql.Status.Primary).Should(Equal("sample-2"))
+		Expect(statefulSetReplicas()).Should(Equal(int32(3)))
+	})
+
+	It("should delete the claims of removed pods with the Delete policy", func() {
+		setup("sample-0", 2, v1beta1.ScaleDownPVCPolicyDelete, newPod(0), newPod(1), newClaim(0), newClaim(1), newClaim(2))
+
+		reconcileMySQL()
+		Expect(statefulSetReplicas()).Should(Equal(int32(2)))
+		// 파드가 사라진 것을 다음 조정에서 확인하고 삭제한다
+		Expect(claimExists(2)).Should(BeTrue())
+		reconcileMySQL()
+		Expect(claimExists(0)).Should(BeTrue())
+		Expect(claimExists(1)).Should(BeTrue())
+		Expect(claimExists(2)).Should(BeFalse())
+	})
+
+	It("should keep the claims of removed pods with the Retain policy", func() {
+		setup("sample-0", 2, v1beta1.ScaleDownPVCPolicyRetain, newPod(0), newPod(1), newClaim(0), newClaim(1), newClaim(2))
+
+		reconcileMySQL()
+		reconcileMySQL()
+		Expect(statefulSetReplicas()).Should(Equal(int32(2)))
+		Expect(claimExists(2)).Should(BeTrue())
+	})
+})
@@ -0,0 +1,180 @@
+package controllers
+
+// initMySQLScript 는 파드 순번으로 server-id 를 정하고, GTID 설정과 함께 컨피그맵에 기록된 프라이머리 여부에 따라
+// master.cnf 또는 slave.cnf 를 /mnt/conf.d 에 복사한다
+const initMySQLScript = `set -ex
+# Generate mysql server-id from pod ordinal index.
+[[ $(hostname) =~ -([0-9]+)$ ]] || exit 1
+ordinal=${BASH_REMATCH[1]}
+echo [mysqld] > /mnt/conf.d/server-id.cnf
+# Add an offset to avoid reserved server-id=0 value.
+echo server-id=$((100 + $ordinal)) >> /mnt/conf.d/server-id.cnf
+# Copy appropriate conf.d files from config-map to emptyDir.
+if [[ -f /mnt/config-map/gtid.cnf ]]; then
+  cp /mnt/config-map/gtid.cnf /mnt/conf.d/
+fi
+primary=$(cat /mnt/config-map/primary 2>/dev/null || echo "${HOSTNAME%-*}-0")
+if [[ "$(hostname)" == "$primary" ]]; then
+  cp /mnt/config-map/master.cnf /mnt/conf.d/
+else
+  cp /mnt/config-map/slave.cnf /mnt/conf.d/
+fi
+`
+
+// restoreMySQLScript 는 백업에서 부트스트랩하는 클러스터의 첫 프라이머리에 백업을 복원한다.
+// 시점 복구이면 백업 이후에 보관된 바이너리 로그도 내려받는다
+const restoreMySQLScript = `set -eo pipefail
+primary=$(cat /mnt/config-map/primary 2>/dev/null || echo "${HOSTNAME%-*}-0")
+if [[ "$(hostname)" != "$primary" || -d /var/lib/mysql/mysql ]]; then
+  exit 0
+fi
+
+echo "Restoring $BACKUP_KEY"
+rm -rf /var/lib/mysql/.restore
+mkdir -p /var/lib/mysql/.restore
+/tools/agent restore --key "$BACKUP_KEY" | xbstream -x -C /var/lib/mysql/.restore
+xtrabackup --prepare --target-dir=/var/lib/mysql/.restore
+if [[ -n "$BINLOG_PREFIX" ]]; then
+  echo "Downloading binary logs archived under $BINLOG_PREFIX"
+  rm -rf /var/lib/mysql-pitr/binlogs
+  /tools/agent restore-binlogs --prefix "$BINLOG_PREFIX" --since "$BINLOGS_SINCE" --dir /var/lib/mysql-pitr/binlogs
+fi
+shopt -s dotglob
+mv /var/lib/mysql/.restore/* /var/lib/mysql/
+rmdir /var/lib/mysql/.restore
+`
+
+// cloneSourceScript 는 다른 클러스터에서 부트스트랩하는 클러스터의 첫 프라이머리에 원본 파드의 백업을 받아 복원한다
+const cloneSourceScript = `set -eo pipefail
+primary=$(cat /mnt/config-map/primary 2>/dev/null || echo "${HOSTNAME%-*}-0")
+if [[ "$(hostname)" != "$primary" || -d /var/lib/mysql/mysql ]]; then
+  exit 0
+fi
+
+echo "Cloning from $CLONE_HOST"
+rm -rf /var/lib/mysql/.restore
+mkdir -p /var/lib/mysql/.restore
+ncat --recv-only "$CLONE_HOST" 3307 | xbstream -x -C /var/lib/mysql/.restore
+xtrabackup --prepare --target-dir=/var/lib/mysql/.restore
+shopt -s dotglob
+mv /var/lib/mysql/.restore/* /var/lib/mysql/
+rmdir /var/lib/mysql/.restore
+`
+
+// xtrabackupScript 는 프라이머리의 계정을 준비하거나 레플리카의 GTID 복제를 시작한 뒤,
+// 다른 파드가 복제해 갈 수 있도록 로컬 데이터의 백업을 제공한다
+const xtrabackupScript = `set -e
+export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"
+primary=$(cat /mnt/config-map/primary 2>/dev/null || echo "$POD_NAME-0")
+
+echo "Waiting for mysqld to be ready (accepting connections)"
+until mysqladmin -h 127.0.0.1 -u root ping; do sleep 1; done
+
+# Clusters created before credentials were introduced have an empty root
+# password until the primary sets it and the change is replicated.
+if ! mysql -h 127.0.0.1 -u root -e "SELECT 1" && MYSQL_PWD= mysql -h 127.0.0.1 -u root -e "SELECT 1"; then
+  if [[ "$(hostname)" == "$primary" ]]; then
+    echo "Setting the root password of a cluster created without one"
+    password=$(printf '%s' "$MYSQL_ROOT_PASSWORD" | sed "s/'/''/g")
+    MYSQL_PWD= mysql -h 127.0.0.1 -u root -e "ALTER USER 'root'@'%' IDENTIFIED BY '$password'; ALTER USER 'root'@'localhost' IDENTIFIED BY '$password'" ||
+      MYSQL_PWD= mysql -h 127.0.0.1 -u root -e "SET PASSWORD FOR 'root'@'%' = PASSWORD('$password'); SET PASSWORD FOR 'root'@'localhost' = PASSWORD('$password')"
+  else
+    export MYSQL_PWD=
+  fi
+fi
+
+if [[ "$(hostname)" == "$primary" ]]; then
+  echo "Ensuring the replication user exists"
+  mysql -h 127.0.0.1 -u root -e "CREATE USER '$MYSQL_REPLICATION_USER'@'%' IDENTIFIED BY '$MYSQL_REPLICATION_PASSWORD'" 2>/dev/null || true
+  # Data restored from a backup has the replication user of the backed up cluster.
+  mysql -h 127.0.0.1 -u root -e "ALTER USER '$MYSQL_REPLICATION_USER'@'%' IDENTIFIED BY '$MYSQL_REPLICATION_PASSWORD'" 2>/dev/null ||
+    mysql -h 127.0.0.1 -u root -e "SET PASSWORD FOR '$MYSQL_REPLICATION_USER'@'%' = PASSWORD('$MYSQL_REPLICATION_PASSWORD')"
+  mysql -h 127.0.0.1 -u root -e "GRANT REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO '$MYSQL_REPLICATION_USER'@'%'"
+elif mysql -h 127.0.0.1 -u root -e "SHOW SLAVE STATUS\\G" | grep -q "Master_User: root$"; then
+  echo "Switching replication to the replication user"
+  mysql -h 127.0.0.1 -u root -e "STOP SLAVE IO_THREAD; \
+    CHANGE MASTER TO MASTER_USER='$MYSQL_REPLICATION_USER', MASTER_PASSWORD='$MYSQL_REPLICATION_PASSWORD'; \
+    START SLAVE IO_THREAD;"
+fi
+
+cd /var/lib/mysql
+# Determine binlog position of cloned data, if any.
+gtid=
+if [[ -f xtrabackup_binlog_info ]]; then
+  gtid=$(tr -d '\n' < xtrabackup_binlog_info | awk '{print $3}')
+fi
+if [[ -n "$gtid" && "$(hostname)" == "$primary" ]]; then
+  # The primary was restored from a backup or cloned from another cluster.
+  # Record the transactions of its data so that replicas and later backups
+  # continue its GTID history.
+  echo "Setting the GTID history of the restored backup"
+  mysql -h 127.0.0.1 -u root -e "RESET MASTER; SET GLOBAL gtid_purged='$gtid'" || exit 1
+  rm -f xtrabackup_binlog_info xtrabackup_slave_info
+elif [[ -n "$gtid" ]]; then
+  # The clone contains every transaction in its GTID set, whether it was
+  # taken from the primary or from a replica with log-slave-updates.
+  rm -f xtrabackup_binlog_info xtrabackup_slave_info
+  echo "RESET MASTER; SET GLOBAL gtid_purged='$gtid'; \
+        CHANGE MASTER TO MASTER_AUTO_POSITION=1" > change_master_to.sql.in
+elif [[ -f xtrabackup_slave_info && "x$(<xtrabackup_slave_info)" != "x" ]]; then
+  # XtraBackup already generated a partial "CHANGE MASTER TO" query
+  # because we're cloning from an existing replica.
+  sed -E 's/;$//g' xtrabackup_slave_info > change_master_to.sql.in
+  rm -f xtrabackup_slave_info xtrabackup_binlog_info
+elif [[ -f xtrabackup_binlog_info ]]; then
+  # We're cloning directly from the primary. Parse binlog position.
+  [[ $(cat xtrabackup_binlog_info) =~ ^(.*?)[[:space:]]+(.*?)$ ]] || exit 1
+  rm -f xtrabackup_binlog_info xtrabackup_slave_info
+  echo "CHANGE MASTER TO MASTER_LOG_FILE='${BASH_REMATCH[1]}',\
+        MASTER_LOG_POS=${BASH_REMATCH[2]}" > change_master_to.sql.in
+fi
+
+# Replay the archived binary logs of a point-in-time recovery. Transactions
+# that are already in the backup are skipped by their GTIDs, so the replay can
+# be repeated if the container restarts. mysqld is not ready until it is done.
+if [[ -d /var/lib/mysql-pitr/binlogs && "$(hostname)" == "$primary" ]]; then
+  echo "Replaying archived binary logs"
+  args=()
+  if [[ -n "$PITR_STOP_DATETIME" ]]; then
+    args+=("--stop-datetime=$PITR_STOP_DATETIME")
+  fi
+  if [[ -n "$PITR_INCLUDE_GTIDS" ]]; then
+    args+=("--include-gtids=$PITR_INCLUDE_GTIDS")
+  fi
+  binlogs=(/var/lib/mysql-pitr/binlogs/*)
+  if [[ -e "${binlogs[0]}" ]]; then
+    (set -o pipefail; TZ=UTC mysqlbinlog "${args[@]}" "${binlogs[@]}" | mysql -h 127.0.0.1 -u root) || exit 1
+  fi
+  rm -rf /var/lib/mysql-pitr/binlogs
+fi
+
+# Check if we need to complete a clone by starting replication.
+if [[ -f change_master_to.sql.in ]]; then
+  echo "Initializing replication from clone position"
+  mysql -h 127.0.0.1 -u root \
+        -e "$(<change_master_to.sql.in), \
+                MASTER_HOST='$primary.$SVC_NAME', \
+                MASTER_USER='$MYSQL_REPLICATION_USER', \
+                MASTER_PASSWORD='$MYSQL_REPLICATION_PASSWORD', \
+                MASTER_CONNECT_RETRY=10; \
+              START SLAVE;" || exit 1
+  # In case of container restart, attempt this at-most-once.
+  mv change_master_to.sql.in change_master_to.sql.orig
+elif [[ "$(hostname)" != "$primary" ]] &&
+  ! mysql -h 127.0.0.1 -u root -e "SHOW SLAVE STATUS\\G" | grep -q "Master_Host: $primary.$SVC_NAME$"; then
+  # The primary changed while this pod was away, e.g. it was the primary
+  # before a failover. Follow the new primary from its own GTID position.
+  echo "Following the new primary $primary"
+  mysql -h 127.0.0.1 -u root -e "SET GLOBAL super_read_only = ON; STOP SLAVE; \
+    CHANGE MASTER TO MASTER_HOST='$primary.$SVC_NAME', \
+                     MASTER_USER='$MYSQL_REPLICATION_USER', \
+                     MASTER_PASSWORD='$MYSQL_REPLICATION_PASSWORD', \
+                     MASTER_AUTO_POSITION=1, \
+                     MASTER_CONNECT_RETRY=10; \
+    START SLAVE;" || exit 1
+fi
+
+# Start a server to send backups when requested by peers.
+exec ncat --listen --keep-open --send-only --max-conns=1 3307 -c \
+  "xtrabackup --backup --slave-info --stream=xbstream --host=127.0.0.1 --user=root"
+`
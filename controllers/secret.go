@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+)
+
+const (
+	// replicationUser 는 레플리카가 프라이머리에 접속할 때 사용하는 사용자 이름이다
+	replicationUser = "repl"
+	// passwordLength 는 오퍼레이터가 생성하는 비밀번호의 길이이다
+	passwordLength = 24
+	// passwordChars 는 셸 스크립트와 SQL 에서 이스케이프할 필요가 없는 문자만 사용한다
+	passwordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// rootSecretName 은 RootPasswordSecretRef 가 없을 때 오퍼레이터가 생성하는 root 비밀번호 시크릿의 이름을 반환한다
+func rootSecretName(mysql *mysqlv1beta1.MySQL) string {
+	return mysql.Name + "-root"
+}
+
+// replicationSecretName 은 복제 계정 시크릿의 이름을 반환한다
+func replicationSecretName(mysql *mysqlv1beta1.MySQL) string {
+	return mysql.Name + "-replication"
+}
+
+// rootPasswordSecretRef 는 root 비밀번호가 저장된 시크릿의 키를 반환한다
+func rootPasswordSecretRef(mysql *mysqlv1beta1.MySQL) *corev1.SecretKeySelector {
+	if mysql.Spec.Server.RootPasswordSecretRef != nil {
+		return mysql.Spec.Server.RootPasswordSecretRef
+	}
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: rootSecretName(mysql)},
+		Key:                  "password",
+	}
+}
+
+// credentialEnv 는 컨테이너에 주입할 root 와 복제 계정의 환경 변수를 반환한다
+func credentialEnv(mysql *mysqlv1beta1.MySQL) []corev1.EnvVar {
+	replication := corev1.LocalObjectReference{Name: replicationSecretName(mysql)}
+	return []corev1.EnvVar{
+		{
+			Name:      "MYSQL_ROOT_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: rootPasswordSecretRef(mysql)},
+		},
+		{
+			Name: "MYSQL_REPLICATION_USER",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: replication,
+				Key:                  "username",
+			}},
+		},
+		{
+			Name: "MYSQL_REPLICATION_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: replication,
+				Key:                  "password",
+			}},
+		},
+	}
+}
+
+// syncSecrets 는 root 비밀번호 시크릿과 복제 계정 시크릿이 없으면 생성한다
+func (r *MySQLReconciler) syncSecrets(mysql *mysqlv1beta1.MySQL) error {
+	if ref := mysql.Spec.Server.RootPasswordSecretRef; ref != nil {
+		// 사용자가 지정한 시크릿은 생성하지 않고 존재하는지만 확인한다
+		secret := &corev1.Secret{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: ref.Name}, secret); err != nil {
+			return err
+		}
+		if _, ok := secret.Data[ref.Key]; !ok {
+			return fmt.Errorf("secret %s does not have key %s", ref.Name, ref.Key)
+		}
+	} else if err := r.syncSecret(mysql, rootSecretName(mysql), func() (map[string][]byte, error) {
+		password, err := generatePassword()
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"password": []byte(password)}, nil
+	}); err != nil {
+		return err
+	}
+	return r.syncSecret(mysql, replicationSecretName(mysql), func() (map[string][]byte, error) {
+		password, err := generatePassword()
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"username": []byte(replicationUser), "password": []byte(password)}, nil
+	})
+}
+
+// syncSecret 은 시크릿이 없으면 생성한다. 이미 있는 시크릿의 비밀번호는 바꾸지 않는다
+func (r *MySQLReconciler) syncSecret(mysql *mysqlv1beta1.MySQL, name string, data func() (map[string][]byte, error)) error {
+	secret := &corev1.Secret{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: name}, secret)
+	if err == nil || !errors.IsNotFound(err) {
+		return err
+	}
+	r.Log.Info("Could not find secret. Create a new one", "secret", name)
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: mysql.Namespace,
+			Name:      name,
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+	if secret.Data, err = data(); err != nil {
+		return err
+	}
+	if err := controllerutil.SetControllerReference(mysql, secret, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(context.TODO(), secret); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func generatePassword() (string, error) {
+	b := make([]byte, passwordLength)
+	max := big.NewInt(int64(len(passwordChars)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordChars[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// secretValue 는 시크릿 키에 저장된 값을 읽는다
+func (r *MySQLReconciler) secretValue(mysql *mysqlv1beta1.MySQL, ref *corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: ref.Name}, secret); err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s does not have key %s", ref.Name, ref.Key)
+	}
+	return string(value), nil
+}
+
+// replicationCredentials 는 복제 계정의 사용자 이름과 비밀번호를 읽는다
+func (r *MySQLReconciler) replicationCredentials(mysql *mysqlv1beta1.MySQL) (string, string, error) {
+	ref := corev1.LocalObjectReference{Name: replicationSecretName(mysql)}
+	user, err := r.secretValue(mysql, &corev1.SecretKeySelector{LocalObjectReference: ref, Key: "username"})
+	if err != nil {
+		return "", "", err
+	}
+	password, err := r.secretValue(mysql, &corev1.SecretKeySelector{LocalObjectReference: ref, Key: "password"})
+	if err != nil {
+		return "", "", err
+	}
+	return user, password, nil
+}
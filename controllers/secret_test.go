@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql credentials", func() {
+	var (
+		mysql *v1beta1.MySQL
+		r     *MySQLReconciler
+		req   = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	newReconciler := func(objs ...runtime.Object) *MySQLReconciler {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		return &MySQLReconciler{
+			Client: fake.NewFakeClientWithScheme(s, objs...),
+			Log:    ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme: s,
+		}
+	}
+
+	getSecret := func(name string) *corev1.Secret {
+		secret := &corev1.Secret{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, secret)).Should(Succeed())
+		return secret
+	}
+
+	findEnv := func(container corev1.Container, name string) *corev1.EnvVar {
+		for i := range container.Env {
+			if container.Env[i].Name == name {
+				return &container.Env[i]
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+			},
+		}
+	})
+
+	It("should generate the root and replication secrets", func() {
+		r = newReconciler(mysql)
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		root := getSecret("sample-root")
+		Expect(root.Data["password"]).Should(HaveLen(passwordLength))
+		Expect(root.OwnerReferences).Should(HaveLen(1))
+		replication := getSecret("sample-replication")
+		Expect(string(replication.Data["username"])).Should(Equal(replicationUser))
+		Expect(replication.Data["password"]).Should(HaveLen(passwordLength))
+		Expect(replication.OwnerReferences).Should(HaveLen(1))
+	})
+
+	It("should keep generated passwords", func() {
+		r = newReconciler(mysql)
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		password := getSecret("sample-root").Data["password"]
+
+		_, err = r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(getSecret("sample-root").Data["password"]).Should(Equal(password))
+	})
+
+	It("should inject credentials into the containers", func() {
+		r = newReconciler(mysql)
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		containers := append(sf.Spec.Template.Spec.Containers, sf.Spec.Template.Spec.InitContainers[1])
+		for _, container := range containers {
+			Expect(findEnv(container, "MYSQL_ALLOW_EMPTY_PASSWORD")).Should(BeNil())
+			env := findEnv(container, "MYSQL_ROOT_PASSWORD")
+			Expect(env).ShouldNot(BeNil(), container.Name)
+			Expect(env.ValueFrom.SecretKeyRef.Name).Should(Equal("sample-root"))
+			Expect(findEnv(container, "MYSQL_REPLICATION_PASSWORD")).ShouldNot(BeNil(), container.Name)
+		}
+	})
+
+	It("should use the root password secret of the spec", func() {
+		mysql.Spec.Server.RootPasswordSecretRef = &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "my-root"},
+			Key:                  "root-password",
+		}
+		r = newReconciler(mysql, &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: "my-root", Namespace: "default"},
+			Data:       map[string][]byte{"root-password": []byte("secret")},
+		})
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-root"}, &corev1.Secret{})).ShouldNot(Succeed())
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		env := findEnv(sf.Spec.Template.Spec.Containers[0], "MYSQL_ROOT_PASSWORD")
+		Expect(env.ValueFrom.SecretKeyRef.Name).Should(Equal("my-root"))
+		Expect(env.ValueFrom.SecretKeyRef.Key).Should(Equal("root-password"))
+	})
+
+	It("should fail when the root password secret of the spec does not exist", func() {
+		mysql.Spec.Server.RootPasswordSecretRef = &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "my-root"},
+			Key:                  "root-password",
+		}
+		r = newReconciler(mysql)
+		_, err := r.Reconcile(req)
+		Expect(err).Should(HaveOccurred())
+	})
+})
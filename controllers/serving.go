@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+)
+
+const (
+	// servingLabel 은 파드가 읽기 요청을 받을 수 있는지를 나타내는 레이블이다. MaxLagSeconds 가 설정되면 읽기 전용 서비스가 이 레이블로 파드를 선택한다
+	servingLabel = "mysql.sample.com/serving"
+	servingTrue  = "true"
+	servingFalse = "false"
+)
+
+// syncServing 은 Status.Instances 에 기록된 복제 지연 시간으로 각 파드의 serving 레이블을 정한다.
+// MaxLagSeconds 가 없으면 모든 파드에 true 를 기록하여, 나중에 설정하더라도 레이블이 붙기 전에 서비스가 비지 않게 한다
+func (r *MySQLReconciler) syncServing(mysql *mysqlv1beta1.MySQL) error {
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	for i := range pods {
+		serving := servingTrue
+		if !isServing(mysql, pods[i].Name) {
+			serving = servingFalse
+		}
+		if err := r.setPodLabel(&pods[i], servingLabel, serving); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isServing 은 파드가 읽기 요청을 받을 수 있는지 확인한다. 프라이머리는 항상 최신이고,
+// 레플리카는 두 복제 스레드가 동작하고 지연 시간이 MaxLagSeconds 이하인 경우에만 받을 수 있다
+func isServing(mysql *mysqlv1beta1.MySQL, name string) bool {
+	maxLag := mysql.Spec.Services.Read.MaxLagSeconds
+	if maxLag == nil || name == mysql.Status.Primary {
+		return true
+	}
+	for _, instance := range mysql.Status.Instances {
+		if instance.Name != name {
+			continue
+		}
+		return instance.Role == roleReplica && instance.IOThreadRunning && instance.SQLThreadRunning &&
+			instance.SecondsBehindSource != nil && *instance.SecondsBehindSource <= *maxLag
+	}
+	return false
+}
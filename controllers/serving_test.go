@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql read serving", func() {
+	var (
+		mysql   *v1beta1.MySQL
+		r       *MySQLReconciler
+		servers map[string]*fakeMySQL
+		req     = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	replica := func(lag int64) *fakeMySQL {
+		return &fakeMySQL{readOnly: true, status: &mysqlclient.ReplicaStatus{
+			SourceHost:          "sample-0.sample",
+			IORunning:           true,
+			SQLRunning:          true,
+			SecondsBehindSource: &lag,
+		}}
+	}
+
+	reconcileMySQL := func() {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		objs := []runtime.Object{mysql}
+		for i := 0; i < 3; i++ {
+			objs = append(objs, &corev1.Pod{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      fmt.Sprintf("sample-%d", i),
+					Namespace: "default",
+					Labels:    map[string]string{"app": "sample"},
+				},
+				Status: corev1.PodStatus{
+					PodIP:      fmt.Sprintf("10.0.0.%d", i),
+					Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+				},
+			})
+		}
+		r = &MySQLReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, objs...),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme:   s,
+			Recorder: record.NewFakeRecorder(10),
+			MySQLClient: func(host, user, password string) (mysqlclient.Client, error) {
+				return servers[host], nil
+			},
+		}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	serving := func(name string) string {
+		pod := &corev1.Pod{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, pod)).Should(Succeed())
+		return pod.Labels[servingLabel]
+	}
+
+	BeforeEach(func() {
+		maxLag := int64(10)
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+				Services:    v1beta1.ServicesSpec{Read: v1beta1.ReadServiceSpec{MaxLagSeconds: &maxLag}},
+			},
+			Status: v1beta1.MySQLStatus{
+				Primary: "sample-0",
+			},
+		}
+		servers = map[string]*fakeMySQL{
+			"10.0.0.0": {},
+			"10.0.0.1": replica(2),
+			"10.0.0.2": replica(60),
+		}
+	})
+
+	It("should select serving pods in the read service", func() {
+		reconcileMySQL()
+		svc := &corev1.Service{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-read"}, svc)).Should(Succeed())
+		Expect(svc.Spec.Selector).Should(Equal(map[string]string{"app": "sample", servingLabel: servingTrue}))
+	})
+
+	It("should stop serving reads from lagging replicas", func() {
+		reconcileMySQL()
+		Expect(serving("sample-0")).Should(Equal(servingTrue))
+		Expect(serving("sample-1")).Should(Equal(servingTrue))
+		Expect(serving("sample-2")).Should(Equal(servingFalse))
+	})
+
+	It("should stop serving reads from broken replicas", func() {
+		servers["10.0.0.1"].status.SQLRunning = false
+		servers["10.0.0.1"].status.SecondsBehindSource = nil
+		reconcileMySQL()
+		Expect(serving("sample-1")).Should(Equal(servingFalse))
+	})
+
+	It("should serve reads again once the replica catches up", func() {
+		reconcileMySQL()
+		Expect(serving("sample-2")).Should(Equal(servingFalse))
+
+		lag := int64(5)
+		servers["10.0.0.2"].status.SecondsBehindSource = &lag
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(serving("sample-2")).Should(Equal(servingTrue))
+	})
+
+	It("should serve reads from every pod without a threshold", func() {
+		mysql.Spec.Services.Read.MaxLagSeconds = nil
+		reconcileMySQL()
+		Expect(serving("sample-2")).Should(Equal(servingTrue))
+		svc := &corev1.Service{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-read"}, svc)).Should(Succeed())
+		Expect(svc.Spec.Selector).Should(Equal(map[string]string{"app": "sample"}))
+	})
+})
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+)
+
+// dataClaimName 은 순번에 해당하는 파드가 사용하는 데이터 볼륨 클레임의 이름을 반환한다
+func dataClaimName(mysql *mysqlv1beta1.MySQL, ordinal int) string {
+	return fmt.Sprintf("data-%s-%d", mysql.Name, ordinal)
+}
+
+// syncStorage 는 Storage.Size 가 늘어난 경우 스테이트풀셋 대신 각 파드의 볼륨 클레임을 직접 확장한다.
+// 볼륨 클레임 템플릿은 변경할 수 없으므로 새로 생성되는 볼륨도 이 과정을 거쳐 확장된다
+func (r *MySQLReconciler) syncStorage(mysql *mysqlv1beta1.MySQL) error {
+	desired := mysql.Spec.StorageSize()
+	var capacity *resource.Quantity
+	pending := 0
+	for i := 0; i < int(mysql.Spec.Replication.Replicas); i++ {
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: dataClaimName(mysql, i)}, pvc); err != nil {
+			if errors.IsNotFound(err) {
+				// 아직 파드가 생성되지 않았다
+				continue
+			}
+			return err
+		}
+		if c, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok && (capacity == nil || c.Cmp(*capacity) < 0) {
+			c := c.DeepCopy()
+			capacity = &c
+		}
+
+		requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if requested.Cmp(desired) < 0 {
+			expandable, err := r.isExpandable(pvc)
+			if err != nil {
+				return err
+			}
+			if !expandable {
+				return r.setCondition(mysql, condition.Condition{
+					Type:    mysqlv1beta1.ConditionTypeStorageResizing,
+					Status:  corev1.ConditionFalse,
+					Reason:  "ExpansionNotSupported",
+					Message: fmt.Sprintf("Storage class of %s does not allow volume expansion", pvc.Name),
+				})
+			}
+			r.Log.Info("Expand persistent volume claim", "pvc", pvc.Name, "from", requested.String(), "to", desired.String())
+			patch := client.MergeFrom(pvc.DeepCopy())
+			if pvc.Spec.Resources.Requests == nil {
+				pvc.Spec.Resources.Requests = corev1.ResourceList{}
+			}
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = desired
+			if err := r.Patch(context.TODO(), pvc, patch); err != nil {
+				return err
+			}
+		}
+		if c, ok := pvc.Status.Capacity[corev1.ResourceStorage]; !ok || c.Cmp(desired) < 0 {
+			pending++
+		}
+	}
+
+	if err := r.updateStorageCapacity(mysql, capacity); err != nil {
+		return err
+	}
+	if pending > 0 {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeStorageResizing,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Resizing",
+			Message: fmt.Sprintf("Waiting for %d volume(s) to be expanded to %s", pending, desired.String()),
+		})
+	}
+	// 확장이 진행 중이었던 경우에만 완료를 기록한다
+	if cond := findCondition(mysql.Status.Conditions, mysqlv1beta1.ConditionTypeStorageResizing); cond == nil || cond.Reason == "ResizeCompleted" {
+		return nil
+	}
+	return r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeStorageResizing,
+		Status:  corev1.ConditionFalse,
+		Reason:  "ResizeCompleted",
+		Message: fmt.Sprintf("All volumes are expanded to %s", desired.String()),
+	})
+}
+
+// isExpandable 는 볼륨 클레임의 스토리지 클래스가 볼륨 확장을 허용하는지 확인한다
+func (r *MySQLReconciler) isExpandable(pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false, nil
+	}
+	sc := &storagev1.StorageClass{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: *pvc.Spec.StorageClassName}, sc); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
+}
+
+func (r *MySQLReconciler) updateStorageCapacity(mysql *mysqlv1beta1.MySQL, capacity *resource.Quantity) error {
+	if capacity == nil {
+		return nil
+	}
+	if mysql.Status.StorageCapacity != nil && mysql.Status.StorageCapacity.Cmp(*capacity) == 0 {
+		return nil
+	}
+	r.Log.Info("Update storage capacity", "capacity", capacity.String())
+	mysql.Status.StorageCapacity = capacity
+	return r.Status().Update(context.TODO(), mysql)
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql storage", func() {
+	var (
+		r   *MySQLReconciler
+		req reconcile.Request
+	)
+
+	newClaim := func(name, class string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: &class,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("2Gi")},
+				},
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("2Gi")},
+			},
+		}
+	}
+
+	setup := func(class string) {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		size := resource.MustParse("5Gi")
+		allow, deny := true, false
+		mysql := &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+				Storage:     v1beta1.StorageSpec{Size: &size, StorageClassName: &class},
+			},
+		}
+		objs := []runtime.Object{
+			mysql,
+			newClaim("data-sample-0", class),
+			newClaim("data-sample-1", class),
+			&storagev1.StorageClass{ObjectMeta: v1.ObjectMeta{Name: "expandable"}, AllowVolumeExpansion: &allow},
+			&storagev1.StorageClass{ObjectMeta: v1.ObjectMeta{Name: "fixed"}, AllowVolumeExpansion: &deny},
+		}
+		r = &MySQLReconciler{
+			Client: fake.NewFakeClientWithScheme(s, objs...),
+			Log:    ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme: s,
+		}
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	// storageRequest 는 클레임이 요청한 용량이다
+	storageRequest := func(spec corev1.PersistentVolumeClaimSpec) string {
+		size := spec.Resources.Requests[corev1.ResourceStorage]
+		return size.String()
+	}
+
+	getMySQL := func() *v1beta1.MySQL {
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		return mysql
+	}
+
+	It("should create claims from the storage spec", func() {
+		setup("expandable")
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		claim := sf.Spec.VolumeClaimTemplates[0]
+		Expect(*claim.Spec.StorageClassName).Should(Equal("expandable"))
+		Expect(claim.Spec.AccessModes).Should(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}))
+		Expect(storageRequest(claim.Spec)).Should(Equal("5Gi"))
+	})
+
+	It("should expand claims when the storage class allows it", func() {
+		setup("expandable")
+		for _, name := range []string{"data-sample-0", "data-sample-1"} {
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, pvc)).Should(Succeed())
+			Expect(storageRequest(pvc.Spec)).Should(Equal("5Gi"))
+		}
+		mysql := getMySQL()
+		Expect(mysql.Status.Conditions.IsTrueFor(v1beta1.ConditionTypeStorageResizing)).Should(BeTrue())
+		Expect(mysql.Status.StorageCapacity.String()).Should(Equal("2Gi"))
+	})
+
+	It("should report completion after the volumes are expanded", func() {
+		setup("expandable")
+		for _, name := range []string{"data-sample-0", "data-sample-1"} {
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, pvc)).Should(Succeed())
+			pvc.Status.Capacity[corev1.ResourceStorage] = resource.MustParse("5Gi")
+			Expect(r.Client.Update(context.TODO(), pvc)).Should(Succeed())
+		}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		mysql := getMySQL()
+		Expect(mysql.Status.Conditions.IsFalseFor(v1beta1.ConditionTypeStorageResizing)).Should(BeTrue())
+		Expect(mysql.Status.StorageCapacity.String()).Should(Equal("5Gi"))
+	})
+
+	It("should not expand claims when the storage class does not allow it", func() {
+		setup("fixed")
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "data-sample-0"}, pvc)).Should(Succeed())
+		Expect(storageRequest(pvc.Spec)).Should(Equal("2Gi"))
+		Expect(getMySQL().Status.Conditions.IsFalseFor(v1beta1.ConditionTypeStorageResizing)).Should(BeTrue())
+	})
+})
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+)
+
+// switchoverCatchUpTimeout 은 전환 대상이 기존 프라이머리의 트랜잭션을 모두 적용할 때까지 기다리는 시간이다.
+// 그동안 기존 프라이머리는 쓰기를 받지 않는다
+const switchoverCatchUpTimeout = 30 * time.Second
+
+// syncSwitchover 는 switchover-to 어노테이션으로 요청된 파드로 프라이머리를 옮긴다. 기존 프라이머리의 쓰기를 막고
+// 대상이 따라잡으면 승격한 뒤 나머지 파드가 대상을 복제하도록 한다. 결과는 Switchover 컨디션에 기록하고 요청을 제거한다
+func (r *MySQLReconciler) syncSwitchover(mysql *mysqlv1beta1.MySQL) error {
+	target := mysql.SwitchoverTarget()
+	if target == "" {
+		return nil
+	}
+	oldPrimary := primaryPodName(mysql)
+	if target == oldPrimary {
+		// 이미 전환을 마쳤거나 현재 프라이머리로 요청한 경우 요청만 정리한다
+		return r.finishSwitchover(mysql, "SwitchoverCompleted", fmt.Sprintf("%s is the primary", target))
+	}
+	if mysql.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeUpgrading) {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeSwitchover,
+			Status:  corev1.ConditionTrue,
+			Reason:  "WaitingForUpgrade",
+			Message: fmt.Sprintf("Switchover to %s will start after the upgrade", target),
+		})
+	}
+	if mysql.Status.GTIDMode != mysqlclient.GTIDModeOn {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeSwitchover,
+			Status:  corev1.ConditionTrue,
+			Reason:  "WaitingForGTID",
+			Message: fmt.Sprintf("Switchover to %s will start after all servers use GTID", target),
+		})
+	}
+
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	var primaryPod, targetPod *corev1.Pod
+	var replicas []*corev1.Pod
+	for i := range pods {
+		switch {
+		case pods[i].Name == oldPrimary:
+			primaryPod = &pods[i]
+		case pods[i].Name == target:
+			targetPod = &pods[i]
+		case isPodReady(&pods[i]):
+			replicas = append(replicas, &pods[i])
+		}
+	}
+	if primaryPod == nil || targetPod == nil || !isPodReady(primaryPod) || !isPodReady(targetPod) {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeSwitchover,
+			Status:  corev1.ConditionTrue,
+			Reason:  "WaitingForPods",
+			Message: fmt.Sprintf("Waiting for %s and %s to be ready", oldPrimary, target),
+		})
+	}
+
+	r.Log.Info("Start switchover", "from", oldPrimary, "to", target)
+	if err := r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeSwitchover,
+		Status:  corev1.ConditionTrue,
+		Reason:  "SwitchingOver",
+		Message: fmt.Sprintf("Switching primary from %s to %s", oldPrimary, target),
+	}); err != nil {
+		return err
+	}
+	if err := r.switchover(mysql, primaryPod, targetPod, replicas); err != nil {
+		// 실패한 요청을 다시 시도하면 그때마다 쓰기가 멈추므로 결과를 기록하고 요청을 제거한다
+		r.Log.Error(err, "Switchover failed", "from", oldPrimary, "to", target)
+		r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "SwitchoverFailed", "Failed to switch primary from %s to %s: %v", oldPrimary, target, err)
+		return r.finishSwitchover(mysql, "SwitchoverFailed", fmt.Sprintf("Failed to switch primary from %s to %s: %v", oldPrimary, target, err))
+	}
+
+	r.Log.Info("Switchover completed", "from", oldPrimary, "to", target)
+	mysql.Status.Primary = target
+	if err := r.Status().Update(context.TODO(), mysql); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "SwitchoverCompleted", "Switched primary from %s to %s", oldPrimary, target)
+	return r.finishSwitchover(mysql, "SwitchoverCompleted", fmt.Sprintf("Switched primary from %s to %s", oldPrimary, target))
+}
+
+// switchover 는 기존 프라이머리를 읽기 전용으로 바꾸고 대상이 모든 트랜잭션을 적용하면 대상을 승격한다.
+// 대상이 따라잡지 못하면 기존 프라이머리에 다시 쓰기를 허용한다
+func (r *MySQLReconciler) switchover(mysql *mysqlv1beta1.MySQL, primaryPod, targetPod *corev1.Pod, replicas []*corev1.Pod) error {
+	rootPassword, err := r.secretValue(mysql, rootPasswordSecretRef(mysql))
+	if err != nil {
+		return err
+	}
+	user, password, err := r.replicationCredentials(mysql)
+	if err != nil {
+		return err
+	}
+	primary, err := r.connect(primaryPod, rootPassword)
+	if err != nil {
+		return err
+	}
+	defer primary.Close()
+	target, err := r.connect(targetPod, rootPassword)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	ctx := context.TODO()
+	if err := primary.SetReadOnly(ctx); err != nil {
+		return err
+	}
+	gtidSet, err := primary.ExecutedGTIDSet(ctx)
+	if err == nil {
+		err = target.WaitForExecutedGTIDSet(ctx, gtidSet, switchoverCatchUpTimeout)
+	}
+	if err == nil {
+		err = target.Promote(ctx)
+	}
+	if err != nil {
+		if rollbackErr := primary.Promote(ctx); rollbackErr != nil {
+			r.Log.Error(rollbackErr, "Could not make the primary writable again", "pod", primaryPod.Name)
+		}
+		return err
+	}
+
+	r.repoint(ctx, mysql, primaryPod.Name, primary, targetPod.Name, user, password)
+	for _, pod := range replicas {
+		c, err := r.connect(pod, rootPassword)
+		if err != nil {
+			r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "RepointFailed", "Failed to repoint %s to %s: %v", pod.Name, targetPod.Name, err)
+			continue
+		}
+		r.repoint(ctx, mysql, pod.Name, c, targetPod.Name, user, password)
+		c.Close()
+	}
+	return nil
+}
+
+// finishSwitchover 는 전환 결과를 Switchover 컨디션에 기록하고 switchover-to 어노테이션을 제거한다
+func (r *MySQLReconciler) finishSwitchover(mysql *mysqlv1beta1.MySQL, reason, message string) error {
+	if err := r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeSwitchover,
+		Status:  corev1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(mysql.DeepCopy())
+	delete(mysql.Annotations, mysqlv1beta1.SwitchoverAnnotation)
+	return r.Patch(context.TODO(), mysql, patch)
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	v12 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql switchover", func() {
+	var (
+		mysql   *v1beta1.MySQL
+		r       *MySQLReconciler
+		servers map[string]*fakeMySQL
+		ready   map[string]bool
+		req     = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	reconcileMySQL := func() {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		objs := []runtime.Object{mysql}
+		for i := 0; i < 3; i++ {
+			name := fmt.Sprintf("sample-%d", i)
+			status := corev1.ConditionTrue
+			if !ready[name] {
+				status = corev1.ConditionFalse
+			}
+			objs = append(objs, &corev1.Pod{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+					Labels:    map[string]string{"app": "sample"},
+				},
+				Status: corev1.PodStatus{
+					PodIP:      fmt.Sprintf("10.0.0.%d", i),
+					Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+				},
+			})
+		}
+		r = &MySQLReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, objs...),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme:   s,
+			Recorder: record.NewFakeRecorder(10),
+			MySQLClient: func(host, user, password string) (mysqlclient.Client, error) {
+				return servers[host], nil
+			},
+		}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		// 가짜 클라이언트는 읽은 값을 기존 오브젝트에 덮어쓰므로 지워진 어노테이션이 남지 않도록 새 오브젝트로 읽는다
+		mysql = &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+	}
+
+	switchoverCondition := func() (corev1.ConditionStatus, string) {
+		cond := findCondition(mysql.Status.Conditions, v1beta1.ConditionTypeSwitchover)
+		Expect(cond).ShouldNot(BeNil())
+		return cond.Status, cond.Reason
+	}
+
+	BeforeEach(func() {
+		mysql = &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "sample",
+				Namespace:   "default",
+				Annotations: map[string]string{v1beta1.SwitchoverAnnotation: "sample-2"},
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+			},
+			Status: v1beta1.MySQLStatus{
+				Primary: "sample-0",
+			},
+		}
+		servers = map[string]*fakeMySQL{
+			"10.0.0.0": {},
+			"10.0.0.1": {status: &mysqlclient.ReplicaStatus{SourceHost: "sample-0.sample"}},
+			"10.0.0.2": {status: &mysqlclient.ReplicaStatus{SourceHost: "sample-0.sample"}},
+		}
+		ready = map[string]bool{"sample-0": true, "sample-1": true, "sample-2": true}
+	})
+
+	It("should move the primary to the requested pod", func() {
+		reconcileMySQL()
+
+		Expect(servers["10.0.0.2"].promoted).Should(BeTrue())
+		Expect(servers["10.0.0.0"].readOnly).Should(BeTrue())
+		Expect(servers["10.0.0.0"].source).Should(Equal("sample-2.sample"))
+		Expect(servers["10.0.0.1"].source).Should(Equal("sample-2.sample"))
+
+		Expect(mysql.Status.Primary).Should(Equal("sample-2"))
+		Expect(mysql.Annotations).ShouldNot(HaveKey(v1beta1.SwitchoverAnnotation))
+		status, reason := switchoverCondition()
+		Expect(status).Should(Equal(corev1.ConditionFalse))
+		Expect(reason).Should(Equal("SwitchoverCompleted"))
+
+		pod := &corev1.Pod{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-2"}, pod)).Should(Succeed())
+		Expect(pod.Labels[roleLabel]).Should(Equal(rolePrimary))
+	})
+
+	It("should do nothing when the target is already the primary", func() {
+		mysql.Annotations[v1beta1.SwitchoverAnnotation] = "sample-0"
+		reconcileMySQL()
+
+		Expect(servers["10.0.0.0"].readOnly).Should(BeFalse())
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(mysql.Annotations).ShouldNot(HaveKey(v1beta1.SwitchoverAnnotation))
+		_, reason := switchoverCondition()
+		Expect(reason).Should(Equal("SwitchoverCompleted"))
+	})
+
+	It("should wait for the target to be ready", func() {
+		ready["sample-2"] = false
+		reconcileMySQL()
+
+		Expect(servers["10.0.0.0"].readOnly).Should(BeFalse())
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(mysql.Annotations).Should(HaveKey(v1beta1.SwitchoverAnnotation))
+		status, reason := switchoverCondition()
+		Expect(status).Should(Equal(corev1.ConditionTrue))
+		Expect(reason).Should(Equal("WaitingForPods"))
+	})
+
+	It("should keep the primary writable when the target can not catch up", func() {
+		servers["10.0.0.2"].catchUpErr = fmt.Errorf("timed out")
+		reconcileMySQL()
+
+		Expect(servers["10.0.0.2"].promoted).Should(BeFalse())
+		Expect(servers["10.0.0.0"].readOnly).Should(BeFalse())
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(mysql.Annotations).ShouldNot(HaveKey(v1beta1.SwitchoverAnnotation))
+		status, reason := switchoverCondition()
+		Expect(status).Should(Equal(corev1.ConditionFalse))
+		Expect(reason).Should(Equal("SwitchoverFailed"))
+	})
+
+	// outdatedStatefulSet 은 sample-0 만 이전 템플릿으로 남은 스테이트풀셋을 만든다
+	outdatedStatefulSet := func() *v12.StatefulSet {
+		for _, name := range []string{"sample-1", "sample-2"} {
+			pod := &corev1.Pod{}
+			Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, pod)).Should(Succeed())
+			pod.Labels[v12.StatefulSetRevisionLabel] = "rev-2"
+			Expect(r.Client.Update(context.TODO(), pod)).Should(Succeed())
+		}
+		replicas := int32(3)
+		return &v12.StatefulSet{
+			Spec:   v12.StatefulSetSpec{Replicas: &replicas},
+			Status: v12.StatefulSetStatus{UpdateRevision: "rev-2"},
+		}
+	}
+
+	It("should move the primary before restarting it for a template change", func() {
+		delete(mysql.Annotations, v1beta1.SwitchoverAnnotation)
+		reconcileMySQL()
+		sf := outdatedStatefulSet()
+
+		rolled, err := r.rollPods(sf, mysql)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rolled).Should(BeFalse())
+		Expect(servers["10.0.0.1"].promoted).Should(BeTrue())
+		Expect(servers["10.0.0.0"].source).Should(Equal("sample-1.sample"))
+		Expect(servers["10.0.0.2"].source).Should(Equal("sample-1.sample"))
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		Expect(mysql.Status.Primary).Should(Equal("sample-1"))
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-0"}, &corev1.Pod{})).Should(Succeed())
+
+		// 다음 조정에서는 레플리카가 된 기존 프라이머리를 재시작한다
+		_, err = r.rollPods(sf, mysql)
+		Expect(err).ShouldNot(HaveOccurred())
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-0"}, &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("should restart the primary in place during an upgrade", func() {
+		delete(mysql.Annotations, v1beta1.SwitchoverAnnotation)
+		reconcileMySQL()
+		sf := outdatedStatefulSet()
+		mysql.Status.Conditions.SetCondition(condition.Condition{
+			Type:   v1beta1.ConditionTypeUpgrading,
+			Status: corev1.ConditionTrue,
+			Reason: "RollingPods",
+		})
+
+		_, err := r.rollPods(sf, mysql)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(servers["10.0.0.1"].promoted).Should(BeFalse())
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-0"}, &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+	})
+})
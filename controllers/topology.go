@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+)
+
+// topologyRefreshInterval 은 각 파드의 복제 상태를 다시 확인하는 주기이다
+const topologyRefreshInterval = 30 * time.Second
+
+// syncTopology 는 준비된 파드마다 MySQL 서버에 접속하여 복제 상태를 Status.Instances 에 기록한다
+func (r *MySQLReconciler) syncTopology(mysql *mysqlv1beta1.MySQL) error {
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	sort.Slice(pods, func(i, j int) bool { return podOrdinal(pods[i].Name) < podOrdinal(pods[j].Name) })
+
+	var rootPassword string
+	instances := make([]mysqlv1beta1.InstanceStatus, 0, len(pods))
+	for i := range pods {
+		instance := mysqlv1beta1.InstanceStatus{Name: pods[i].Name}
+		if !isPodReady(&pods[i]) || pods[i].Status.PodIP == "" {
+			instance.LastError = "Pod is not ready"
+			instances = append(instances, instance)
+			continue
+		}
+		if rootPassword == "" {
+			if rootPassword, err = r.secretValue(mysql, rootPasswordSecretRef(mysql)); err != nil {
+				return err
+			}
+		}
+		if err := r.observeInstance(&pods[i], rootPassword, &instance); err != nil {
+			instance.LastError = err.Error()
+		}
+		instances = append(instances, instance)
+	}
+
+	// 프라이머리가 아닌 파드가 쓰기를 받기 시작하면 데이터가 갈라질 수 있으므로 알린다
+	for _, instance := range instances {
+		if instance.Role == rolePrimary && instance.Name != mysql.Status.Primary && observedRole(mysql, instance.Name) != rolePrimary {
+			r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "UnexpectedWritable", "%s accepts writes but the primary is %s", instance.Name, mysql.Status.Primary)
+		}
+	}
+	if equality.Semantic.DeepEqual(instances, mysql.Status.Instances) {
+		return nil
+	}
+	mysql.Status.Instances = instances
+	return r.Status().Update(context.TODO(), mysql)
+}
+
+// observeInstance 는 파드의 MySQL 서버에서 읽기 전용 여부와 복제 상태를 읽는다
+func (r *MySQLReconciler) observeInstance(pod *corev1.Pod, rootPassword string, instance *mysqlv1beta1.InstanceStatus) error {
+	c, err := r.connect(pod, rootPassword)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx := context.TODO()
+	if instance.ReadOnly, instance.SuperReadOnly, err = c.ReadOnly(ctx); err != nil {
+		return err
+	}
+	if instance.ExecutedGTIDSet, err = c.ExecutedGTIDSet(ctx); err != nil {
+		return err
+	}
+	status, err := c.ReplicaStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if status == nil {
+		if !instance.ReadOnly && !instance.SuperReadOnly {
+			instance.Role = rolePrimary
+		}
+		return nil
+	}
+	instance.Role = roleReplica
+	instance.SourceHost = status.SourceHost
+	instance.SecondsBehindSource = status.SecondsBehindSource
+	instance.IOThreadRunning = status.IORunning
+	instance.SQLThreadRunning = status.SQLRunning
+	if status.LastIOError != "" {
+		instance.LastError = status.LastIOError
+	} else if status.LastSQLError != "" {
+		instance.LastError = status.LastSQLError
+	}
+	return nil
+}
+
+// observedRole 은 마지막으로 관찰한 파드의 복제 역할을 반환한다
+func observedRole(mysql *mysqlv1beta1.MySQL, name string) string {
+	for _, instance := range mysql.Status.Instances {
+		if instance.Name == name {
+			return instance.Role
+		}
+	}
+	return ""
+}
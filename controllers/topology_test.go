@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql topology", func() {
+	const gtid = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10"
+	var (
+		r        *MySQLReconciler
+		recorder *record.FakeRecorder
+		servers  map[string]*fakeMySQL
+		req      = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+	)
+
+	newPod := func(name, ip string, ready corev1.ConditionStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"app": "sample"},
+			},
+			Status: corev1.PodStatus{
+				PodIP:      ip,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+			},
+		}
+	}
+
+	reconcileMySQL := func() *v1beta1.MySQL {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		mysql := &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 3},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+			},
+			Status: v1beta1.MySQLStatus{
+				Primary: "sample-0",
+			},
+		}
+		recorder = record.NewFakeRecorder(10)
+		r = &MySQLReconciler{
+			Client: fake.NewFakeClientWithScheme(s, mysql,
+				newPod("sample-0", "10.0.0.0", corev1.ConditionTrue),
+				newPod("sample-1", "10.0.0.1", corev1.ConditionTrue),
+				newPod("sample-2", "10.0.0.2", corev1.ConditionFalse)),
+			Log:      ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme:   s,
+			Recorder: recorder,
+			MySQLClient: func(host, user, password string) (mysqlclient.Client, error) {
+				return servers[host], nil
+			},
+		}
+		result, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(Equal(topologyRefreshInterval))
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		return mysql
+	}
+
+	BeforeEach(func() {
+		lag := int64(3)
+		servers = map[string]*fakeMySQL{
+			"10.0.0.0": {},
+			"10.0.0.1": {readOnly: true, status: &mysqlclient.ReplicaStatus{
+				SourceHost:          "sample-0.sample",
+				IORunning:           true,
+				SQLRunning:          true,
+				SecondsBehindSource: &lag,
+				ExecutedGTIDSet:     gtid,
+			}},
+		}
+	})
+
+	It("should publish the replication state of each pod", func() {
+		mysql := reconcileMySQL()
+
+		Expect(mysql.Status.Instances).Should(HaveLen(3))
+		primary, replica, notReady := mysql.Status.Instances[0], mysql.Status.Instances[1], mysql.Status.Instances[2]
+		Expect(primary.Name).Should(Equal("sample-0"))
+		Expect(primary.Role).Should(Equal(rolePrimary))
+		Expect(primary.ReadOnly).Should(BeFalse())
+
+		Expect(replica.Name).Should(Equal("sample-1"))
+		Expect(replica.Role).Should(Equal(roleReplica))
+		Expect(replica.SuperReadOnly).Should(BeTrue())
+		Expect(replica.SourceHost).Should(Equal("sample-0.sample"))
+		Expect(replica.ExecutedGTIDSet).Should(Equal(gtid))
+		Expect(*replica.SecondsBehindSource).Should(Equal(int64(3)))
+		Expect(replica.IOThreadRunning).Should(BeTrue())
+		Expect(replica.SQLThreadRunning).Should(BeTrue())
+
+		Expect(notReady.Name).Should(Equal("sample-2"))
+		Expect(notReady.Role).Should(BeEmpty())
+		Expect(notReady.LastError).ShouldNot(BeEmpty())
+	})
+
+	It("should report replication errors", func() {
+		servers["10.0.0.1"].status.IORunning = false
+		servers["10.0.0.1"].status.LastIOError = "error connecting to master"
+		mysql := reconcileMySQL()
+
+		Expect(mysql.Status.Instances[1].IOThreadRunning).Should(BeFalse())
+		Expect(mysql.Status.Instances[1].LastError).Should(Equal("error connecting to master"))
+	})
+
+	It("should warn when a replica accepts writes", func() {
+		servers["10.0.0.1"] = &fakeMySQL{}
+		mysql := reconcileMySQL()
+
+		Expect(mysql.Status.Instances[1].Role).Should(Equal(rolePrimary))
+		Expect(mysql.Status.Primary).Should(Equal("sample-0"))
+		Expect(recorder.Events).Should(Receive(ContainSubstring("UnexpectedWritable")))
+	})
+})
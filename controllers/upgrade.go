@@ -0,0 +1,422 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/woohhan/kubebuilder-util/pkg/condition"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
+	"sample-mysql-operator/pkg/mysqlclient"
+)
+
+const (
+	// upgradeFromAnnotation 은 진행 중인 업그레이드의 이전 버전을 스테이트풀셋에 기록한다
+	upgradeFromAnnotation = "mysql.sample.com/upgrade-from"
+	// upgradeJobLabel 은 업그레이드를 위해 생성한 잡에 붙이는 레이블이다
+	upgradeJobLabel = "mysql.sample.com/upgrade"
+	// mysqlShellImage 는 8.0 업그레이드 검사에 사용하는 MySQL Shell 이미지이다
+	mysqlShellImage = "mysql/mysql-shell:8.0"
+)
+
+// syncUpgrade 는 Spec.Version 이 변경되면 사전 검사 후 레플리카부터 프라이머리 순서로 파드를 업그레이드한다
+func (r *MySQLReconciler) syncUpgrade(sf *appsv1.StatefulSet, mysql *mysqlv1beta1.MySQL) error {
+	current := sf.Spec.Template.Annotations[versionAnnotation]
+	if current == "" {
+		// 버전이 기록되기 전에 만들어진 스테이트풀셋은 기본 버전으로 동작하고 있다
+		current = mysqlv1beta1.DefaultVersion
+	}
+	target := mysql.Spec.ServerVersion()
+	if current != target {
+		return r.startUpgrade(sf, mysql, current, target)
+	}
+
+	// 템플릿 변경이 모든 파드에 반영될 때까지 파드를 하나씩 재시작한다
+	rolled, err := r.rollPods(sf, mysql)
+	if err != nil || !rolled {
+		return err
+	}
+	if _, ok := sf.Annotations[upgradeFromAnnotation]; ok {
+		return r.finishUpgrade(sf, mysql, target)
+	}
+	return r.cancelUpgrade(mysql, target)
+}
+
+func (r *MySQLReconciler) startUpgrade(sf *appsv1.StatefulSet, mysql *mysqlv1beta1.MySQL, current, target string) error {
+	cmp, err := mysqlv1beta1.CompareVersions(target, current)
+	if err != nil {
+		return err
+	}
+	if cmp < 0 {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeUpgrading,
+			Status:  corev1.ConditionFalse,
+			Reason:  "DowngradeNotAllowed",
+			Message: fmt.Sprintf("Downgrade from %s to %s is not supported", current, target),
+		})
+	}
+	if !mysqlv1beta1.IsUpgradePathSupported(current, target) {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeUpgrading,
+			Status:  corev1.ConditionFalse,
+			Reason:  "UnsupportedUpgradePath",
+			Message: fmt.Sprintf("Upgrade from %s to %s must go through every major version in between", current, target),
+		})
+	}
+	// 모든 파드가 준비된 상태에서만 업그레이드를 시작한다
+	if !isRolledOut(sf) {
+		return nil
+	}
+
+	job, err := r.syncJob(mysql, newPreflightJob(mysql, current, target))
+	if err != nil {
+		return err
+	}
+	finished, failed := jobFinished(job)
+	if !finished {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeUpgrading,
+			Status:  corev1.ConditionTrue,
+			Reason:  "PreflightChecking",
+			Message: fmt.Sprintf("Running pre-flight checks for %s in job %s", target, job.Name),
+		})
+	}
+	if failed {
+		return r.setCondition(mysql, condition.Condition{
+			Type:    mysqlv1beta1.ConditionTypeUpgrading,
+			Status:  corev1.ConditionFalse,
+			Reason:  "PreflightCheckFailed",
+			Message: fmt.Sprintf("Pre-flight checks for %s failed. See the logs of job %s and delete it to retry", target, job.Name),
+		})
+	}
+
+	r.Log.Info("Pre-flight checks passed. Start upgrade", "from", current, "to", target)
+	clonedStatefulSet := sf.DeepCopy()
+	if clonedStatefulSet.Annotations == nil {
+		clonedStatefulSet.Annotations = map[string]string{}
+	}
+	clonedStatefulSet.Annotations[upgradeFromAnnotation] = current
+	if clonedStatefulSet.Spec.Template.Annotations == nil {
+		clonedStatefulSet.Spec.Template.Annotations = map[string]string{}
+	}
+	clonedStatefulSet.Spec.Template.Annotations[versionAnnotation] = target
+	// 파드 재시작 순서를 오퍼레이터가 직접 결정한다
+	clonedStatefulSet.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+	for i := range clonedStatefulSet.Spec.Template.Spec.Containers {
+		if clonedStatefulSet.Spec.Template.Spec.Containers[i].Name == "mysql" {
+			clonedStatefulSet.Spec.Template.Spec.Containers[i].Image = mysql.Spec.ServerImage()
+		}
+	}
+	if err := r.Update(context.TODO(), clonedStatefulSet); err != nil {
+		return err
+	}
+	return r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeUpgrading,
+		Status:  corev1.ConditionTrue,
+		Reason:  "RollingPods",
+		Message: fmt.Sprintf("Upgrading from %s to %s", current, target),
+	})
+}
+
+func (r *MySQLReconciler) finishUpgrade(sf *appsv1.StatefulSet, mysql *mysqlv1beta1.MySQL, target string) error {
+	// 8.0.16 미만의 서버는 시스템 테이블을 직접 업그레이드하지 않으므로 mysql_upgrade 를 실행한다
+	if needsMySQLUpgrade(target) {
+		job, err := r.syncJob(mysql, newMySQLUpgradeJob(mysql, target))
+		if err != nil {
+			return err
+		}
+		finished, failed := jobFinished(job)
+		if !finished {
+			return r.setCondition(mysql, condition.Condition{
+				Type:    mysqlv1beta1.ConditionTypeUpgrading,
+				Status:  corev1.ConditionTrue,
+				Reason:  "UpgradingSystemTables",
+				Message: fmt.Sprintf("Running mysql_upgrade in job %s", job.Name),
+			})
+		}
+		if failed {
+			return r.setCondition(mysql, condition.Condition{
+				Type:    mysqlv1beta1.ConditionTypeUpgrading,
+				Status:  corev1.ConditionFalse,
+				Reason:  "MySQLUpgradeFailed",
+				Message: fmt.Sprintf("mysql_upgrade failed. See the logs of job %s and delete it to retry", job.Name),
+			})
+		}
+	}
+
+	r.Log.Info("Upgrade completed", "version", target)
+	if err := r.deleteUpgradeJobs(mysql); err != nil {
+		return err
+	}
+	clonedStatefulSet := sf.DeepCopy()
+	delete(clonedStatefulSet.Annotations, upgradeFromAnnotation)
+	if err := r.Update(context.TODO(), clonedStatefulSet); err != nil {
+		return err
+	}
+	return r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeUpgrading,
+		Status:  corev1.ConditionFalse,
+		Reason:  "UpgradeCompleted",
+		Message: fmt.Sprintf("Upgraded to %s", target),
+	})
+}
+
+// cancelUpgrade 는 Spec.Version 이 현재 버전으로 되돌아온 경우 실패했거나 대기 중인 업그레이드를 정리한다
+func (r *MySQLReconciler) cancelUpgrade(mysql *mysqlv1beta1.MySQL, current string) error {
+	cond := findCondition(mysql.Status.Conditions, mysqlv1beta1.ConditionTypeUpgrading)
+	if cond == nil || cond.Reason == "UpgradeCompleted" || cond.Reason == "UpgradeCanceled" {
+		return nil
+	}
+	if err := r.deleteUpgradeJobs(mysql); err != nil {
+		return err
+	}
+	return r.setCondition(mysql, condition.Condition{
+		Type:    mysqlv1beta1.ConditionTypeUpgrading,
+		Status:  corev1.ConditionFalse,
+		Reason:  "UpgradeCanceled",
+		Message: fmt.Sprintf("Version %s is already running", current),
+	})
+}
+
+// rollPods 는 스테이트풀셋 템플릿이 변경된 경우 레플리카부터 파드를 하나씩 삭제하고 프라이머리는 마지막에 삭제한다.
+// 업그레이드가 아니면 프라이머리는 삭제하기 전에 다른 파드로 옮긴다.
+// 모든 파드가 최신 템플릿으로 준비되었으면 true 를 반환한다
+func (r *MySQLReconciler) rollPods(sf *appsv1.StatefulSet, mysql *mysqlv1beta1.MySQL) (bool, error) {
+	if sf.Spec.Replicas == nil || sf.Status.ObservedGeneration < sf.Generation || sf.Status.UpdateRevision == "" {
+		return false, nil
+	}
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return false, err
+	}
+	if int32(len(pods)) < *sf.Spec.Replicas {
+		return false, nil
+	}
+	var outdated []corev1.Pod
+	for i := range pods {
+		// 재시작 중인 파드가 있으면 준비될 때까지 기다린다
+		if !isPodReady(&pods[i]) {
+			return false, nil
+		}
+		if pods[i].Labels[appsv1.StatefulSetRevisionLabel] != sf.Status.UpdateRevision {
+			outdated = append(outdated, pods[i])
+		}
+	}
+	if len(outdated) == 0 {
+		return true, nil
+	}
+
+	// 순번이 높은 레플리카부터 재시작하고 프라이머리는 가장 마지막에 재시작한다
+	primary := primaryPodName(mysql)
+	sort.Slice(outdated, func(i, j int) bool {
+		if (outdated[i].Name == primary) != (outdated[j].Name == primary) {
+			return outdated[j].Name == primary
+		}
+		return podOrdinal(outdated[i].Name) > podOrdinal(outdated[j].Name)
+	})
+	pod := &outdated[0]
+	if pod.Name == primary && !mysql.Status.Conditions.IsTrueFor(mysqlv1beta1.ConditionTypeUpgrading) {
+		// 업그레이드가 아닌 재시작은 프라이머리를 먼저 다른 파드로 옮겨, 재시작하는 동안 쓰기가 멈추거나 페일오버가 일어나지 않게 한다
+		if moved, err := r.switchoverForRestart(mysql, pod, pods); moved || err != nil {
+			return false, err
+		}
+	}
+	r.Log.Info("Restart pod to apply the new template", "pod", pod.Name, "remaining", len(outdated))
+	if err := r.Delete(context.TODO(), pod); err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return false, nil
+}
+
+// switchoverForRestart 는 재시작할 프라이머리를 순번이 가장 낮은 레플리카로 옮긴다. 모든 서버가 GTID 를 사용하기 전이거나
+// 옮길 레플리카가 없으면 false 를 반환하여 프라이머리를 그대로 재시작하게 한다
+func (r *MySQLReconciler) switchoverForRestart(mysql *mysqlv1beta1.MySQL, primaryPod *corev1.Pod, pods []corev1.Pod) (bool, error) {
+	if mysql.Status.GTIDMode != mysqlclient.GTIDModeOn {
+		return false, nil
+	}
+	var targetPod *corev1.Pod
+	var replicas []*corev1.Pod
+	for i := range pods {
+		if pods[i].Name == primaryPod.Name || pods[i].Status.PodIP == "" {
+			continue
+		}
+		if targetPod == nil || podOrdinal(pods[i].Name) < podOrdinal(targetPod.Name) {
+			if targetPod != nil {
+				replicas = append(replicas, targetPod)
+			}
+			targetPod = &pods[i]
+		} else {
+			replicas = append(replicas, &pods[i])
+		}
+	}
+	if targetPod == nil {
+		return false, nil
+	}
+
+	r.Log.Info("Move primary before restarting it", "from", primaryPod.Name, "to", targetPod.Name)
+	if err := r.switchover(mysql, primaryPod, targetPod, replicas); err != nil {
+		r.Recorder.Eventf(mysql, corev1.EventTypeWarning, "SwitchoverFailed", "Failed to switch primary from %s to %s before restarting it: %v", primaryPod.Name, targetPod.Name, err)
+		return false, err
+	}
+	mysql.Status.Primary = targetPod.Name
+	if err := r.Status().Update(context.TODO(), mysql); err != nil {
+		return false, err
+	}
+	r.Recorder.Eventf(mysql, corev1.EventTypeNormal, "SwitchoverCompleted", "Switched primary from %s to %s before restarting it", primaryPod.Name, targetPod.Name)
+	return true, nil
+}
+
+// needsMySQLUpgrade 는 업그레이드 후 mysql_upgrade 를 실행해야 하는지 확인한다
+func needsMySQLUpgrade(version string) bool {
+	cmp, err := mysqlv1beta1.CompareVersions(version, "8.0.16")
+	return err == nil && cmp < 0
+}
+
+func newPreflightJob(mysql *mysqlv1beta1.MySQL, current, target string) *batchv1.Job {
+	host := podHost(mysql, primaryPodName(mysql))
+	image := mysql.Spec.ServerImage()
+	command := fmt.Sprintf("mysqlcheck -h %s -u root --all-databases --check-upgrade", host)
+	if mysqlv1beta1.MajorVersion(current) != "8.0" && mysqlv1beta1.MajorVersion(target) == "8.0" {
+		// 8.0 으로의 업그레이드는 MySQL Shell 의 업그레이드 검사기로 호환성을 확인한다
+		image = mysqlShellImage
+		targetVersion := ""
+		if target != mysqlv1beta1.MajorVersion(target) {
+			targetVersion = "--target-version=" + target
+		}
+		command = fmt.Sprintf("mysqlsh --no-wizard --uri=root@%s:3306 --password=\"$MYSQL_PWD\" -- util checkForServerUpgrade %s --output-format=JSON > /tmp/report.json; "+
+			"cat /tmp/report.json; grep -q '\"errorCount\": 0' /tmp/report.json", host, targetVersion)
+	}
+	return newUpgradeJob(mysql, fmt.Sprintf("%s-preflight-%s", mysql.Name, versionSuffix(target)), image, command)
+}
+
+func newMySQLUpgradeJob(mysql *mysqlv1beta1.MySQL, target string) *batchv1.Job {
+	primary := primaryPodName(mysql)
+	var replicas []string
+	for i := 0; i < int(mysql.Spec.Replication.Replicas); i++ {
+		if name := podName(mysql, i); name != primary {
+			replicas = append(replicas, podHost(mysql, name))
+		}
+	}
+	// 레플리카는 super_read_only 를 잠시 해제한 상태에서 업그레이드하고, 프라이머리는 마지막에 업그레이드한다
+	command := fmt.Sprintf(`for host in %s; do
+  mysql -h "$host" -u root -e "SET GLOBAL super_read_only = OFF" || exit 1
+  mysql_upgrade -h "$host" -u root --force
+  rc=$?
+  mysql -h "$host" -u root -e "SET GLOBAL super_read_only = ON"
+  [ $rc -eq 0 ] || exit $rc
+done
+mysql_upgrade -h %s -u root --force`, strings.Join(replicas, " "), podHost(mysql, primary))
+	return newUpgradeJob(mysql, fmt.Sprintf("%s-mysql-upgrade-%s", mysql.Name, versionSuffix(target)), mysql.Spec.ServerImage(), command)
+}
+
+func newUpgradeJob(mysql *mysqlv1beta1.MySQL, name, image, command string) *batchv1.Job {
+	backoffLimit := int32(0)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: mysql.Namespace,
+			Name:      name,
+			Labels: map[string]string{
+				upgradeJobLabel: mysql.Name,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						upgradeJobLabel: mysql.Name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    "upgrade",
+							Image:   image,
+							Command: []string{"sh", "-c", command},
+							// mysql 클라이언트 도구는 MYSQL_PWD 환경 변수의 비밀번호로 접속한다
+							Env: []corev1.EnvVar{
+								{
+									Name:      "MYSQL_PWD",
+									ValueFrom: &corev1.EnvVarSource{SecretKeyRef: rootPasswordSecretRef(mysql)},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func versionSuffix(version string) string {
+	return strings.ReplaceAll(version, ".", "-")
+}
+
+// syncJob 은 잡이 없으면 생성하고, 있으면 현재 잡을 반환한다
+func (r *MySQLReconciler) syncJob(mysql *mysqlv1beta1.MySQL, job *batchv1.Job) (*batchv1.Job, error) {
+	found := &batchv1.Job{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: job.Namespace, Name: job.Name}, found)
+	if err == nil {
+		return found, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	r.Log.Info("Could not find job. Create a new one", "job", job.Name)
+	if err := controllerutil.SetControllerReference(mysql, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(context.TODO(), job); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *MySQLReconciler) deleteUpgradeJobs(mysql *mysqlv1beta1.MySQL) error {
+	jobs := &batchv1.JobList{}
+	if err := r.List(context.TODO(), jobs, client.InNamespace(mysql.Namespace), client.MatchingLabels{upgradeJobLabel: mysql.Name}); err != nil {
+		return err
+	}
+	for i := range jobs.Items {
+		if err := r.Delete(context.TODO(), &jobs.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// jobFinished 는 잡이 끝났는지와 실패했는지를 반환한다
+func jobFinished(job *batchv1.Job) (finished, failed bool) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, false
+		case batchv1.JobFailed:
+			return true, true
+		}
+	}
+	return false, false
+}
+
+func findCondition(conditions condition.Conditions, t condition.ConditionType) *condition.Condition {
+	for i := range conditions {
+		if conditions[i].Type == t {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
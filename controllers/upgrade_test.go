@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v12 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("mysql upgrade", func() {
+	var (
+		r   *MySQLReconciler
+		req reconcile.Request
+	)
+
+	// setVersion 은 스테이트풀셋이 모두 준비된 상태에서 MySQL 버전을 변경한다
+	setVersion := func(version string) {
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		sf.Status.UpdatedReplicas = 2
+		sf.Status.ReadyReplicas = 2
+		sf.Status.UpdateRevision = "rev-1"
+		Expect(r.Client.Update(context.TODO(), sf)).Should(Succeed())
+
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		mysql.Spec.Server.Version = version
+		Expect(r.Client.Update(context.TODO(), mysql)).Should(Succeed())
+	}
+
+	getMySQL := func() *v1beta1.MySQL {
+		mysql := &v1beta1.MySQL{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, mysql)).Should(Succeed())
+		return mysql
+	}
+
+	BeforeEach(func() {
+		s := scheme.Scheme
+		Expect(v1alpha1.AddToScheme(s)).Should(Succeed())
+		Expect(v1beta1.AddToScheme(s)).Should(Succeed())
+		mysql := &v1beta1.MySQL{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "sample",
+				Namespace: "default",
+			},
+			Spec: v1beta1.MySQLSpec{
+				Replication: v1beta1.ReplicationSpec{Replicas: 2},
+				Owner:       v1beta1.OwnerSpec{Name: "woohyung han"},
+				Server:      v1beta1.ServerSpec{Version: "5.7"},
+			},
+		}
+		r = &MySQLReconciler{
+			Client: fake.NewFakeClientWithScheme(s, mysql),
+			Log:    ctrl.Log.WithName("controllers").WithName("MySQL"),
+			Scheme: s,
+		}
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "sample"}}
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should run pre-flight checks before changing the image", func() {
+		setVersion("8.0")
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		job := &batchv1.Job{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-preflight-8-0"}, job)).Should(Succeed())
+		Expect(job.Spec.Template.Spec.Containers[0].Image).Should(Equal(mysqlShellImage))
+		Expect(getMySQL().Status.Conditions.IsTrueFor(v1beta1.ConditionTypeUpgrading)).Should(BeTrue())
+
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Spec.Containers[0].Image).Should(Equal("mysql:5.7"))
+	})
+
+	It("should update the template after pre-flight checks pass", func() {
+		setVersion("8.0")
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		job := &batchv1.Job{}
+		Expect(r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "sample-preflight-8-0"}, job)).Should(Succeed())
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(r.Client.Update(context.TODO(), job)).Should(Succeed())
+		_, err = r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Spec.Containers[0].Image).Should(Equal("mysql:8.0"))
+		Expect(sf.Spec.Template.Annotations[versionAnnotation]).Should(Equal("8.0"))
+		Expect(sf.Annotations[upgradeFromAnnotation]).Should(Equal("5.7"))
+		Expect(sf.Spec.UpdateStrategy.Type).Should(Equal(v12.OnDeleteStatefulSetStrategyType))
+	})
+
+	It("should block downgrades", func() {
+		setVersion("5.6")
+		_, err := r.Reconcile(req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(getMySQL().Status.Conditions.IsFalseFor(v1beta1.ConditionTypeUpgrading)).Should(BeTrue())
+		sf := &v12.StatefulSet{}
+		Expect(r.Client.Get(context.TODO(), req.NamespacedName, sf)).Should(Succeed())
+		Expect(sf.Spec.Template.Spec.Containers[0].Image).Should(Equal("mysql:5.7"))
+	})
+
+	It("should not skip a major version", func() {
+		Expect(v1beta1.IsUpgradePathSupported("5.6", "8.0")).Should(BeFalse())
+		Expect(v1beta1.IsUpgradePathSupported("5.7", "8.0.20")).Should(BeTrue())
+		Expect(v1beta1.IsUpgradePathSupported("5.7.30", "5.7.31")).Should(BeTrue())
+	})
+})
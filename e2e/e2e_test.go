@@ -11,25 +11,13 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sample-mysql-operator/api/v1alpha1"
+	"sample-mysql-operator/api/v1alpha2"
 	"time"
 )
 
 var _ = Describe("MySQL Controller E2E tests", func() {
 	Context("Basic", func() {
 		It("Should ok", func() {
-			By("Creating configMap")
-			configMap := &corev1.ConfigMap{
-				ObjectMeta: v1.ObjectMeta{
-					Name:      "mysql",
-					Namespace: "default",
-				},
-				Data: map[string]string{
-					"master.cnf": "[mysqld]\nlog-bin",
-					"slave.cnf":  "[mysqld]\nsuper-read-only",
-				},
-			}
-			Expect(k8sClient.Create(context.TODO(), configMap)).Should(Succeed())
-
 			By("Creating mysql")
 			toCreate := &v1alpha1.MySQL{
 				ObjectMeta: v1.ObjectMeta{
@@ -49,6 +37,10 @@ var _ = Describe("MySQL Controller E2E tests", func() {
 						Namespace: "default",
 					},
 				}
+				By("Refusing to delete mysql with deletion protection")
+				Expect(k8sClient.Delete(context.TODO(), toDelete)).ShouldNot(Succeed())
+				disableDeletionProtection(types.NamespacedName{Namespace: "default", Name: "mysql-sample"})
+
 				By("Deleting mysql")
 				Expect(k8sClient.Delete(context.TODO(), toDelete)).Should(Succeed())
 
@@ -60,9 +52,6 @@ var _ = Describe("MySQL Controller E2E tests", func() {
 					}
 					return false
 				}, 180*time.Second, 5*time.Second).Should(BeTrue())
-
-				By("Deleting configMap")
-				Expect(k8sClient.Delete(context.TODO(), configMap)).Should(Succeed())
 			}()
 
 			By("Waiting for mysql to be running condition")
@@ -75,6 +64,23 @@ var _ = Describe("MySQL Controller E2E tests", func() {
 				return mysql.Status.Conditions.IsTrueFor(v1alpha1.ConditionTypeRunning)
 			}, 300*time.Second, 5*time.Second).Should(BeTrue())
 
+			By("Checking the config map of the cluster")
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "mysql-sample-config"}, configMap)).Should(Succeed())
+			Expect(configMap.Data).Should(HaveKey("master.cnf"))
+			Expect(configMap.Data).Should(HaveKey("slave.cnf"))
+
+			By("Checking the primary service")
+			Expect(k8sClient.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "mysql-sample-primary"}, &corev1.Service{})).Should(Succeed())
+			Eventually(func() bool {
+				endpoints := &corev1.Endpoints{}
+				if err := k8sClient.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "mysql-sample-primary"}, endpoints); err != nil {
+					return false
+				}
+				return len(endpoints.Subsets) == 1 && len(endpoints.Subsets[0].Addresses) == 1 &&
+					endpoints.Subsets[0].Addresses[0].TargetRef.Name == "mysql-sample-0"
+			}, 60*time.Second, 5*time.Second).Should(BeTrue())
+
 			By("Updating mysql replicas 2->3")
 			toUpdate := &v1alpha1.MySQL{}
 			Expect(k8sClient.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "mysql-sample"}, toUpdate)).Should(Succeed())
@@ -92,4 +98,90 @@ var _ = Describe("MySQL Controller E2E tests", func() {
 			}, 180*time.Second, 5*time.Second).Should(BeTrue())
 		})
 	})
+
+	Context("Conversion", func() {
+		key := types.NamespacedName{Namespace: "default", Name: "mysql-v1alpha2"}
+
+		It("Should serve both versions side by side", func() {
+			By("Rejecting a v1alpha2 mysql without a last name")
+			invalid := &v1alpha2.MySQL{
+				ObjectMeta: v1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec:       v1alpha2.MySQLSpec{Replicas: 1, OwnerFirstName: "woohyung"},
+			}
+			Expect(k8sClient.Create(context.TODO(), invalid)).ShouldNot(Succeed())
+
+			By("Creating mysql as v1alpha2")
+			toCreate := &v1alpha2.MySQL{
+				ObjectMeta: v1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec:       v1alpha2.MySQLSpec{Replicas: 1, OwnerFirstName: "woohyung", OwnerLastName: "han"},
+			}
+			Expect(k8sClient.Create(context.TODO(), toCreate)).Should(Succeed())
+			defer func() {
+				By("Deleting mysql")
+				disableDeletionProtection(key)
+				Expect(k8sClient.Delete(context.TODO(), &v1alpha1.MySQL{ObjectMeta: v1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}})).Should(Succeed())
+				Eventually(func() bool {
+					err := k8sClient.Get(context.TODO(), key, &v1alpha1.MySQL{})
+					return errors.IsNotFound(err)
+				}, 180*time.Second, 5*time.Second).Should(BeTrue())
+			}()
+
+			By("Reading it back as v1alpha1")
+			hub := &v1alpha1.MySQL{}
+			Expect(k8sClient.Get(context.TODO(), key, hub)).Should(Succeed())
+			Expect(hub.Spec.Replicas).Should(Equal(int32(1)))
+			Expect(hub.Spec.OwnerName).Should(Equal("woohyung han"))
+
+			// 오퍼레이터도 같은 객체를 수정하므로 충돌하면 다시 읽어서 수정한다
+			By("Setting a field that v1alpha2 can not represent")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.TODO(), key, hub); err != nil {
+					return err
+				}
+				hub.Spec.DeletionPolicy = v1alpha1.DeletionPolicyDelete
+				return k8sClient.Update(context.TODO(), hub)
+			}, 30*time.Second, time.Second).Should(Succeed())
+
+			By("Updating the owner through v1alpha2")
+			spoke := &v1alpha2.MySQL{}
+			Expect(k8sClient.Get(context.TODO(), key, spoke)).Should(Succeed())
+			Expect(spoke.Spec.OwnerFirstName).Should(Equal("woohyung"))
+			Expect(spoke.Spec.OwnerLastName).Should(Equal("han"))
+			Eventually(func() error {
+				if err := k8sClient.Get(context.TODO(), key, spoke); err != nil {
+					return err
+				}
+				spoke.Spec.OwnerLastName = "kim"
+				return k8sClient.Update(context.TODO(), spoke)
+			}, 30*time.Second, time.Second).Should(Succeed())
+
+			By("Keeping the v1alpha1 only field")
+			Expect(k8sClient.Get(context.TODO(), key, hub)).Should(Succeed())
+			Expect(hub.Spec.OwnerName).Should(Equal("woohyung kim"))
+			Expect(hub.Spec.DeletionPolicy).Should(Equal(v1alpha1.DeletionPolicyDelete))
+
+			By("Carrying the status conditions to v1alpha2")
+			Eventually(func() bool {
+				if err := k8sClient.Get(context.TODO(), key, hub); err != nil {
+					return false
+				}
+				return len(hub.Status.Conditions) > 0
+			}, 60*time.Second, 5*time.Second).Should(BeTrue())
+			Expect(k8sClient.Get(context.TODO(), key, spoke)).Should(Succeed())
+			Expect(spoke.Status.Conditions).ShouldNot(BeEmpty())
+		})
+	})
 })
+
+// disableDeletionProtection 은 클러스터를 삭제할 수 있도록 삭제 보호를 해제한다. 오퍼레이터와 충돌하면 다시 시도한다
+func disableDeletionProtection(key types.NamespacedName) {
+	Eventually(func() error {
+		mysql := &v1alpha1.MySQL{}
+		if err := k8sClient.Get(context.TODO(), key, mysql); err != nil {
+			return err
+		}
+		protected := false
+		mysql.Spec.DeletionProtection = &protected
+		return k8sClient.Update(context.TODO(), mysql)
+	}, 30*time.Second, time.Second).Should(Succeed())
+}
@@ -4,8 +4,13 @@ go 1.13
 
 require (
 	github.com/go-logr/logr v0.1.0
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/google/gofuzz v1.0.0
+	github.com/minio/minio-go/v6 v6.0.57
 	github.com/onsi/ginkgo v1.11.0
 	github.com/onsi/gomega v1.8.1
+	github.com/prometheus/client_golang v1.0.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/woohhan/kubebuilder-util v0.0.0-20200626025445-5dbee653ca73
 	k8s.io/api v0.17.2
 	k8s.io/apimachinery v0.17.2
@@ -28,7 +28,10 @@ import (
 
 	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
 	mysqlv1alpha2 "sample-mysql-operator/api/v1alpha2"
+	mysqlv1beta1 "sample-mysql-operator/api/v1beta1"
 	"sample-mysql-operator/controllers"
+	"sample-mysql-operator/pkg/binlog"
+	"sample-mysql-operator/pkg/mysqlclient"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -42,16 +45,19 @@ func init() {
 
 	_ = mysqlv1alpha1.AddToScheme(scheme)
 	_ = mysqlv1alpha2.AddToScheme(scheme)
+	_ = mysqlv1beta1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var agentImage string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&agentImage, "agent-image", "controller:latest", "The image that backup jobs run the agent from.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -69,18 +75,49 @@ func main() {
 	}
 
 	if err = (&controllers.MySQLReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("MySQL"),
-		Scheme: mgr.GetScheme(),
+		Client:              mgr.GetClient(),
+		Log:                 ctrl.Log.WithName("controllers").WithName("MySQL"),
+		Scheme:              mgr.GetScheme(),
+		Recorder:            mgr.GetEventRecorderFor("mysql-controller"),
+		MySQLClient:         mysqlclient.New,
+		AgentImage:          agentImage,
+		BinlogArchiveStatus: binlog.GetStatus,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MySQL")
 		os.Exit(1)
 	}
+	if err = (&controllers.MySQLBackupReconciler{
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("MySQLBackup"),
+		Scheme:     mgr.GetScheme(),
+		Recorder:   mgr.GetEventRecorderFor("mysqlbackup-controller"),
+		AgentImage: agentImage,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MySQLBackup")
+		os.Exit(1)
+	}
+	if err = (&controllers.MySQLBackupScheduleReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("MySQLBackupSchedule"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("mysqlbackupschedule-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MySQLBackupSchedule")
+		os.Exit(1)
+	}
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
 		if err = (&mysqlv1alpha1.MySQL{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook for v1alpha1", "webhook", "MySQL")
 			os.Exit(1)
 		}
+		if err = (&mysqlv1alpha2.MySQL{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook for v1alpha2", "webhook", "MySQL")
+			os.Exit(1)
+		}
+		if err = (&mysqlv1beta1.MySQL{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook for v1beta1", "webhook", "MySQL")
+			os.Exit(1)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 
@@ -0,0 +1,106 @@
+// Package backup 은 xtrabackup 사이드카에서 백업을 받아 저장소에 올린다
+package backup
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+
+	"sample-mysql-operator/pkg/storage"
+)
+
+// xtrabackup 이 백업에 함께 넣는 메타데이터 파일
+const (
+	binlogInfoFile = "xtrabackup_binlog_info"
+	infoFile       = "xtrabackup_info"
+)
+
+// Result 는 저장한 백업에 대한 정보이다. 백업 잡은 이를 JSON 으로 종료 메시지에 남긴다
+type Result struct {
+	Location       string `json:"location"`
+	Size           int64  `json:"size"`
+	ServerVersion  string `json:"serverVersion,omitempty"`
+	BinlogFile     string `json:"binlogFile"`
+	BinlogPosition int64  `json:"binlogPosition"`
+	GTIDSet        string `json:"gtidSet,omitempty"`
+}
+
+// Backup 은 addr 의 xtrabackup 사이드카에서 xbstream 을 받아 backend 의 key 에 저장한다.
+// 스트림을 저장하면서 함께 읽어 백업 시점의 바이너리 로그 위치와 GTID 집합을 구한다
+func Backup(ctx context.Context, backend storage.Backend, addr, key string) (*Result, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	files := map[string][]byte{binlogInfoFile: nil, infoFile: nil}
+	pr, pw := io.Pipe()
+	scanned := make(chan error, 1)
+	go func() {
+		err := scanXbstream(pr, files)
+		// 읽기에 실패해도 저장은 끝까지 진행되도록 나머지를 버린다
+		io.Copy(ioutil.Discard, pr)
+		scanned <- err
+	}()
+
+	size, err := backend.Put(ctx, key, io.TeeReader(conn, pw))
+	pw.CloseWithError(err)
+	scanErr := <-scanned
+	if err != nil {
+		return nil, fmt.Errorf("failed to store backup: %v", err)
+	}
+	if scanErr != nil {
+		backend.Delete(ctx, key)
+		return nil, fmt.Errorf("invalid backup stream: %v", scanErr)
+	}
+	if files[binlogInfoFile] == nil {
+		// 사이드카가 백업 도중 끊기면 메타데이터 파일이 없는 불완전한 스트림이 남는다
+		backend.Delete(ctx, key)
+		return nil, fmt.Errorf("backup stream does not contain %s", binlogInfoFile)
+	}
+
+	result := &Result{Location: backend.Location(key), Size: size}
+	if err := parseBinlogInfo(string(files[binlogInfoFile]), result); err != nil {
+		backend.Delete(ctx, key)
+		return nil, err
+	}
+	result.ServerVersion = parseInfo(string(files[infoFile]))["server_version"]
+	return result, nil
+}
+
+// parseBinlogInfo 는 "<파일>\t<위치>[\t<GTID 집합>]" 형식의 xtrabackup_binlog_info 를 읽는다
+func parseBinlogInfo(s string, result *Result) error {
+	fields := strings.SplitN(strings.TrimSpace(s), "\t", 3)
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid %s: %q", binlogInfoFile, s)
+	}
+	position, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid binlog position in %s: %v", binlogInfoFile, err)
+	}
+	result.BinlogFile = fields[0]
+	result.BinlogPosition = position
+	if len(fields) == 3 {
+		result.GTIDSet = strings.Join(strings.Fields(fields[2]), "")
+	}
+	return nil
+}
+
+// parseInfo 는 "키 = 값" 줄로 이루어진 xtrabackup_info 를 읽는다
+func parseInfo(s string) map[string]string {
+	info := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		if kv := strings.SplitN(scanner.Text(), "=", 2); len(kv) == 2 {
+			info[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return info
+}
@@ -0,0 +1,118 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"net"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"sample-mysql-operator/pkg/storage"
+)
+
+// writeChunk 는 xbstream 페이로드 청크 하나를 쓴다
+func writeChunk(buf *bytes.Buffer, path string, offset uint64, data []byte) {
+	buf.WriteString(xbstreamMagic)
+	buf.WriteByte(0)
+	buf.WriteByte(chunkPayload)
+	binary.Write(buf, binary.LittleEndian, uint32(len(path)))
+	buf.WriteString(path)
+	binary.Write(buf, binary.LittleEndian, uint64(len(data)))
+	binary.Write(buf, binary.LittleEndian, offset)
+	binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE(data))
+	buf.Write(data)
+}
+
+// writeEOF 는 파일의 끝을 나타내는 xbstream 청크를 쓴다
+func writeEOF(buf *bytes.Buffer, path string) {
+	buf.WriteString(xbstreamMagic)
+	buf.WriteByte(0)
+	buf.WriteByte(chunkEOF)
+	binary.Write(buf, binary.LittleEndian, uint32(len(path)))
+	buf.WriteString(path)
+}
+
+var _ = Describe("backup", func() {
+	const gtid = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10,\n4f22fb58-82db-22f2-af44-d91bba53a673:1-3"
+	var (
+		root    string
+		backend storage.Backend
+	)
+
+	// serve 는 xtrabackup 사이드카처럼 연결되면 stream 을 보내고 닫는 서버의 주소를 반환한다
+	serve := func(stream []byte) string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ShouldNot(HaveOccurred())
+		go func() {
+			defer l.Close()
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write(stream)
+			conn.Close()
+		}()
+		return l.Addr().String()
+	}
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "backup")
+		Expect(err).ShouldNot(HaveOccurred())
+		backend = storage.NewFilesystem(root, "pvc://backups")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(root)).Should(Succeed())
+	})
+
+	It("should store the stream and record the binlog position", func() {
+		stream := &bytes.Buffer{}
+		writeChunk(stream, "ibdata1", 0, bytes.Repeat([]byte{1}, 1024))
+		writeEOF(stream, "ibdata1")
+		writeChunk(stream, binlogInfoFile, 0, []byte("binlog.000003\t1234\t"+gtid[:20]))
+		writeChunk(stream, binlogInfoFile, 20, []byte(gtid[20:]+"\n"))
+		writeEOF(stream, binlogInfoFile)
+		writeChunk(stream, infoFile, 0, []byte("uuid = 1\nserver_version = 8.0.20\n"))
+		writeEOF(stream, infoFile)
+
+		result, err := Backup(context.TODO(), backend, serve(stream.Bytes()), "sample/backup.xbstream")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Location).Should(Equal("pvc://backups/sample/backup.xbstream"))
+		Expect(result.Size).Should(Equal(int64(stream.Len())))
+		Expect(result.ServerVersion).Should(Equal("8.0.20"))
+		Expect(result.BinlogFile).Should(Equal("binlog.000003"))
+		Expect(result.BinlogPosition).Should(Equal(int64(1234)))
+		Expect(result.GTIDSet).Should(Equal("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10,4f22fb58-82db-22f2-af44-d91bba53a673:1-3"))
+
+		stored, err := ioutil.ReadFile(root + "/sample/backup.xbstream")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(stored).Should(Equal(stream.Bytes()))
+	})
+
+	It("should fail and remove an incomplete backup", func() {
+		stream := &bytes.Buffer{}
+		writeChunk(stream, "ibdata1", 0, bytes.Repeat([]byte{1}, 1024))
+
+		_, err := Backup(context.TODO(), backend, serve(stream.Bytes()[:100]), "sample/backup.xbstream")
+		Expect(err).Should(HaveOccurred())
+		keys, err := backend.List(context.TODO(), "")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(keys).Should(BeEmpty())
+	})
+
+	It("should reject a corrupted stream", func() {
+		stream := &bytes.Buffer{}
+		writeChunk(stream, binlogInfoFile, 0, []byte("binlog.000003\t1234\n"))
+		data := stream.Bytes()
+		data[len(data)-2] = 'x'
+
+		_, err := Backup(context.TODO(), backend, serve(data), "sample/backup.xbstream")
+		Expect(err).Should(HaveOccurred())
+	})
+})
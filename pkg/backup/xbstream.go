@@ -0,0 +1,84 @@
+package backup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// xbstreamMagic 은 xbstream 청크의 시작을 나타낸다
+const xbstreamMagic = "XBSTCK01"
+
+// xbstream 청크의 종류
+const (
+	chunkPayload = 'P'
+	chunkSparse  = 'S'
+	chunkEOF     = 'E'
+)
+
+// scanXbstream 은 xbstream 을 끝까지 읽고 files 에 이름이 있는 파일의 내용을 모은다.
+// 백업 전체를 메모리에 올리지 않도록 files 에 없는 파일은 버린다
+func scanXbstream(r io.Reader, files map[string][]byte) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(xbstreamMagic)+2+4)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read chunk header: %v", err)
+		}
+		if string(header[:len(xbstreamMagic)]) != xbstreamMagic {
+			return fmt.Errorf("invalid xbstream chunk magic %q", header[:len(xbstreamMagic)])
+		}
+		chunkType := header[len(xbstreamMagic)+1]
+		path := make([]byte, binary.LittleEndian.Uint32(header[len(xbstreamMagic)+2:]))
+		if _, err := io.ReadFull(br, path); err != nil {
+			return fmt.Errorf("failed to read chunk path: %v", err)
+		}
+
+		switch chunkType {
+		case chunkEOF:
+			continue
+		case chunkPayload, chunkSparse:
+		default:
+			return fmt.Errorf("unknown xbstream chunk type %q in %s", chunkType, path)
+		}
+
+		var sparseMapSize uint32
+		if chunkType == chunkSparse {
+			if err := binary.Read(br, binary.LittleEndian, &sparseMapSize); err != nil {
+				return fmt.Errorf("failed to read sparse map size: %v", err)
+			}
+		}
+		var payload struct {
+			Length   uint64
+			Offset   uint64
+			Checksum uint32
+		}
+		if err := binary.Read(br, binary.LittleEndian, &payload); err != nil {
+			return fmt.Errorf("failed to read chunk payload header: %v", err)
+		}
+		if _, err := io.CopyN(ioutil.Discard, br, int64(sparseMapSize)*16); err != nil {
+			return fmt.Errorf("failed to read sparse map: %v", err)
+		}
+
+		if _, ok := files[string(path)]; !ok || chunkType != chunkPayload {
+			if _, err := io.CopyN(ioutil.Discard, br, int64(payload.Length)); err != nil {
+				return fmt.Errorf("failed to read chunk payload of %s: %v", path, err)
+			}
+			continue
+		}
+		data := make([]byte, payload.Length)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return fmt.Errorf("failed to read chunk payload of %s: %v", path, err)
+		}
+		if crc32.ChecksumIEEE(data) != payload.Checksum {
+			return fmt.Errorf("checksum mismatch in %s", path)
+		}
+		files[string(path)] = append(files[string(path)], data...)
+	}
+}
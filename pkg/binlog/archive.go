@@ -0,0 +1,177 @@
+// Package binlog 은 특정 시점 복구를 위해 바이너리 로그를 저장소에 보관하고 복원할 때 내려받는다
+package binlog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sample-mysql-operator/pkg/storage"
+)
+
+// timeLayout 은 키 앞에 붙이는 바이너리 로그의 마지막 기록 시각 형식이다. 키를 사전 순으로 정렬하면 시간 순서가 된다
+const timeLayout = "20060102T150405Z"
+
+// Key 는 prefix 아래에서 바이너리 로그 파일의 키를 반환한다. 같은 이름의 파일도 클러스터를 다시 만들거나
+// 페일오버한 뒤에는 내용이 다르므로 마지막으로 기록된 시각을 붙인다
+func Key(prefix string, modTime time.Time, file string) string {
+	return prefix + modTime.UTC().Format(timeLayout) + "-" + file
+}
+
+// ParseKey 는 키에서 바이너리 로그에 마지막으로 기록된 시각과 파일 이름을 읽는다
+func ParseKey(prefix, key string) (time.Time, string, error) {
+	name := strings.TrimPrefix(key, prefix)
+	i := strings.Index(name, "-")
+	if i < 0 {
+		return time.Time{}, "", fmt.Errorf("invalid binlog key %q", key)
+	}
+	t, err := time.Parse(timeLayout, name[:i])
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid binlog key %q: %v", key, err)
+	}
+	return t, name[i+1:], nil
+}
+
+// Archiver 는 닫힌 바이너리 로그 파일을 저장소에 올린다. 현재 기록 중인 파일은 닫힌 뒤에 올린다
+type Archiver struct {
+	// Backend 는 바이너리 로그를 보관할 저장소이다
+	Backend storage.Backend
+	// Dir 은 바이너리 로그와 인덱스 파일이 있는 MySQL 데이터 디렉터리이다
+	Dir string
+	// Prefix 는 저장소 안에서 바이너리 로그를 보관할 경로이다
+	Prefix string
+
+	mu       sync.Mutex
+	archived map[string]bool
+	status   Status
+}
+
+// Archive 는 아직 보관하지 않은 닫힌 바이너리 로그를 모두 저장소에 올린다
+func (a *Archiver) Archive(ctx context.Context) error {
+	err := a.archive(ctx)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.LastError = ""
+	if err != nil {
+		a.status.LastError = err.Error()
+	}
+	return err
+}
+
+func (a *Archiver) archive(ctx context.Context) error {
+	if a.archived == nil {
+		// 다시 시작한 경우 이미 보관한 파일을 다시 올리지 않는다
+		keys, err := a.Backend.List(ctx, a.Prefix)
+		if err != nil {
+			return err
+		}
+		archived := map[string]bool{}
+		for _, key := range keys {
+			archived[key] = true
+			// 바이너리 로그가 아닌 파일은 상태에 남기지 않는다
+			a.recordArchived(key)
+		}
+		a.archived = archived
+	}
+
+	files, err := closedBinlogs(a.Dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := filepath.Join(a.Dir, file)
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		key := Key(a.Prefix, info.ModTime(), file)
+		if a.archived[key] {
+			continue
+		}
+		if err := a.put(ctx, key, name); err != nil {
+			return fmt.Errorf("failed to archive %s: %v", file, err)
+		}
+		a.archived[key] = true
+		if err := a.recordArchived(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archiver) put(ctx context.Context, key, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = a.Backend.Put(ctx, key, f)
+	return err
+}
+
+// recordArchived 는 가장 최근에 기록된 바이너리 로그를 상태에 남긴다
+func (a *Archiver) recordArchived(key string) error {
+	t, _, err := ParseKey(a.Prefix, key)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status.LastArchivedTime == nil || t.After(*a.status.LastArchivedTime) {
+		a.status.LastArchivedKey = key
+		a.status.LastArchivedTime = &t
+	}
+	return nil
+}
+
+// Status 는 보관 상태를 반환한다
+func (a *Archiver) Status() Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.status
+}
+
+// closedBinlogs 는 인덱스 파일에 기록된 바이너리 로그 중 현재 기록 중인 마지막 파일을 뺀 나머지를 반환한다
+func closedBinlogs(dir string) ([]string, error) {
+	indexes, err := filepath.Glob(filepath.Join(dir, "*.index"))
+	if err != nil {
+		return nil, err
+	}
+	var closed []string
+	for _, index := range indexes {
+		// 릴레이 로그 인덱스는 보관하지 않는다
+		if strings.Contains(filepath.Base(index), "-relay-bin") {
+			continue
+		}
+		files, err := readIndex(index)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			closed = append(closed, files[:len(files)-1]...)
+		}
+	}
+	return closed, nil
+}
+
+// readIndex 는 바이너리 로그 인덱스 파일에 기록된 파일 이름을 순서대로 읽는다
+func readIndex(index string) ([]string, error) {
+	f, err := os.Open(index)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			files = append(files, filepath.Base(line))
+		}
+	}
+	return files, scanner.Err()
+}
@@ -0,0 +1,146 @@
+package binlog
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"sample-mysql-operator/pkg/storage"
+)
+
+var _ = Describe("binlog archive", func() {
+	const prefix = "sample/binlog/"
+	var (
+		dataDir    string
+		storageDir string
+		backend    storage.Backend
+		archiver   *Archiver
+		ctx        = context.Background()
+		base       = time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	// writeBinlog 은 바이너리 로그 파일을 쓰고 마지막 기록 시각을 modTime 으로 맞춘다
+	writeBinlog := func(name, content string, modTime time.Time) {
+		file := filepath.Join(dataDir, name)
+		Expect(ioutil.WriteFile(file, []byte(content), 0644)).Should(Succeed())
+		Expect(os.Chtimes(file, modTime, modTime)).Should(Succeed())
+	}
+
+	writeIndex := func(names ...string) {
+		var lines []string
+		for _, name := range names {
+			lines = append(lines, "./"+name)
+		}
+		Expect(ioutil.WriteFile(filepath.Join(dataDir, "sample-0-bin.index"), []byte(strings.Join(lines, "\n")+"\n"), 0644)).Should(Succeed())
+	}
+
+	list := func() []string {
+		keys, err := backend.List(ctx, prefix)
+		Expect(err).ShouldNot(HaveOccurred())
+		return keys
+	}
+
+	BeforeEach(func() {
+		var err error
+		dataDir, err = ioutil.TempDir("", "binlog-data")
+		Expect(err).ShouldNot(HaveOccurred())
+		storageDir, err = ioutil.TempDir("", "binlog-storage")
+		Expect(err).ShouldNot(HaveOccurred())
+		backend = storage.NewFilesystem(storageDir, "pvc://backups")
+		archiver = &Archiver{Backend: backend, Dir: dataDir, Prefix: prefix}
+
+		writeBinlog("sample-0-bin.000001", "first", base)
+		writeBinlog("sample-0-bin.000002", "second", base.Add(5*time.Minute))
+		writeBinlog("sample-0-bin.000003", "current", base.Add(7*time.Minute))
+		writeIndex("sample-0-bin.000001", "sample-0-bin.000002", "sample-0-bin.000003")
+		// 릴레이 로그는 보관하지 않는다
+		Expect(ioutil.WriteFile(filepath.Join(dataDir, "sample-0-relay-bin.index"), []byte("./sample-0-relay-bin.000001\n./sample-0-relay-bin.000002\n"), 0644)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dataDir)
+		os.RemoveAll(storageDir)
+	})
+
+	It("should archive closed binlogs only", func() {
+		Expect(archiver.Archive(ctx)).Should(Succeed())
+		Expect(list()).Should(Equal([]string{
+			"sample/binlog/20200701T120000Z-sample-0-bin.000001",
+			"sample/binlog/20200701T120500Z-sample-0-bin.000002",
+		}))
+
+		status := archiver.Status()
+		Expect(status.LastArchivedKey).Should(Equal("sample/binlog/20200701T120500Z-sample-0-bin.000002"))
+		Expect(*status.LastArchivedTime).Should(BeTemporally("==", base.Add(5*time.Minute)))
+		Expect(status.LastError).Should(BeEmpty())
+	})
+
+	It("should archive a binlog once it is closed", func() {
+		Expect(archiver.Archive(ctx)).Should(Succeed())
+		writeBinlog("sample-0-bin.000004", "", base.Add(10*time.Minute))
+		writeIndex("sample-0-bin.000001", "sample-0-bin.000002", "sample-0-bin.000003", "sample-0-bin.000004")
+		Expect(archiver.Archive(ctx)).Should(Succeed())
+		Expect(list()).Should(HaveLen(3))
+		Expect(archiver.Status().LastArchivedKey).Should(Equal("sample/binlog/20200701T120700Z-sample-0-bin.000003"))
+	})
+
+	It("should not archive binlogs again after a restart", func() {
+		Expect(archiver.Archive(ctx)).Should(Succeed())
+		// 보관한 파일이 바뀌면 다시 올린 것을 알 수 있다
+		Expect(ioutil.WriteFile(filepath.Join(storageDir, "sample/binlog/20200701T120000Z-sample-0-bin.000001"), []byte("archived"), 0644)).Should(Succeed())
+
+		restarted := &Archiver{Backend: backend, Dir: dataDir, Prefix: prefix}
+		Expect(restarted.Status().LastArchivedKey).Should(BeEmpty())
+		Expect(restarted.Archive(ctx)).Should(Succeed())
+		Expect(restarted.Status().LastArchivedKey).Should(Equal("sample/binlog/20200701T120500Z-sample-0-bin.000002"))
+		data, err := ioutil.ReadFile(filepath.Join(storageDir, "sample/binlog/20200701T120000Z-sample-0-bin.000001"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal("archived"))
+	})
+
+	It("should report why archiving failed", func() {
+		writeIndex("sample-0-bin.000001", "sample-0-bin.000009", "sample-0-bin.000010")
+		Expect(archiver.Archive(ctx)).ShouldNot(Succeed())
+		Expect(archiver.Status().LastError).Should(ContainSubstring("sample-0-bin.000009"))
+	})
+
+	It("should serve its status", func() {
+		Expect(archiver.Archive(ctx)).Should(Succeed())
+		server := httptest.NewServer(archiver)
+		defer server.Close()
+
+		resp, err := server.Client().Get(server.URL + "/status")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(body)).Should(ContainSubstring(`"lastArchivedKey":"sample/binlog/20200701T120500Z-sample-0-bin.000002"`))
+	})
+
+	It("should download binlogs written after the backup", func() {
+		Expect(archiver.Archive(ctx)).Should(Succeed())
+		restoreDir := filepath.Join(dataDir, ".pitr")
+		files, err := Download(ctx, backend, prefix, base.Add(time.Minute), restoreDir)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(files).Should(Equal([]string{"20200701T120500Z-sample-0-bin.000002"}))
+		data, err := ioutil.ReadFile(filepath.Join(restoreDir, files[0]))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal("second"))
+	})
+
+	It("should parse the keys it creates", func() {
+		t, file, err := ParseKey(prefix, Key(prefix, base.In(time.FixedZone("KST", 9*60*60)), "sample-0-bin.000001"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(t).Should(BeTemporally("==", base))
+		Expect(file).Should(Equal("sample-0-bin.000001"))
+		_, _, err = ParseKey(prefix, prefix+"sample-0-bin.000001")
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,54 @@
+package binlog
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"sample-mysql-operator/pkg/storage"
+)
+
+// Download 는 prefix 아래에 보관된 바이너리 로그 중 since 이후에 기록된 파일을 dir 에 내려받고 재생할 순서대로 파일 이름을 반환한다.
+// since 이전에 닫힌 바이너리 로그의 트랜잭션은 모두 백업에 포함되어 있다. 파일 이름은 키와 같으므로 이름 순서가 시간 순서이다
+func Download(ctx context.Context, backend storage.Backend, prefix string, since time.Time, dir string) ([]string, error) {
+	keys, err := backend.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, key := range keys {
+		t, _, err := ParseKey(prefix, key)
+		if err != nil || t.Before(since) {
+			continue
+		}
+		name := path.Base(key)
+		if err := download(ctx, backend, key, filepath.Join(dir, name)); err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+func download(ctx context.Context, backend storage.Backend, key, name string) error {
+	r, err := backend.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,57 @@
+package binlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusPort 는 아카이버가 보관 상태를 알려주는 HTTP 포트이다
+const StatusPort = 8081
+
+// Status 는 아카이버의 보관 상태이다
+type Status struct {
+	// LastArchivedKey 는 가장 최근에 기록된 보관된 바이너리 로그의 키이다
+	LastArchivedKey string `json:"lastArchivedKey,omitempty"`
+	// LastArchivedTime 은 LastArchivedKey 의 바이너리 로그에 마지막으로 기록된 시각이다
+	LastArchivedTime *time.Time `json:"lastArchivedTime,omitempty"`
+	// LastError 는 마지막 보관이 실패한 이유이다
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ServeHTTP 는 보관 상태를 JSON 으로 응답한다
+func (a *Archiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.Status())
+}
+
+// StatusFunc 는 host 에서 동작하는 아카이버의 보관 상태를 가져온다. 테스트에서는 가짜 상태를 반환하는 함수로 바꿀 수 있다
+type StatusFunc func(ctx context.Context, host string) (*Status, error)
+
+// GetStatus 는 host 의 StatusPort 로 보관 상태를 가져온다
+func GetStatus(ctx context.Context, host string) (*Status, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	url := fmt.Sprintf("http://%s/status", net.JoinHostPort(host, strconv.Itoa(StatusPort)))
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+	status := &Status{}
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
@@ -0,0 +1,298 @@
+// Package mysqlclient 는 오퍼레이터가 MySQL 서버에 직접 접속하여 복제를 관리할 때 사용하는 클라이언트이다
+package mysqlclient
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ReplicaStatus 는 SHOW SLAVE STATUS 의 결과 중 오퍼레이터가 사용하는 값이다
+type ReplicaStatus struct {
+	// SourceHost 는 복제하고 있는 프라이머리의 호스트이다
+	SourceHost string
+	// IORunning 은 복제 I/O 스레드가 동작 중인지를 나타낸다
+	IORunning bool
+	// SQLRunning 은 복제 SQL 스레드가 동작 중인지를 나타낸다
+	SQLRunning bool
+	// SecondsBehindSource 는 복제 지연 시간이다. SQL 스레드가 멈춰 있으면 nil 이다
+	SecondsBehindSource *int64
+	// LastIOError 는 복제 I/O 스레드의 마지막 오류이다
+	LastIOError string
+	// LastSQLError 는 복제 SQL 스레드의 마지막 오류이다
+	LastSQLError string
+	// RetrievedGTIDSet 은 프라이머리로부터 받은 트랜잭션의 GTID 집합이다
+	RetrievedGTIDSet string
+	// ExecutedGTIDSet 은 이 서버에서 실행한 트랜잭션의 GTID 집합이다
+	ExecutedGTIDSet string
+}
+
+// Client 는 하나의 MySQL 서버에 대한 복제 관리 작업을 나타낸다
+type Client interface {
+	// ReplicaStatus 는 복제 상태를 반환한다. 레플리카가 아니면 nil 을 반환한다
+	ReplicaStatus(ctx context.Context) (*ReplicaStatus, error)
+	// ExecutedGTIDSet 은 이 서버에서 실행한 트랜잭션의 GTID 집합을 반환한다
+	ExecutedGTIDSet(ctx context.Context) (string, error)
+	// ReadOnly 는 read_only 와 super_read_only 시스템 변수의 값을 반환한다
+	ReadOnly(ctx context.Context) (readOnly, superReadOnly bool, err error)
+	// SetReadOnly 는 새로운 쓰기를 막는다
+	SetReadOnly(ctx context.Context) error
+	// StopReplicaIO 는 프라이머리로부터 트랜잭션을 받는 것을 멈춘다
+	StopReplicaIO(ctx context.Context) error
+	// StopReplica 는 복제를 멈춘다
+	StopReplica(ctx context.Context) error
+	// StartReplica 는 멈춘 복제를 다시 시작한다
+	StartReplica(ctx context.Context) error
+	// WaitForExecutedGTIDSet 은 GTID 집합의 트랜잭션이 모두 실행될 때까지 기다린다
+	WaitForExecutedGTIDSet(ctx context.Context, gtidSet string, timeout time.Duration) error
+	// Promote 는 복제를 끊고 쓰기를 허용하여 프라이머리로 만든다
+	Promote(ctx context.Context) error
+	// SetSource 는 읽기 전용으로 전환하고 GTID 자동 위치로 host 를 복제하도록 한다
+	SetSource(ctx context.Context, host, user, password string) error
+	// FlushBinaryLogs 는 현재 바이너리 로그를 닫고 새 바이너리 로그를 시작한다
+	FlushBinaryLogs(ctx context.Context) error
+	// GTIDMode 는 gtid_mode 시스템 변수의 값을 반환한다
+	GTIDMode(ctx context.Context) (string, error)
+	// SetGTIDMode 는 gtid_mode 를 바꾼다. ON 으로 바꿀 때는 enforce_gtid_consistency 를 ON 으로, 그 전 단계에서는 WARN 으로 설정한다
+	SetGTIDMode(ctx context.Context, mode string) error
+	// OngoingAnonymousTransactions 는 GTID 없이 실행 중인 트랜잭션의 수를 반환한다
+	OngoingAnonymousTransactions(ctx context.Context) (int64, error)
+	// BinaryLogPosition 은 현재 바이너리 로그 파일과 위치를 반환한다
+	BinaryLogPosition(ctx context.Context) (string, int64, error)
+	// WaitForBinaryLogPosition 은 프라이머리의 바이너리 로그 위치까지 적용할 때까지 기다린다
+	WaitForBinaryLogPosition(ctx context.Context, file string, position int64, timeout time.Duration) error
+	// Close 는 연결을 닫는다
+	Close() error
+}
+
+// Factory 는 MySQL 서버에 접속하는 클라이언트를 만든다. 테스트에서는 가짜 클라이언트를 만드는 함수로 바꿀 수 있다
+type Factory func(host, user, password string) (Client, error)
+
+// New 는 host 의 3306 포트로 접속하는 클라이언트를 만든다
+func New(host, user, password string) (Client, error) {
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:3306", host)
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.Timeout = 5 * time.Second
+	cfg.ReadTimeout = time.Minute
+	cfg.InterpolateParams = true
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	// 세션 변수를 유지하기 위해 하나의 연결만 사용한다
+	db.SetMaxOpenConns(1)
+	return &client{db: db}, nil
+}
+
+type client struct {
+	db *sql.DB
+}
+
+func (c *client) ReplicaStatus(ctx context.Context) (*ReplicaStatus, error) {
+	row, err := c.queryRow(ctx, "SHOW SLAVE STATUS")
+	if err != nil || row == nil {
+		return nil, err
+	}
+	status := &ReplicaStatus{
+		SourceHost:       row["Master_Host"],
+		IORunning:        row["Slave_IO_Running"] == "Yes",
+		SQLRunning:       row["Slave_SQL_Running"] == "Yes",
+		LastIOError:      row["Last_IO_Error"],
+		LastSQLError:     row["Last_SQL_Error"],
+		RetrievedGTIDSet: normalizeGTIDSet(row["Retrieved_Gtid_Set"]),
+		ExecutedGTIDSet:  normalizeGTIDSet(row["Executed_Gtid_Set"]),
+	}
+	// SQL 스레드가 멈춰 있으면 Seconds_Behind_Master 는 NULL 이다
+	if seconds, err := strconv.ParseInt(row["Seconds_Behind_Master"], 10, 64); err == nil {
+		status.SecondsBehindSource = &seconds
+	}
+	return status, nil
+}
+
+func (c *client) ReadOnly(ctx context.Context) (bool, bool, error) {
+	var readOnly, superReadOnly bool
+	if err := c.db.QueryRowContext(ctx, "SELECT @@GLOBAL.read_only, @@GLOBAL.super_read_only").Scan(&readOnly, &superReadOnly); err != nil {
+		return false, false, err
+	}
+	return readOnly, superReadOnly, nil
+}
+
+func (c *client) ExecutedGTIDSet(ctx context.Context) (string, error) {
+	var gtidSet string
+	if err := c.db.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&gtidSet); err != nil {
+		return "", err
+	}
+	return normalizeGTIDSet(gtidSet), nil
+}
+
+func (c *client) SetReadOnly(ctx context.Context) error {
+	return c.exec(ctx, "SET GLOBAL super_read_only = ON")
+}
+
+func (c *client) FlushBinaryLogs(ctx context.Context) error {
+	return c.exec(ctx, "FLUSH BINARY LOGS")
+}
+
+func (c *client) StopReplicaIO(ctx context.Context) error {
+	_, err := c.db.ExecContext(ctx, "STOP SLAVE IO_THREAD")
+	return err
+}
+
+func (c *client) StopReplica(ctx context.Context) error {
+	return c.exec(ctx, "STOP SLAVE")
+}
+
+func (c *client) StartReplica(ctx context.Context) error {
+	return c.exec(ctx, "START SLAVE")
+}
+
+func (c *client) WaitForExecutedGTIDSet(ctx context.Context, gtidSet string, timeout time.Duration) error {
+	if gtidSet == "" {
+		return nil
+	}
+	var timedOut int
+	if err := c.db.QueryRowContext(ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", gtidSet, int(timeout.Seconds())).Scan(&timedOut); err != nil {
+		return err
+	}
+	if timedOut != 0 {
+		return fmt.Errorf("timed out waiting for %s to be executed", gtidSet)
+	}
+	return nil
+}
+
+func (c *client) Promote(ctx context.Context) error {
+	return c.exec(ctx,
+		"STOP SLAVE",
+		"RESET SLAVE ALL",
+		"SET GLOBAL super_read_only = OFF",
+		"SET GLOBAL read_only = OFF",
+	)
+}
+
+func (c *client) SetSource(ctx context.Context, host, user, password string) error {
+	if err := c.exec(ctx, "SET GLOBAL super_read_only = ON", "STOP SLAVE"); err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, "CHANGE MASTER TO MASTER_HOST = ?, MASTER_USER = ?, MASTER_PASSWORD = ?, MASTER_AUTO_POSITION = 1, MASTER_CONNECT_RETRY = 10",
+		host, user, password); err != nil {
+		return err
+	}
+	return c.exec(ctx, "START SLAVE")
+}
+
+func (c *client) GTIDMode(ctx context.Context) (string, error) {
+	var mode string
+	if err := c.db.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_mode").Scan(&mode); err != nil {
+		return "", err
+	}
+	return mode, nil
+}
+
+func (c *client) SetGTIDMode(ctx context.Context, mode string) error {
+	if GTIDModeIndex(mode) < 0 {
+		return fmt.Errorf("invalid gtid_mode %q", mode)
+	}
+	enforce := "WARN"
+	if mode == GTIDModeOn {
+		enforce = "ON"
+	}
+	return c.exec(ctx,
+		"SET GLOBAL enforce_gtid_consistency = "+enforce,
+		"SET GLOBAL gtid_mode = "+mode,
+	)
+}
+
+func (c *client) OngoingAnonymousTransactions(ctx context.Context) (int64, error) {
+	row, err := c.queryRow(ctx, "SHOW GLOBAL STATUS LIKE 'Ongoing_anonymous_transaction_count'")
+	if err != nil {
+		return 0, err
+	}
+	if row == nil {
+		return 0, fmt.Errorf("Ongoing_anonymous_transaction_count is not available")
+	}
+	return strconv.ParseInt(row["Value"], 10, 64)
+}
+
+func (c *client) BinaryLogPosition(ctx context.Context) (string, int64, error) {
+	row, err := c.queryRow(ctx, "SHOW MASTER STATUS")
+	if err != nil {
+		return "", 0, err
+	}
+	if row == nil {
+		return "", 0, fmt.Errorf("binary logging is not enabled")
+	}
+	position, err := strconv.ParseInt(row["Position"], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return row["File"], position, nil
+}
+
+func (c *client) WaitForBinaryLogPosition(ctx context.Context, file string, position int64, timeout time.Duration) error {
+	var result sql.NullInt64
+	if err := c.db.QueryRowContext(ctx, "SELECT MASTER_POS_WAIT(?, ?, ?)", file, position, int(timeout.Seconds())).Scan(&result); err != nil {
+		return err
+	}
+	if !result.Valid {
+		return fmt.Errorf("replication is not running")
+	}
+	if result.Int64 < 0 {
+		return fmt.Errorf("timed out waiting for %s:%d to be applied", file, position)
+	}
+	return nil
+}
+
+func (c *client) Close() error {
+	return c.db.Close()
+}
+
+func (c *client) exec(ctx context.Context, queries ...string) error {
+	for _, query := range queries {
+		if _, err := c.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("%s: %v", query, err)
+		}
+	}
+	return nil
+}
+
+// queryRow 는 첫 번째 행을 열 이름과 문자열 값의 맵으로 반환한다. 결과가 없으면 nil 을 반환한다
+func (c *client) queryRow(ctx context.Context, query string) (map[string]string, error) {
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	row := make(map[string]string, len(columns))
+	for i, column := range columns {
+		row[column] = string(values[i])
+	}
+	return row, nil
+}
+
+// normalizeGTIDSet 은 MySQL 이 여러 줄로 출력한 GTID 집합에서 공백을 제거한다
+func normalizeGTIDSet(gtidSet string) string {
+	return strings.Join(strings.Fields(gtidSet), "")
+}
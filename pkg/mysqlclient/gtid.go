@@ -0,0 +1,162 @@
+package mysqlclient
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gtid_mode 시스템 변수의 값이다
+const (
+	GTIDModeOff           = "OFF"
+	GTIDModeOffPermissive = "OFF_PERMISSIVE"
+	GTIDModeOnPermissive  = "ON_PERMISSIVE"
+	GTIDModeOn            = "ON"
+)
+
+// GTIDModes 는 gtid_mode 를 OFF 에서 ON 으로 바꿀 때 거치는 순서이다. MySQL 은 한 번에 한 단계씩만 바꿀 수 있고,
+// 복제하는 서버끼리는 인접한 단계에 있어야 한다
+var GTIDModes = []string{GTIDModeOff, GTIDModeOffPermissive, GTIDModeOnPermissive, GTIDModeOn}
+
+// GTIDModeIndex 는 GTIDModes 에서 mode 의 순서를 반환한다. 알 수 없는 값이면 -1 을 반환한다
+func GTIDModeIndex(mode string) int {
+	for i, m := range GTIDModes {
+		if strings.EqualFold(m, mode) {
+			return i
+		}
+	}
+	return -1
+}
+
+// GTIDSet 은 서버 UUID 별 트랜잭션 번호 구간의 집합이다
+type GTIDSet map[string][]interval
+
+type interval struct {
+	start, end int64
+}
+
+// ParseGTIDSet 은 "uuid:1-5:7,uuid2:1-3" 형식의 GTID 집합을 해석한다
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	set := GTIDSet{}
+	s = normalizeGTIDSet(s)
+	if s == "" {
+		return set, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Split(part, ":")
+		if len(fields) < 2 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid gtid set %q", part)
+		}
+		uuid := strings.ToLower(fields[0])
+		for _, r := range fields[1:] {
+			iv, err := parseInterval(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gtid set %q: %v", part, err)
+			}
+			set[uuid] = append(set[uuid], iv)
+		}
+		set[uuid] = merge(set[uuid])
+	}
+	return set, nil
+}
+
+func parseInterval(s string) (interval, error) {
+	bounds := strings.SplitN(s, "-", 2)
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return interval{}, err
+	}
+	end := start
+	if len(bounds) == 2 {
+		if end, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+			return interval{}, err
+		}
+	}
+	if start < 1 || end < start {
+		return interval{}, fmt.Errorf("invalid interval %q", s)
+	}
+	return interval{start: start, end: end}, nil
+}
+
+// merge 는 구간을 정렬하고 겹치거나 이어지는 구간을 합친다
+func merge(intervals []interval) []interval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+	var merged []interval
+	for _, iv := range intervals {
+		if n := len(merged); n > 0 && iv.start <= merged[n-1].end+1 {
+			if iv.end > merged[n-1].end {
+				merged[n-1].end = iv.end
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// Union 은 두 집합의 합집합을 반환한다
+func (s GTIDSet) Union(other GTIDSet) GTIDSet {
+	union := GTIDSet{}
+	for _, set := range []GTIDSet{s, other} {
+		for uuid, intervals := range set {
+			union[uuid] = merge(append(append([]interval{}, union[uuid]...), intervals...))
+		}
+	}
+	return union
+}
+
+// Contains 는 other 의 모든 트랜잭션이 이 집합에 포함되는지를 반환한다
+func (s GTIDSet) Contains(other GTIDSet) bool {
+	for uuid, intervals := range other {
+		for _, iv := range intervals {
+			if !containsInterval(s[uuid], iv) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// containsInterval 은 합쳐진 구간 중 하나가 iv 를 모두 포함하는지를 반환한다
+func containsInterval(intervals []interval, iv interval) bool {
+	for _, candidate := range intervals {
+		if candidate.start <= iv.start && iv.end <= candidate.end {
+			return true
+		}
+	}
+	return false
+}
+
+// Count 는 집합에 포함된 트랜잭션의 수를 반환한다
+func (s GTIDSet) Count() int64 {
+	var count int64
+	for _, intervals := range s {
+		for _, iv := range intervals {
+			count += iv.end - iv.start + 1
+		}
+	}
+	return count
+}
+
+// String 은 MySQL 이 사용하는 형식으로 집합을 출력한다
+func (s GTIDSet) String() string {
+	uuids := make([]string, 0, len(s))
+	for uuid := range s {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	parts := make([]string, 0, len(uuids))
+	for _, uuid := range uuids {
+		part := uuid
+		for _, iv := range s[uuid] {
+			if iv.start == iv.end {
+				part += fmt.Sprintf(":%d", iv.start)
+			} else {
+				part += fmt.Sprintf(":%d-%d", iv.start, iv.end)
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,59 @@
+package mysqlclient
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gtid set", func() {
+	const (
+		a = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+		b = "4f22fb58-82db-22f2-af44-d91bba53a673"
+	)
+
+	It("should parse and count a gtid set", func() {
+		set, err := ParseGTIDSet(a + ":1-5:7,\n" + b + ":1-3")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(set.Count()).Should(Equal(int64(9)))
+		Expect(set.String()).Should(Equal(a + ":1-5:7," + b + ":1-3"))
+	})
+
+	It("should parse an empty gtid set", func() {
+		set, err := ParseGTIDSet("")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(set.Count()).Should(BeZero())
+	})
+
+	It("should reject an invalid gtid set", func() {
+		for _, s := range []string{a, a + ":0", a + ":5-3", a + ":x", ":1-3"} {
+			_, err := ParseGTIDSet(s)
+			Expect(err).Should(HaveOccurred(), s)
+		}
+	})
+
+	It("should merge sets without counting transactions twice", func() {
+		executed, err := ParseGTIDSet(a + ":1-10")
+		Expect(err).ShouldNot(HaveOccurred())
+		retrieved, err := ParseGTIDSet(a + ":8-12:14," + b + ":1")
+		Expect(err).ShouldNot(HaveOccurred())
+		union := executed.Union(retrieved)
+		Expect(union.String()).Should(Equal(a + ":1-12:14," + b + ":1"))
+		Expect(union.Count()).Should(Equal(int64(14)))
+		Expect(executed.Count()).Should(Equal(int64(10)))
+	})
+
+	It("should check whether a set contains another", func() {
+		parse := func(s string) GTIDSet {
+			set, err := ParseGTIDSet(s)
+			Expect(err).ShouldNot(HaveOccurred())
+			return set
+		}
+		Expect(parse(a + ":1-10:12," + b + ":1-3").Contains(parse(a + ":2-5:12," + b + ":3"))).Should(BeTrue())
+		Expect(parse(a + ":1-10").Contains(parse(""))).Should(BeTrue())
+		Expect(parse("").Contains(parse(a + ":1"))).Should(BeFalse())
+		// 개수가 같아도 다른 트랜잭션을 가진 집합은 서로 포함하지 않는다
+		Expect(parse(a + ":1-5").Contains(parse(a + ":1-4:6"))).Should(BeFalse())
+		Expect(parse(a + ":1-4:6").Contains(parse(a + ":1-5"))).Should(BeFalse())
+		Expect(parse(a + ":1-5").Contains(parse(b + ":1"))).Should(BeFalse())
+	})
+})
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+)
+
+// itBehavesLikeBackend 는 모든 저장소가 지켜야 하는 동작을 확인한다
+func itBehavesLikeBackend(backend func() Backend) {
+	ctx := context.TODO()
+
+	It("should store and read an object", func() {
+		b := backend()
+		size, err := b.Put(ctx, "sample/backup-1.xbstream", strings.NewReader("backup"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(size).Should(Equal(int64(6)))
+
+		r, err := b.Get(ctx, "sample/backup-1.xbstream")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal("backup"))
+	})
+
+	It("should list objects by prefix", func() {
+		b := backend()
+		for _, key := range []string{"sample/b.xbstream", "sample/a.xbstream", "other/c.xbstream"} {
+			_, err := b.Put(ctx, key, strings.NewReader(key))
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		keys, err := b.List(ctx, "sample/")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(keys).Should(Equal([]string{"sample/a.xbstream", "sample/b.xbstream"}))
+	})
+
+	It("should delete objects", func() {
+		b := backend()
+		_, err := b.Put(ctx, "sample/a.xbstream", strings.NewReader("a"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(b.Delete(ctx, "sample/a.xbstream")).Should(Succeed())
+		Expect(b.Delete(ctx, "sample/a.xbstream")).Should(Succeed())
+		_, err = b.Get(ctx, "sample/a.xbstream")
+		Expect(err).Should(HaveOccurred())
+	})
+}
+
+var _ = Describe("filesystem storage", func() {
+	var root string
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "storage")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(root)).Should(Succeed())
+	})
+
+	itBehavesLikeBackend(func() Backend {
+		b, err := New(&mysqlv1alpha1.BackupStorage{
+			PersistentVolumeClaim: &mysqlv1alpha1.PVCBackupStorage{ClaimName: "backups", Path: "mysql"},
+		}, Options{MountPath: root})
+		Expect(err).ShouldNot(HaveOccurred())
+		return b
+	})
+
+	It("should describe the location with the claim name", func() {
+		b := NewFilesystem(root, "pvc://backups/mysql")
+		Expect(b.Location("sample/a.xbstream")).Should(Equal("pvc://backups/mysql/sample/a.xbstream"))
+	})
+
+	It("should not leave a partial object when the upload fails", func() {
+		b := NewFilesystem(root, "pvc://backups")
+		_, err := b.Put(context.TODO(), "sample/a.xbstream", &failingReader{})
+		Expect(err).Should(HaveOccurred())
+		keys, err := b.List(context.TODO(), "")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(keys).Should(BeEmpty())
+	})
+})
+
+// S3 저장소는 S3_TEST_ENDPOINT 에 지정한 오브젝트 스토리지로 확인한다. 로컬에서는 make minio 로 띄운 MinIO 를 사용한다
+var _ = Describe("s3 storage", func() {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+
+	BeforeEach(func() {
+		if endpoint == "" {
+			Skip("S3_TEST_ENDPOINT is not set")
+		}
+	})
+
+	itBehavesLikeBackend(func() Backend {
+		spec := &mysqlv1alpha1.S3BackupStorage{
+			Endpoint:          endpoint,
+			Bucket:            os.Getenv("S3_TEST_BUCKET"),
+			Prefix:            "storage-test",
+			Insecure:          true,
+			CredentialsSecret: corev1.LocalObjectReference{Name: "unused"},
+		}
+		b, err := NewS3(spec, os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"))
+		Expect(err).ShouldNot(HaveOccurred())
+		// 이전 실행에서 남은 오브젝트를 지운다
+		keys, err := b.List(context.TODO(), "")
+		Expect(err).ShouldNot(HaveOccurred())
+		for _, key := range keys {
+			Expect(b.Delete(context.TODO(), key)).Should(Succeed())
+		}
+		return b
+	})
+})
+
+var _ = Describe("storage spec", func() {
+	It("should require exactly one storage", func() {
+		Expect(Validate(&mysqlv1alpha1.BackupStorage{})).ShouldNot(Succeed())
+		Expect(Validate(&mysqlv1alpha1.BackupStorage{
+			PersistentVolumeClaim: &mysqlv1alpha1.PVCBackupStorage{ClaimName: "backups"},
+			S3:                    &mysqlv1alpha1.S3BackupStorage{Endpoint: "minio:9000", Bucket: "backups"},
+		})).ShouldNot(Succeed())
+		Expect(Validate(&mysqlv1alpha1.BackupStorage{
+			S3: &mysqlv1alpha1.S3BackupStorage{Endpoint: "minio:9000", Bucket: "backups"},
+		})).Should(Succeed())
+	})
+})
+
+type failingReader struct{}
+
+func (*failingReader) Read(p []byte) (int, error) {
+	return 0, os.ErrClosed
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// filesystem 은 디렉터리에 키를 파일로 저장한다
+type filesystem struct {
+	root     string
+	location string
+}
+
+// NewFilesystem 은 root 디렉터리에 저장하는 저장소를 만든다. location 은 Location 이 반환하는 위치의 앞부분이다
+func NewFilesystem(root, location string) Backend {
+	return &filesystem{root: root, location: location}
+}
+
+func (f *filesystem) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	name := f.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return 0, err
+	}
+	// 완전히 쓴 파일만 보이도록 임시 파일에 쓴 뒤 이름을 바꾼다
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return size, os.Rename(tmp.Name(), name)
+}
+
+func (f *filesystem) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(f.path(key))
+}
+
+func (f *filesystem) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(f.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *filesystem) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(f.root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(f.root, name)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (f *filesystem) Location(key string) string {
+	return f.location + "/" + key
+}
+
+func (f *filesystem) path(key string) string {
+	return filepath.Join(f.root, filepath.FromSlash(key))
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v6"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+)
+
+// s3 는 S3 호환 오브젝트 스토리지의 버킷에 키를 오브젝트로 저장한다
+type s3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 는 S3 호환 오브젝트 스토리지 저장소를 만든다
+func NewS3(spec *mysqlv1alpha1.S3BackupStorage, accessKeyID, secretAccessKey string) (Backend, error) {
+	client, err := minio.NewWithRegion(spec.Endpoint, accessKeyID, secretAccessKey, !spec.Insecure, spec.Region)
+	if err != nil {
+		return nil, err
+	}
+	return &s3{client: client, bucket: spec.Bucket, prefix: strings.Trim(spec.Prefix, "/")}, nil
+}
+
+func (s *s3) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	// 크기를 모르는 스트림은 멀티파트로 올리며, 실패하면 올린 부분을 지운다
+	return s.client.PutObjectWithContext(ctx, s.bucket, s.object(key), r, -1, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+}
+
+func (s *s3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObjectWithContext(ctx, s.bucket, s.object(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// 오브젝트가 없으면 첫 번째 읽기가 아니라 Stat 에서 오류를 알 수 있다
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, err
+	}
+	return object, nil
+}
+
+func (s *s3) Delete(ctx context.Context, key string) error {
+	err := s.client.RemoveObject(s.bucket, s.object(key))
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil
+	}
+	return err
+}
+
+func (s *s3) List(ctx context.Context, prefix string) ([]string, error) {
+	done := make(chan struct{})
+	defer close(done)
+	var keys []string
+	for object := range s.client.ListObjectsV2(s.bucket, s.object(prefix), true, done) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		keys = append(keys, strings.TrimPrefix(strings.TrimPrefix(object.Key, s.prefix), "/"))
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *s3) Location(key string) string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.object(key))
+}
+
+func (s *s3) object(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return path.Join(s.prefix, key)
+}
@@ -0,0 +1,64 @@
+// Package storage 는 백업과 바이너리 로그를 저장하는 저장소이다
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	mysqlv1alpha1 "sample-mysql-operator/api/v1alpha1"
+)
+
+// Backend 는 키로 오브젝트를 저장하고 읽는 저장소이다. 키는 / 로 구분한 상대 경로이다
+type Backend interface {
+	// Put 은 r 의 내용을 key 에 저장하고 저장한 크기를 반환한다. 저장에 실패하면 일부만 저장된 오브젝트를 남기지 않는다
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Get 은 key 에 저장된 내용을 읽는다
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 는 key 를 삭제한다. 이미 없으면 성공한다
+	Delete(ctx context.Context, key string) error
+	// List 는 prefix 로 시작하는 모든 키를 이름 순서대로 반환한다
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Location 은 사람이 알아볼 수 있는 key 의 위치를 반환한다
+	Location(key string) string
+}
+
+// Options 는 저장소에 접근하는 데 필요한 값이다
+type Options struct {
+	// MountPath 는 볼륨 클레임이 마운트된 경로이다
+	MountPath string
+	// AccessKeyID 와 SecretAccessKey 는 S3 호환 저장소의 인증 정보이다
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// New 는 spec 에 지정된 저장소를 만든다
+func New(spec *mysqlv1alpha1.BackupStorage, opts Options) (Backend, error) {
+	if err := Validate(spec); err != nil {
+		return nil, err
+	}
+	if pvc := spec.PersistentVolumeClaim; pvc != nil {
+		return NewFilesystem(path.Join(opts.MountPath, pvc.Path), fmt.Sprintf("pvc://%s", path.Join(pvc.ClaimName, pvc.Path))), nil
+	}
+	return NewS3(spec.S3, opts.AccessKeyID, opts.SecretAccessKey)
+}
+
+// Validate 는 하나의 저장소만 지정되었는지 확인한다
+func Validate(spec *mysqlv1alpha1.BackupStorage) error {
+	switch {
+	case spec.PersistentVolumeClaim != nil && spec.S3 != nil:
+		return fmt.Errorf("only one of persistentVolumeClaim and s3 can be specified")
+	case spec.PersistentVolumeClaim != nil:
+		if spec.PersistentVolumeClaim.ClaimName == "" {
+			return fmt.Errorf("persistentVolumeClaim.claimName is required")
+		}
+	case spec.S3 != nil:
+		if spec.S3.Endpoint == "" || spec.S3.Bucket == "" {
+			return fmt.Errorf("s3.endpoint and s3.bucket are required")
+		}
+	default:
+		return fmt.Errorf("one of persistentVolumeClaim and s3 must be specified")
+	}
+	return nil
+}